@@ -5,21 +5,29 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/auth"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/contest"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/question"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/test"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/testcase"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/trash"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/users"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/mailer"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/ratelimit"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/memory"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/mongodb"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	// "github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/users"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/http/handler/submission"
@@ -37,14 +45,64 @@ func main() {
 
 	//database
 	//storage
-    storage, err := mongodb.New(cfg)
+    storage, mongoStorage, err := openStorage(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	slog.Info("Database connected",cfg.DatabaseName)
+
+	blobs, err := openBlobs(cfg, mongoStorage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mail := mailer.New(cfg.Mail)
 
 	// Initialize auth middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JwtSecret)
+	authMiddleware := middleware.NewAuthMiddleware(cfg.JwtSecret, storage)
+	authenticated := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(handler)
+	}
+	admin := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(authMiddleware.RequireAdmin(handler))
+	}
+	optional := func(handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Optional(handler)
+	}
+
+	// Rate limiting: in-memory by default, shared through Mongo when
+	// several API instances run behind the load balancer
+	var limiterStore ratelimit.Store
+	switch cfg.RateLimit.Store {
+	case "mongodb":
+		if mongoStorage == nil {
+			log.Fatal("rate_limit.store mongodb requires mongodb storage")
+		}
+		limiterStore, err = mongoStorage.NewRateLimitStore(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		memoryStore := ratelimit.NewMemoryStore()
+		go func() {
+			for range time.Tick(time.Minute) {
+				memoryStore.Sweep()
+			}
+		}()
+		limiterStore = memoryStore
+	}
+	limiter := ratelimit.New(limiterStore, ratelimit.LockoutPolicy{
+		Threshold:    cfg.RateLimit.LockoutThreshold,
+		BaseDuration: cfg.RateLimit.LockoutDuration,
+		MaxDuration:  cfg.RateLimit.LockoutMaxDuration,
+		Window:       cfg.RateLimit.LockoutFailureWindow,
+	})
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(limiter, cfg.RateLimit.TrustProxy)
+	loginIPLimit := ratelimit.Limit{Interval: cfg.RateLimit.LoginIPInterval, Burst: cfg.RateLimit.LoginIPBurst}
+	loginAccountLimit := ratelimit.Limit{Interval: cfg.RateLimit.LoginAccountInterval, Burst: cfg.RateLimit.LoginAccountBurst}
+	signupIPLimit := ratelimit.Limit{Interval: cfg.RateLimit.SignupIPInterval, Burst: cfg.RateLimit.SignupIPBurst}
+	runLimit := ratelimit.Limit{Interval: cfg.RateLimit.RunInterval, Burst: cfg.RateLimit.RunBurst}
+	submitLimit := ratelimit.Limit{Interval: cfg.Submissions.Cooldown, Burst: 1}
+	judgeQueue := middleware.NewConcurrencyLimit(cfg.Submissions.MaxInFlight, 5*time.Second)
 
 	// Setup routes
 	router := http.NewServeMux()
@@ -73,31 +131,115 @@ router.Handle("GET /api/admin/test",
     ),
 )
 
-	router.HandleFunc("POST /api/signup",users.New(storage))
-	router.HandleFunc("POST /api/login",auth.Login(storage,cfg.JwtSecret))
-	router.HandleFunc("POST /api/contest",contest.CreateContest(storage))
-	router.HandleFunc("DELETE /api/contest/{id}",contest.DeleteContestById(storage))
-	router.HandleFunc("PUT /api/contest/{id}",contest.EditContestById(storage))	
-	router.HandleFunc("POST /api/question",question.CreateQuestion(storage))
-	router.HandleFunc("PUT /api/question/{id}",question.EditQuestionById(storage))
-	router.HandleFunc("POST /api/testcase",testcase.CreateTestCase(storage))
-	router.HandleFunc("PUT /api/testcase/{id}",testcase.EditTestCaseById(storage))
+	router.Handle("POST /api/signup",
+		rateLimitMiddleware.LimitByIP("signup", signupIPLimit,
+			http.HandlerFunc(users.New(storage)),
+		),
+	)
+	router.Handle("POST /api/login",
+		rateLimitMiddleware.LimitByIP("login", loginIPLimit,
+			http.HandlerFunc(auth.Login(storage, cfg.JwtSecret, limiter, loginAccountLimit)),
+		),
+	)
+	router.Handle("POST /api/contest", admin(contest.CreateContest(storage)))
+	router.Handle("DELETE /api/contest/{id}", admin(contest.DeleteContestById(storage, cfg.Deletion.Cascades())))
+	router.Handle("PUT /api/contest/{id}", admin(contest.EditContestById(storage)))	
+	router.Handle("POST /api/question", admin(question.CreateQuestion(storage)))
+	router.Handle("PUT /api/question/{id}", admin(question.EditQuestionById(storage)))
+	router.Handle("POST /api/testcase", admin(testcase.CreateTestCase(storage, blobs, cfg.Blobs)))
+	router.Handle("PUT /api/testcase/{id}", admin(testcase.EditTestCaseById(storage, blobs, cfg.Blobs, judgeClient)))
 	router.HandleFunc("GET /api/contest",contest.GetAllContests(storage))
 	router.HandleFunc("GET /api/contest/{id}",contest.GetContestById(storage))
-	router.HandleFunc("GET /api/question/{id}",question.GetQuestionById(storage))
-	router.HandleFunc("POST /api/contest/{id}/question", contest.AddQuestionToContest(storage))
-	router.HandleFunc("DELETE /api/contest/{contestId}/question/{questionId}", contest.DeleteQuestionFromContestById(storage))
-	router.HandleFunc("POST /api/question/{id}/testcase", question.AddTestCaseToQuestion(storage))
-	router.HandleFunc("DELETE /api/question/{questionId}/testcase/{testCaseId}", question.DeleteTestCaseFromQuestionById(storage))
-	router.HandleFunc("POST /api/submissions", submission.CreateSubmission(storage, judgeClient))
+	router.Handle("GET /api/question/{id}", optional(question.GetQuestionById(storage, blobs)))
+	router.Handle("POST /api/contest/{id}/question", admin(contest.AddQuestionToContest(storage)))
+	router.Handle("DELETE /api/contest/{contestId}/question/{questionId}", admin(contest.DeleteQuestionFromContestById(storage)))
+	router.Handle("POST /api/question/{id}/testcase", admin(question.AddTestCaseToQuestion(storage, blobs, cfg.Blobs, judgeClient)))
+	router.Handle("DELETE /api/question/{questionId}/testcase/{testCaseId}", admin(question.DeleteTestCaseFromQuestionById(storage)))
+	// Submissions and runs wait on the judge, so refuse them while it is
+	// saturated rather than queue without bound
+	router.Handle("POST /api/submissions", authMiddleware.Authenticate(
+		judgeQueue.Limit(
+			rateLimitMiddleware.LimitByUser("submit", submitLimit,
+				submission.CreateSubmission(storage, judgeClient, blobs, cfg.Submissions),
+			),
+		),
+	))
+	router.Handle("POST /api/run", authMiddleware.Authenticate(
+		judgeQueue.Limit(
+			rateLimitMiddleware.LimitByUser("run", runLimit,
+				submission.RunCode(storage, judgeClient, blobs, cfg.Submissions),
+			),
+		),
+	))
+
+	// Profiles
+	router.Handle("GET /api/me", authenticated(users.GetMe(storage)))
+	router.Handle("PUT /api/me", authenticated(users.UpdateMe(storage)))
+	router.HandleFunc("GET /api/users/{id}", users.GetPublicProfile(storage))
+
+	// User administration
+	router.Handle("GET /api/admin/users", admin(users.ListUsers(storage)))
+	router.Handle("PUT /api/admin/users/{id}/role", admin(users.UpdateUserRole(storage)))
+	router.Handle("POST /api/admin/users/{id}/disable", admin(users.SetUserDisabled(storage, true)))
+	router.Handle("POST /api/admin/users/{id}/enable", admin(users.SetUserDisabled(storage, false)))
+	router.Handle("DELETE /api/admin/users/{id}", admin(users.DeleteUserById(storage)))
+	router.Handle("POST /api/admin/users/import", admin(users.ImportUsers(storage, mail, cfg.Mail)))
+	// Question bank
+	router.Handle("GET /api/question", admin(question.ListQuestions(storage)))
+	router.Handle("DELETE /api/question/{id}", admin(question.DeleteQuestionById(storage, cfg.Deletion.Cascades())))
+	router.Handle("POST /api/contest/{contestId}/question/{questionId}", admin(contest.LinkQuestionToContest(storage)))
+	router.Handle("PUT /api/contest/{contestId}/question/{questionId}", admin(contest.UpdateContestProblem(storage)))
+	router.Handle("PUT /api/contest/{id}/order", admin(contest.ReorderContestProblems(storage)))
+	// Problem packages
+	router.Handle("POST /api/question/import", admin(question.ImportQuestion(storage, blobs, cfg.Blobs)))
+	router.Handle("GET /api/question/{id}/export", admin(question.ExportQuestion(storage, blobs)))
+	// Test data
+	router.Handle("GET /api/testcase/{id}/input", admin(testcase.GetTestCaseData(storage, blobs, testcase.PartInput)))
+	router.Handle("GET /api/testcase/{id}/output", admin(testcase.GetTestCaseData(storage, blobs, testcase.PartOutput)))
+	router.Handle("PUT /api/testcase/{id}/input", admin(testcase.PutTestCaseData(storage, blobs, cfg.Blobs, judgeClient, testcase.PartInput)))
+	router.Handle("PUT /api/testcase/{id}/output", admin(testcase.PutTestCaseData(storage, blobs, cfg.Blobs, judgeClient, testcase.PartOutput)))
+	router.Handle("POST /api/blobs", admin(testcase.UploadBlob(blobs, cfg.Blobs)))
+	router.Handle("GET /api/blobs/{hash}", admin(testcase.DownloadBlob(blobs)))
+	router.Handle("POST /api/question/{id}/testcases/archive", admin(question.UploadTestCaseArchive(storage, blobs, cfg.Blobs, judgeClient)))
+	// Solutions, validators and generators
+	router.Handle("GET /api/question/{id}/solutions", admin(question.GetQuestionSolutions(storage)))
+	router.Handle("PUT /api/question/{id}/solutions", admin(question.SetQuestionSolutions(storage)))
+	router.Handle("POST /api/question/{id}/validate", admin(question.ValidateQuestion(storage, judgeClient, blobs)))
+	router.Handle("GET /api/question/{id}/validator", admin(question.GetQuestionValidator(storage)))
+	router.Handle("PUT /api/question/{id}/validator", admin(question.SetQuestionValidator(storage)))
+	router.Handle("DELETE /api/question/{id}/validator", admin(question.DeleteQuestionValidator(storage)))
+	router.Handle("GET /api/question/{id}/generators", admin(question.GetQuestionGenerators(storage)))
+	router.Handle("PUT /api/question/{id}/generators", admin(question.SetQuestionGenerators(storage)))
+	router.Handle("POST /api/question/{id}/testcases/generate", admin(question.GenerateTestCases(storage, judgeClient, blobs, cfg.Blobs)))
+	// Revisions
+	router.Handle("GET /api/question/{id}/revisions", admin(question.ListQuestionRevisions(storage)))
+	router.Handle("POST /api/question/{id}/revisions/{number}/rollback", admin(question.RollbackQuestion(storage)))
+	// Trash
+	router.Handle("GET /api/admin/trash", admin(trash.ListTrash(storage)))
+	router.Handle("POST /api/admin/trash/{kind}/{id}/restore", admin(trash.Restore(storage)))
+	router.Handle("POST /api/invitations/accept",
+		rateLimitMiddleware.LimitByIP("invitation", loginIPLimit,
+			http.HandlerFunc(users.AcceptInvitation(storage)),
+		),
+	)
     
 	//start server
 
+	// Every request context derives from baseCtx, so cancelling it aborts
+	// in-flight database work that outlives the shutdown grace period
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
 	server := http.Server{
 		Addr: cfg.Addr,
-		Handler: router,
+		Handler: middleware.RequestID(router),
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
+	go purgeTrash(baseCtx, storage, cfg.Deletion)
+
     fmt.Println("Server is running on port", cfg.Addr)
   
      done := make(chan os.Signal,1)
@@ -106,7 +248,7 @@ router.Handle("GET /api/admin/test",
 
 	go func(){
 		err := server.ListenAndServe()
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 
@@ -124,6 +266,107 @@ router.Handle("GET /api/admin/test",
 
 		slog.Error("Server Shutdown Failed",slog.String("error",err.Error()))
 	}
+	cancelBase()
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelClose()
+
+	if err := storage.Close(closeCtx); err != nil {
+		slog.Error("Database Disconnect Failed",slog.String("error",err.Error()))
+	}
 
 	slog.Info("Server ShutDown Properly")
-}
\ No newline at end of file
+}
+
+// openBlobs opens the store for test data too large to keep in documents.
+func openBlobs(cfg *config.Config, mongoStorage *mongodb.MongoDB) (blob.Store, error) {
+	switch cfg.Blobs.Backend {
+	case "gridfs":
+		return mongoStorage.NewBlobStore(), nil
+	case "filesystem":
+		return blob.NewFileStore(cfg.Blobs.Path)
+	default:
+		slog.Warn("Using in-memory blob storage, large test data will be lost on restart")
+		return blob.NewMemoryStore(), nil
+	}
+}
+
+// openStorage connects the configured backend. The MongoDB handle is also
+// returned, or nil, for features that need Mongo specifically.
+func openStorage(cfg *config.Config) (storage.Storage, *mongodb.MongoDB, error) {
+	if cfg.Storage == "memory" {
+		slog.Warn("Using in-memory storage, data will be lost on restart")
+		return memory.New(), nil, nil
+	}
+
+	db, err := mongodb.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("Database connected", slog.String("database", cfg.DatabaseName), slog.Bool("transactions", db.SupportsTransactions()))
+	if !db.SupportsTransactions() {
+		slog.Warn("MongoDB is not a replica set, composite writes run without transactions; use portal-cli check to find leftovers")
+	}
+
+	ctx := context.Background()
+	baselined, err := db.BaselineFresh(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if baselined {
+		slog.Info("New database, recorded every migration as applied", slog.Int("version", mongodb.LatestVersion()))
+	}
+	switch cfg.Migrations.Startup {
+	case "apply":
+		applied, err := db.MigrateUp(ctx, 0)
+		for _, migration := range applied {
+			slog.Info("Migration applied", slog.Int("version", migration.Version), slog.String("description", migration.Description))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	case "ignore":
+		if err := db.CheckSchema(ctx); err != nil {
+			slog.Warn("Serving with an outdated schema", slog.String("error", err.Error()))
+		}
+	default:
+		if err := db.CheckSchema(ctx); err != nil {
+			return nil, nil, fmt.Errorf("%v (set migrations.startup to \"apply\" to migrate automatically)", err)
+		}
+	}
+
+	if err := db.EnsureIndexes(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return db, db, nil
+}
+
+// purgeTrash removes content that has been in the trash longer than the
+// retention period, checking every purge interval until ctx is cancelled.
+func purgeTrash(ctx context.Context, storage storage.Storage, cfg config.Deletion) {
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := storage.PurgeDeleted(ctx, time.Now().Add(-cfg.Retention), cfg.Cascades())
+		if err != nil {
+			slog.Error("Purging trash failed", slog.String("error", err.Error()))
+		} else if *result != (types.PurgeResult{}) {
+			slog.Info("Purged trash",
+				slog.Int("contests", result.Contests),
+				slog.Int("questions", result.Questions),
+				slog.Int("test_cases", result.TestCases),
+				slog.Int("submissions", result.Submissions),
+				slog.Int("leaderboard_rows", result.LeaderboardRows),
+				slog.Int("revisions", result.Revisions),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/mongodb"
+)
+
+const checkUsage = "check [-repair] [-grace duration]"
+
+func check(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "remove dangling references and delete orphaned documents")
+	grace := flags.Duration("grace", time.Hour, "ignore documents created more recently than this")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: portal-cli "+checkUsage)
+		return 2
+	}
+
+	storage, err := mongodb.New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.Close(context.Background())
+
+	report, err := storage.CheckConsistency(context.Background(), *repair, *grace)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%d dangling question references, %d dangling test case references, %d orphaned test cases\n",
+		len(report.DanglingQuestions), len(report.DanglingTestCases), len(report.OrphanedTestCases))
+	if report.Problems() > 0 && !report.Repaired {
+		fmt.Fprintln(os.Stderr, "run with -repair to fix them")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/mailer"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/roster"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/mongodb"
+)
+
+func importUsers(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("import-users", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "validate the roster without creating users")
+	invite := flags.Bool("invite", false, "email invitations instead of generating passwords")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: portal-cli import-users [-dry-run] [-invite] roster.csv")
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	rows, err := roster.Parse(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	storage, err := mongodb.New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Duplicate rows are rejected by the unique indexes, so make sure they
+	// exist even if the API has never been started against this database
+	if err := storage.EnsureIndexes(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report := roster.Import(context.Background(), storage, rows, roster.Options{
+		DryRun:    *dryRun,
+		Invite:    *invite,
+		Mailer:    mailer.New(cfg.Mail),
+		InviteURL: cfg.Mail.InviteURL,
+		InviteTTL: cfg.Mail.InviteTTL,
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	fmt.Fprintf(os.Stderr, "%d rows: %d valid, %d created, %d failed\n", report.Total, report.Valid, report.Created, report.Failed)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
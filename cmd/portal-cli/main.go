@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+)
+
+// command is a portal-cli subcommand. run receives the arguments that
+// follow the subcommand name and returns the process exit code.
+type command struct {
+	usage string
+	run   func(cfg *config.Config, args []string) int
+}
+
+var commands = map[string]command{
+	"import-users": {
+		usage: "import-users [-dry-run] [-invite] roster.csv",
+		run:   importUsers,
+	},
+	"migrate": {
+		usage: migrateUsage,
+		run:   migrate,
+	},
+	"check": {
+		usage: checkUsage,
+		run:   check,
+	},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: portal-cli -config <path> <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintln(os.Stderr, "  "+cmd.usage)
+	}
+}
+
+func main() {
+	cfg := config.MustLoad()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	os.Exit(cmd.run(cfg, args[1:]))
+}
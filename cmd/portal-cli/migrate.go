@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/mongodb"
+)
+
+const migrateUsage = "migrate status | up [-to version] | down [-to version] | unlock"
+
+func migrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: portal-cli "+migrateUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	to := flags.Int("to", -1, "target schema version (up: default latest, down: default the previous version)")
+	flags.Parse(args[1:])
+
+	storage, err := mongodb.New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.Close(context.Background())
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		return migrationStatus(ctx, storage)
+
+	case "up":
+		target := *to
+		if target < 0 {
+			target = 0
+		}
+		done, err := storage.MigrateUp(ctx, target)
+		for _, migration := range done {
+			fmt.Printf("applied %d: %s\n", migration.Version, migration.Description)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return 0
+
+	case "down":
+		target := *to
+		if target < 0 {
+			target, err = previousVersion(ctx, storage)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		done, err := storage.MigrateDown(ctx, target)
+		for _, migration := range done {
+			fmt.Printf("reverted %d: %s\n", migration.Version, migration.Description)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("nothing to revert")
+		}
+		return 0
+
+	case "unlock":
+		removed, err := storage.UnlockMigrations(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if removed {
+			fmt.Println("migration lock removed")
+		} else {
+			fmt.Println("migrations were not locked")
+		}
+		return 0
+
+	default:
+		fmt.Fprintln(os.Stderr, "usage: portal-cli "+migrateUsage)
+		return 2
+	}
+}
+
+func migrationStatus(ctx context.Context, storage *mongodb.MongoDB) int {
+	states, err := storage.MigrationStatus(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, state := range states {
+		applied := "pending"
+		if state.Applied {
+			applied = state.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, applied, state.Description)
+	}
+	w.Flush()
+
+	if err := storage.CheckSchema(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// previousVersion is the version below the newest applied migration, so a
+// bare "migrate down" reverts one step.
+func previousVersion(ctx context.Context, storage *mongodb.MongoDB) (int, error) {
+	states, err := storage.MigrationStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	target := 0
+	for i := len(states) - 1; i >= 0; i-- {
+		if states[i].Applied {
+			if i > 0 {
+				target = states[i-1].Version
+			}
+			break
+		}
+	}
+	return target, nil
+}
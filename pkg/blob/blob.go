@@ -0,0 +1,151 @@
+// Package blob keeps large test data outside database documents. Blobs are
+// addressed by the SHA-256 of their content, so identical data is stored
+// once however many test cases use it.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// Store keeps blobs. Put must be safe to call concurrently with the same
+// content. Open and Stat fail with a storage.ErrNotFound error for blobs
+// that are not stored, including malformed hashes.
+type Store interface {
+	Put(ctx context.Context, r io.Reader) (types.BlobRef, error)
+	Open(ctx context.Context, hash string) (io.ReadCloser, error)
+	Stat(ctx context.Context, hash string) (types.BlobRef, error)
+}
+
+// Hash returns the address of data.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidHash reports whether hash is a lower-case hex SHA-256.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// NotFound is the error for a blob that is not stored.
+func NotFound(hash string) error {
+	return storage.NotFound("no blob found with hash %s", hash)
+}
+
+// Text returns test data as the text fed to or expected from a program:
+// the blob ref points at if set, otherwise the inline value, with
+// structured values as JSON.
+func Text(ctx context.Context, store Store, inline interface{}, ref *types.BlobRef) (string, error) {
+	if ref == nil {
+		return inlineText(inline), nil
+	}
+	rc, err := store.Open(ctx, ref.Hash)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var b strings.Builder
+	b.Grow(int(ref.Size))
+	if _, err := io.Copy(&b, rc); err != nil {
+		return "", fmt.Errorf("reading blob %s: %v", ref.Hash, err)
+	}
+	return b.String(), nil
+}
+
+func inlineText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// Prepare readies a test case to be saved. Each part may be inline or a
+// blob, not both; blob refs must name stored blobs and get their size
+// filled in, and inline data longer than inlineLimit bytes is moved into
+// the store.
+func Prepare(ctx context.Context, store Store, testCase *types.TestCase, inlineLimit int) error {
+	for _, part := range []struct {
+		name   string
+		inline *interface{}
+		ref    **types.BlobRef
+	}{
+		{"input", &testCase.Input, &testCase.InputBlob},
+		{"expected_output", &testCase.ExpectedOutput, &testCase.ExpectedOutputBlob},
+	} {
+		if *part.ref != nil {
+			if *part.inline != nil {
+				return storage.Validation("%s and %s_blob cannot both be given", part.name, part.name)
+			}
+			ref, err := store.Stat(ctx, (*part.ref).Hash)
+			if err != nil {
+				return err
+			}
+			*part.ref = &ref
+			continue
+		}
+		if *part.inline == nil {
+			continue
+		}
+		text := inlineText(*part.inline)
+		if len(text) <= inlineLimit {
+			continue
+		}
+		ref, err := store.Put(ctx, strings.NewReader(text))
+		if err != nil {
+			return fmt.Errorf("storing %s: %v", part.name, err)
+		}
+		*part.inline, *part.ref = nil, &ref
+	}
+	return nil
+}
+
+// HashingReader hashes and counts what is read through it, so a Store can
+// address a blob while streaming it in.
+type HashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+}
+
+func NewHashingReader(r io.Reader) *HashingReader {
+	return &HashingReader{r: r, h: sha256.New()}
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.h.Write(p[:n])
+	hr.n += int64(n)
+	return n, err
+}
+
+// Ref returns the reference of everything read so far.
+func (hr *HashingReader) Ref() types.BlobRef {
+	return types.BlobRef{Hash: hex.EncodeToString(hr.h.Sum(nil)), Size: hr.n}
+}
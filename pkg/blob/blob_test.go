@@ -0,0 +1,54 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+func TestPrepare(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	big := strings.Repeat("9", 100)
+	stored, err := store.Put(ctx, strings.NewReader("stored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCase := types.TestCase{Input: big, ExpectedOutput: "small"}
+	if err := Prepare(ctx, store, &testCase, 10); err != nil {
+		t.Fatal(err)
+	}
+	if testCase.Input != nil || testCase.InputBlob == nil || testCase.InputBlob.Hash != Hash([]byte(big)) || testCase.InputBlob.Size != 100 {
+		t.Errorf("large input was not moved to a blob: %+v", testCase)
+	}
+	if testCase.ExpectedOutput != "small" || testCase.ExpectedOutputBlob != nil {
+		t.Errorf("small output was moved: %+v", testCase)
+	}
+	if text, err := Text(ctx, store, testCase.Input, testCase.InputBlob); err != nil || text != big {
+		t.Errorf("Text = %q, %v", text, err)
+	}
+
+	// Refs are checked and sized
+	testCase = types.TestCase{ExpectedOutputBlob: &types.BlobRef{Hash: stored.Hash}}
+	if err := Prepare(ctx, store, &testCase, 10); err != nil || testCase.ExpectedOutputBlob.Size != 6 {
+		t.Errorf("Prepare with stored ref = %+v, %v", testCase.ExpectedOutputBlob, err)
+	}
+	testCase = types.TestCase{InputBlob: &types.BlobRef{Hash: Hash([]byte("missing"))}}
+	if err := Prepare(ctx, store, &testCase, 10); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Prepare with missing ref = %v, want ErrNotFound", err)
+	}
+	testCase = types.TestCase{Input: "1", InputBlob: &stored}
+	if err := Prepare(ctx, store, &testCase, 10); !errors.Is(err, storage.ErrValidation) {
+		t.Errorf("Prepare with both = %v, want ErrValidation", err)
+	}
+
+	// Structured inline data is sent as JSON
+	if text, _ := Text(ctx, store, []interface{}{1, 2}, nil); text != "[1,2]" {
+		t.Errorf("Text of structured data = %q", text)
+	}
+}
@@ -0,0 +1,99 @@
+// Package blobtest is a conformance suite every blob.Store must pass.
+package blobtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+)
+
+func Run(t *testing.T, newStore func(t *testing.T) blob.Store) {
+	ctx := context.Background()
+
+	t.Run("PutOpenStat", func(t *testing.T) {
+		s := newStore(t)
+		data := strings.Repeat("1 2 3\n", 100000)
+
+		ref, err := s.Put(ctx, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.Hash != blob.Hash([]byte(data)) || ref.Size != int64(len(data)) {
+			t.Errorf("ref = %+v", ref)
+		}
+
+		stat, err := s.Stat(ctx, ref.Hash)
+		if err != nil || stat != ref {
+			t.Errorf("Stat = %+v, %v, want %+v", stat, err, ref)
+		}
+
+		rc, err := s.Open(ctx, ref.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		got, err := io.ReadAll(rc)
+		if err != nil || string(got) != data {
+			t.Errorf("Open read %d bytes, %v", len(got), err)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		s := newStore(t)
+		ref, err := s.Put(ctx, strings.NewReader(""))
+		if err != nil || ref.Size != 0 {
+			t.Fatalf("Put empty = %+v, %v", ref, err)
+		}
+		if _, err := s.Stat(ctx, ref.Hash); err != nil {
+			t.Errorf("Stat empty: %v", err)
+		}
+	})
+
+	t.Run("Dedup", func(t *testing.T) {
+		s := newStore(t)
+		var wg sync.WaitGroup
+		refs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Put(ctx, strings.NewReader("same"))
+				refs <- err
+			}()
+		}
+		wg.Wait()
+		close(refs)
+		for err := range refs {
+			if err != nil {
+				t.Errorf("concurrent Put: %v", err)
+			}
+		}
+
+		rc, err := s.Open(ctx, blob.Hash([]byte("same")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		if got, _ := io.ReadAll(rc); string(got) != "same" {
+			t.Errorf("deduplicated blob = %q", got)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s := newStore(t)
+		for _, hash := range []string{blob.Hash([]byte("missing")), "../../etc/passwd", ""} {
+			if _, err := s.Stat(ctx, hash); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Stat(%q) = %v, want ErrNotFound", hash, err)
+			}
+			if _, err := s.Open(ctx, hash); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Open(%q) = %v, want ErrNotFound", hash, err)
+			}
+		}
+	})
+}
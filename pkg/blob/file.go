@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// FileStore keeps blobs as files under a directory, each at
+// <root>/<first two hex digits>/<hash>. Uploads are written to <root>/tmp
+// and renamed into place, so a blob is either whole or absent.
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, fmt.Errorf("creating blob directory: %v", err)
+	}
+	return &FileStore{root: root}, nil
+}
+
+func (s *FileStore) path(hash string) string {
+	return filepath.Join(s.root, hash[:2], hash)
+}
+
+func (s *FileStore) Put(ctx context.Context, r io.Reader) (types.BlobRef, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return types.BlobRef{}, err
+	}
+	// Harmless once renamed into place
+	defer os.Remove(tmp.Name())
+
+	hr := NewHashingReader(r)
+	if _, err := io.Copy(tmp, hr); err != nil {
+		tmp.Close()
+		return types.BlobRef{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return types.BlobRef{}, err
+	}
+
+	ref := hr.Ref()
+	if _, err := os.Stat(s.path(ref.Hash)); err == nil {
+		return ref, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path(ref.Hash)), 0o755); err != nil {
+		return types.BlobRef{}, err
+	}
+	if err := os.Rename(tmp.Name(), s.path(ref.Hash)); err != nil {
+		return types.BlobRef{}, err
+	}
+	return ref, nil
+}
+
+func (s *FileStore) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if !ValidHash(hash) {
+		return nil, NotFound(hash)
+	}
+	f, err := os.Open(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NotFound(hash)
+	}
+	return f, err
+}
+
+func (s *FileStore) Stat(ctx context.Context, hash string) (types.BlobRef, error) {
+	if !ValidHash(hash) {
+		return types.BlobRef{}, NotFound(hash)
+	}
+	info, err := os.Stat(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.BlobRef{}, NotFound(hash)
+	}
+	if err != nil {
+		return types.BlobRef{}, err
+	}
+	return types.BlobRef{Hash: hash, Size: info.Size()}, nil
+}
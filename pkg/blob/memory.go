@@ -0,0 +1,58 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// MemoryStore keeps blobs in process memory, for the memory storage
+// backend and tests. Blobs are lost on restart.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, r io.Reader) (types.BlobRef, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return types.BlobRef{}, err
+	}
+	ref := types.BlobRef{Hash: Hash(data), Size: int64(len(data))}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blobs[ref.Hash]; !ok {
+		s.blobs[ref.Hash] = data
+	}
+	return ref, nil
+}
+
+func (s *MemoryStore) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.blobs[hash]
+	if !ok {
+		return nil, NotFound(hash)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStore) Stat(ctx context.Context, hash string) (types.BlobRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.blobs[hash]
+	if !ok {
+		return types.BlobRef{}, NotFound(hash)
+	}
+	return types.BlobRef{Hash: hash, Size: int64(len(data))}, nil
+}
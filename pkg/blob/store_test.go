@@ -0,0 +1,24 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob/blobtest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		return blob.NewMemoryStore()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		s, err := blob.NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -13,12 +14,105 @@ type HTTPServer struct {
 	Addr string `yaml:"address" env-default:"localhost:8000"`
 }
 
+// DatabaseTimeouts bounds each kind of database operation. Requests are
+// also cancelled when the client disconnects or the server shuts down.
+type DatabaseTimeouts struct {
+	Connect   time.Duration `yaml:"connect" env-default:"10s"`
+	Read      time.Duration `yaml:"read" env-default:"5s"`
+	Write     time.Duration `yaml:"write" env-default:"5s"`
+	Aggregate time.Duration `yaml:"aggregate" env-default:"10s"`
+}
+
+type RateLimit struct {
+	Store                string        `yaml:"store" env-default:"memory"`
+	TrustProxy           bool          `yaml:"trust_proxy"`
+	LoginIPInterval      time.Duration `yaml:"login_ip_interval" env-default:"6s"`
+	LoginIPBurst         int           `yaml:"login_ip_burst" env-default:"20"`
+	LoginAccountInterval time.Duration `yaml:"login_account_interval" env-default:"30s"`
+	LoginAccountBurst    int           `yaml:"login_account_burst" env-default:"10"`
+	SignupIPInterval     time.Duration `yaml:"signup_ip_interval" env-default:"2m"`
+	SignupIPBurst        int           `yaml:"signup_ip_burst" env-default:"5"`
+	RunInterval          time.Duration `yaml:"run_interval" env-default:"10s"`
+	RunBurst             int           `yaml:"run_burst" env-default:"6"`
+	LockoutThreshold     int           `yaml:"lockout_threshold" env-default:"5"`
+	LockoutDuration      time.Duration `yaml:"lockout_duration" env-default:"1m"`
+	LockoutMaxDuration   time.Duration `yaml:"lockout_max_duration" env-default:"1h"`
+	LockoutFailureWindow time.Duration `yaml:"lockout_failure_window" env-default:"15m"`
+}
+
+// Migrations controls what the API does on startup when the database
+// schema is behind this build: "check" refuses to start, "apply" runs the
+// pending migrations and "ignore" logs a warning and serves anyway. An
+// empty database is taken to be at the latest schema.
+type Migrations struct {
+	Startup string `yaml:"startup" env-default:"check"`
+}
+
+// Deletion controls what happens to deleted contests, questions and test
+// cases. They go to a trash admins can restore from, and the purge job
+// removes anything trashed longer than Retention ago every PurgeInterval.
+// Cascade is "all" to delete a contest's questions and their test cases
+// along with it, and on purge the submissions and leaderboard rows recorded
+// against them, or "none" to delete only the item itself.
+type Deletion struct {
+	Cascade       string        `yaml:"cascade" env-default:"all"`
+	Retention     time.Duration `yaml:"retention" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// Cascades reports whether deletes take contained content with them.
+func (d Deletion) Cascades() bool {
+	return d.Cascade == "all"
+}
+
+// Blobs stores large test data outside database documents. Backend is
+// "gridfs" (mongodb storage only), "filesystem" under Path, or "memory";
+// empty picks gridfs for mongodb storage and memory otherwise. Test data
+// longer than InlineLimit bytes is moved into blobs, and a single upload
+// may be at most MaxSize bytes.
+type Blobs struct {
+	Backend     string `yaml:"backend"`
+	Path        string `yaml:"path" env-default:"data/blobs"`
+	InlineLimit int    `yaml:"inline_limit" env-default:"65536"`
+	MaxSize     int64  `yaml:"max_size" env-default:"268435456"`
+}
+
+// Submissions bounds the load contestants can put on the judge. Each user
+// may submit once per Cooldown, code may be at most MaxCodeSize bytes, and
+// while MaxInFlight submissions and runs are being judged by an instance it
+// refuses more with 429. Zero turns a bound off.
+type Submissions struct {
+	Cooldown    time.Duration `yaml:"cooldown" env-default:"10s"`
+	MaxCodeSize int           `yaml:"max_code_size" env-default:"65536"`
+	MaxInFlight int           `yaml:"max_in_flight" env-default:"32"`
+}
+
+type Mail struct {
+	Host      string        `yaml:"host"`
+	Port      int           `yaml:"port" env-default:"587"`
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password"`
+	From      string        `yaml:"from" env-default:"no-reply@bdcoe.portal"`
+	InviteURL string        `yaml:"invite_url"`
+	InviteTTL time.Duration `yaml:"invite_ttl" env-default:"168h"`
+}
+
 type Config struct {
 	Env    string `yaml:"env" env:"ENV" env-required:"true" env-default:"production"`
-    DatabaseURL string `yaml:"DatabaseURL" env-required:"true"`
-    DatabaseName string `yaml:"DatabaseName" env-required:"true"`
+	// Storage selects the backend: "mongodb", or "memory" for local
+	// development without a database. Memory data is lost on restart.
+	Storage      string `yaml:"storage" env-default:"mongodb"`
+    DatabaseURL string `yaml:"DatabaseURL"`
+    DatabaseName string `yaml:"DatabaseName"`
+	DatabaseTimeouts DatabaseTimeouts `yaml:"DatabaseTimeouts"`
+	Migrations   Migrations `yaml:"migrations"`
 	JwtSecret    string `yaml:"JwtSecret"`
 	HTTPServer `yaml:"http_server"`
+	RateLimit  RateLimit `yaml:"rate_limit"`
+	Mail       Mail      `yaml:"mail"`
+	Deletion   Deletion  `yaml:"deletion"`
+	Blobs      Blobs     `yaml:"blobs"`
+	Submissions Submissions `yaml:"submissions"`
 }
 
 
@@ -48,5 +142,52 @@ func MustLoad() *Config{
 		log.Fatalf("failed to load config: %v", err.Error())
 	}
 
+	switch cfg.Storage {
+	case "mongodb":
+		if cfg.DatabaseURL == "" || cfg.DatabaseName == "" {
+			log.Fatalf("DatabaseURL and DatabaseName are required for mongodb storage")
+		}
+	case "memory":
+	default:
+		log.Fatalf("unknown storage %q", cfg.Storage)
+	}
+
+	switch cfg.Migrations.Startup {
+	case "check", "apply", "ignore":
+	default:
+		log.Fatalf("unknown migrations.startup %q", cfg.Migrations.Startup)
+	}
+
+	switch cfg.Deletion.Cascade {
+	case "all", "none":
+	default:
+		log.Fatalf("unknown deletion.cascade %q", cfg.Deletion.Cascade)
+	}
+	if cfg.Deletion.Retention < 0 {
+		log.Fatalf("deletion.retention must not be negative")
+	}
+	if cfg.Deletion.PurgeInterval <= 0 {
+		log.Fatalf("deletion.purge_interval must be positive")
+	}
+
+	if cfg.Blobs.Backend == "" {
+		cfg.Blobs.Backend = "memory"
+		if cfg.Storage == "mongodb" {
+			cfg.Blobs.Backend = "gridfs"
+		}
+	}
+	switch cfg.Blobs.Backend {
+	case "gridfs":
+		if cfg.Storage != "mongodb" {
+			log.Fatalf("blobs.backend gridfs requires mongodb storage")
+		}
+	case "filesystem", "memory":
+	default:
+		log.Fatalf("unknown blobs.backend %q", cfg.Blobs.Backend)
+	}
+	if cfg.Blobs.InlineLimit < 0 || cfg.Blobs.MaxSize <= 0 {
+		log.Fatalf("blobs.inline_limit and blobs.max_size must be positive")
+	}
+
 	return &cfg
 }
\ No newline at end of file
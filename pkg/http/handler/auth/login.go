@@ -1,18 +1,23 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
 	"fmt"
-	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"strings"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/ratelimit"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
 )
 
-func Login(storage storage.Storage, secretKey string) http.HandlerFunc {
+func Login(storage storage.Storage, secretKey string, limiter *ratelimit.Limiter, accountLimit ratelimit.Limit) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         var loginReq types.LoginRequest
 
@@ -21,23 +26,51 @@ func Login(storage storage.Storage, secretKey string) http.HandlerFunc {
             return
         }
 
-        if err := validator.New().Struct(loginReq); err != nil {
-            validateErrs := err.(validator.ValidationErrors)
-            response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+        if err := validation.Struct(loginReq); err != nil {
+            response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
             return
         }
 
-        user, err := storage.GetUserByEmail(loginReq.Email)
+        accountKey := "login:account:" + strings.ToLower(loginReq.Email)
+        ip, _ := r.Context().Value(middleware.ClientIPKey).(string)
+
+        lockedFor, err := limiter.LockedFor(r.Context(), accountKey)
+        if err != nil {
+            slog.Error("lockout check failed", slog.String("error", err.Error()))
+        }
+        if lockedFor > 0 {
+            middleware.TooManyRequests(w, lockedFor)
+            return
+        }
+
+        result, err := limiter.Allow(r.Context(), accountKey, accountLimit)
         if err != nil {
-            response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(err))
+            slog.Error("rate limiter unavailable", slog.String("error", err.Error()))
+        }
+        if !result.Allowed {
+            middleware.TooManyRequests(w, result.RetryAfter)
+            return
+        }
+
+        user, err := storage.GetUserByEmail(r.Context(), loginReq.Email)
+        if err != nil && response.StatusForError(err) != http.StatusNotFound {
+            response.WriteError(w, err)
+            return
+        }
+        if err != nil || user.Password != loginReq.Password {
+            loginFailed(r.Context(), w, storage, limiter, accountKey, loginReq.Email, ip)
             return
         }
 
-        if user.Password != loginReq.Password {
-            response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("invalid credentials")))
+        if user.Disabled {
+            response.WriteJson(w, http.StatusForbidden, response.GeneralError(fmt.Errorf("account is disabled")))
             return
         }
 
+        if err := limiter.Reset(r.Context(), accountKey); err != nil {
+            slog.Error("failed to reset login failures", slog.String("error", err.Error()))
+        }
+
         // Create JWT token
         token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
             "user_id": user.ID,
@@ -68,4 +101,33 @@ func Login(storage storage.Storage, secretKey string) http.HandlerFunc {
             TokenType:   "Bearer",
         })
     }
-}
\ No newline at end of file
+}
+
+// loginFailed counts a failed attempt against the account and, when that
+// failure trips a lockout, records it in the audit log and tells the
+// client when it may retry.
+func loginFailed(ctx context.Context, w http.ResponseWriter, storage storage.Storage, limiter *ratelimit.Limiter, accountKey, email, ip string) {
+    lockout, err := limiter.Fail(ctx, accountKey)
+    if err != nil {
+        slog.Error("failed to record login failure", slog.String("error", err.Error()))
+    }
+
+    if !lockout.Locked {
+        response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("invalid credentials")))
+        return
+    }
+
+    slog.Warn("account locked", slog.String("email", email), slog.String("ip", ip), slog.Time("until", lockout.Until))
+    entry := types.AuditEntry{
+        Action:    types.AuditAccountLocked,
+        Subject:   email,
+        IP:        ip,
+        Details:   fmt.Sprintf("locked until %s after repeated failed logins (lockout #%d)", lockout.Until.Format(time.RFC3339), lockout.Lockouts),
+        CreatedAt: time.Now(),
+    }
+    if err := storage.CreateAuditEntry(ctx, entry); err != nil {
+        slog.Error("failed to write audit entry", slog.String("error", err.Error()))
+    }
+
+    middleware.TooManyRequests(w, time.Until(lockout.Until))
+}
@@ -2,14 +2,19 @@ package contest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func CreateContest(storage storage.Storage) http.HandlerFunc {
@@ -20,9 +25,19 @@ func CreateContest(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		contestId, err := storage.CreateContest(contestReq)
+		if err := validation.Struct(contestReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		contestReq.CreatedBy = primitive.NilObjectID
+		if userID, ok := middleware.UserIDFromContext(r.Context()); ok {
+			contestReq.CreatedBy, _ = primitive.ObjectIDFromHex(userID)
+		}
+
+		contestId, err := storage.CreateContest(r.Context(), contestReq)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -30,7 +45,9 @@ func CreateContest(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
-func DeleteContestById(storage storage.Storage) http.HandlerFunc {
+// DeleteContestById moves a contest to the trash, along with its questions
+// and their test cases when cascade is set.
+func DeleteContestById(storage storage.Storage, cascade bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		id := path[strings.LastIndex(path, "/")+1:]
@@ -40,8 +57,8 @@ func DeleteContestById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteContestById(id); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		if err := storage.DeleteContestById(r.Context(), id, cascade); err != nil {
+			response.WriteError(w, err)
 			return
 		}
 
@@ -65,9 +82,14 @@ func EditContestById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.EditContestById(id, contest); 
+		if err := validation.Partial(contest); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if err := storage.EditContestById(r.Context(), id, contest); 
 		err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -78,9 +100,9 @@ func EditContestById(storage storage.Storage) http.HandlerFunc {
 
 func GetAllContests(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		contests, err := storage.GetAllContests()
+		contests, err := storage.GetAllContests(r.Context())
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 		
@@ -98,13 +120,9 @@ func GetContestById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		contest, err := storage.GetContestById(id)
+		contest, err := storage.GetContestById(r.Context(), id)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				response.WriteJson(w, http.StatusNotFound, response.GeneralError(fmt.Errorf("contest not found")))
-				return
-			}
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 		response.WriteJson(w, http.StatusOK, contest)
@@ -134,9 +152,19 @@ func AddQuestionToContest(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		questionId, err := storage.AddQuestionToContest(contestId, question)
+		if err := validation.Struct(question); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if userID, ok := middleware.UserIDFromContext(r.Context()); ok {
+			question.CreatedBy, _ = primitive.ObjectIDFromHex(userID)
+		}
+		question.CreatedAt = time.Now()
+
+		questionId, err := storage.AddQuestionToContest(r.Context(), contestId, question)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -144,6 +172,8 @@ func AddQuestionToContest(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
+// DeleteQuestionFromContestById removes a question from a contest. The
+// question stays in the bank; DELETE /api/question/{id} trashes it.
 func DeleteQuestionFromContestById(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -163,11 +193,86 @@ func DeleteQuestionFromContestById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteQuestionFromContestById(contestId, questionId); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		if err := storage.DeleteQuestionFromContestById(r.Context(), contestId, questionId); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "question removed from contest successfully"})
+	}
+}
+
+// LinkQuestionToContest adds a question from the bank to a contest. The
+// body, which may be empty, sets its position and points in the contest.
+func LinkQuestionToContest(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placement, ok := decodePlacement(w, r)
+		if !ok {
+			return
+		}
+
+		if err := storage.LinkQuestionToContest(r.Context(), r.PathValue("contestId"), r.PathValue("questionId"), placement); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusCreated, map[string]string{"status": "success", "message": "question added to contest successfully"})
+	}
+}
+
+// UpdateContestProblem moves a question within a contest and replaces its
+// label, colour and points override; omitted fields fall back to the
+// defaults.
+func UpdateContestProblem(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placement, ok := decodePlacement(w, r)
+		if !ok {
+			return
+		}
+
+		if err := storage.UpdateContestProblem(r.Context(), r.PathValue("contestId"), r.PathValue("questionId"), placement); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "contest question updated successfully"})
+	}
+}
+
+// ReorderContestProblems puts a contest's questions in the order given,
+// which must list each of them once.
+func ReorderContestProblems(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var order types.ProblemOrder
+		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 			return
 		}
 
-		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "question deleted from contest successfully"})
+		if err := validation.Struct(order); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if err := storage.ReorderContestProblems(r.Context(), r.PathValue("id"), order.QuestionIDs); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "contest questions reordered successfully"})
+	}
+}
+
+func decodePlacement(w http.ResponseWriter, r *http.Request) (types.ProblemPlacement, bool) {
+	var placement types.ProblemPlacement
+	if err := json.NewDecoder(r.Body).Decode(&placement); err != nil && !errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return placement, false
+	}
+
+	if err := validation.Struct(placement); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+		return placement, false
 	}
+	return placement, true
 }
\ No newline at end of file
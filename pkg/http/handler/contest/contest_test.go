@@ -0,0 +1,146 @@
+package contest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/memory"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+func newRouter() *http.ServeMux {
+	storage := memory.New()
+	router := http.NewServeMux()
+	router.HandleFunc("POST /api/contest", CreateContest(storage))
+	router.HandleFunc("GET /api/contest/{id}", GetContestById(storage))
+	router.HandleFunc("PUT /api/contest/{id}", EditContestById(storage))
+	router.HandleFunc("POST /api/contest/{id}/question", AddQuestionToContest(storage))
+	return router
+}
+
+func do(t *testing.T, router http.Handler, method, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestCreateAndGetContest(t *testing.T) {
+	router := newRouter()
+
+	var created map[string]string
+	status := do(t, router, "POST", "/api/contest", `{
+		"title": "Weekly 1",
+		"description": "First weekly contest",
+		"start_time": "2030-01-01T10:00:00Z",
+		"end_time": "2030-01-01T12:00:00Z"
+	}`, &created)
+	if status != http.StatusCreated || created["contest_id"] == "" {
+		t.Fatalf("create returned %d %v", status, created)
+	}
+	id := created["contest_id"]
+
+	status = do(t, router, "POST", "/api/contest/"+id+"/question", `{
+		"title": "Two Sum",
+		"description": "Add two numbers",
+		"difficulty": "easy"
+	}`, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("add question returned %d %v", status, created)
+	}
+
+	var got struct {
+		ID        string `json:"contest_id"`
+		Title     string `json:"title"`
+		StartTime string `json:"start_time"`
+		Questions []struct {
+			ID    string `json:"question_id"`
+			Title string `json:"title"`
+		} `json:"questions"`
+	}
+	status = do(t, router, "GET", "/api/contest/"+id, "", &got)
+	if status != http.StatusOK {
+		t.Fatalf("get returned %d %+v", status, got)
+	}
+	if got.ID != id || got.Title != "Weekly 1" || got.StartTime != "2030-01-01T10:00:00Z" {
+		t.Errorf("contest = %+v", got)
+	}
+	if len(got.Questions) != 1 || got.Questions[0].ID != created["question_id"] || got.Questions[0].Title != "Two Sum" {
+		t.Errorf("questions = %+v", got.Questions)
+	}
+}
+
+func TestCreateContestValidation(t *testing.T) {
+	router := newRouter()
+
+	var resp response.Response
+	status := do(t, router, "POST", "/api/contest", `{
+		"title": "Backwards",
+		"description": "Ends before it starts",
+		"start_time": "2030-01-01T12:00:00Z",
+		"end_time": "2030-01-01T10:00:00Z"
+	}`, &resp)
+	if status != http.StatusBadRequest || resp.Code != response.CodeValidationFailed {
+		t.Fatalf("got %d %+v, want 400 %s", status, resp, response.CodeValidationFailed)
+	}
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "end_time" {
+		t.Errorf("fields = %+v, want end_time", resp.Fields)
+	}
+}
+
+func TestNegativeMaxAttempts(t *testing.T) {
+	router := newRouter()
+
+	var created map[string]string
+	body := `{
+		"title": "Weekly 1",
+		"description": "First weekly contest",
+		"start_time": "2030-01-01T10:00:00Z",
+		"end_time": "2030-01-01T12:00:00Z"%s
+	}`
+	if status := do(t, router, "POST", "/api/contest", fmt.Sprintf(body, ""), &created); status != http.StatusCreated {
+		t.Fatalf("create returned %d %v", status, created)
+	}
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{"POST", "/api/contest", fmt.Sprintf(body, `, "max_attempts": -1`)},
+		{"PUT", "/api/contest/" + created["contest_id"], `{"max_attempts": -1}`},
+	}
+	for _, tt := range tests {
+		var resp response.Response
+		status := do(t, router, tt.method, tt.path, tt.body, &resp)
+		if status != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "max_attempts" {
+			t.Errorf("%s %s = %d %+v, want 400 on max_attempts", tt.method, tt.path, status, resp)
+		}
+	}
+}
+
+func TestGetContestErrors(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/contest/not-an-id", http.StatusBadRequest},
+		{"/api/contest/000000000000000000000000", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		var resp response.Response
+		if status := do(t, router, "GET", tt.path, "", &resp); status != tt.want {
+			t.Errorf("GET %s = %d %+v, want %d", tt.path, status, resp, tt.want)
+		}
+	}
+}
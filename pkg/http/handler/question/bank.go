@@ -0,0 +1,89 @@
+package question
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListQuestions lists the question bank, newest first. tag may be repeated
+// or comma separated; a question must carry all of them.
+func ListQuestions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		filter := types.QuestionFilter{
+			Difficulty: query.Get("difficulty"),
+			Author:     query.Get("author"),
+			Page:       1,
+			Limit:      defaultPageSize,
+		}
+		for _, tags := range query["tag"] {
+			for _, tag := range strings.Split(tags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					filter.Tags = append(filter.Tags, tag)
+				}
+			}
+		}
+
+		if page := query.Get("page"); page != "" {
+			n, err := strconv.Atoi(page)
+			if err != nil || n < 1 {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("page must be a positive integer")))
+				return
+			}
+			filter.Page = n
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 || n > maxPageSize {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("limit must be between 1 and %d", maxPageSize)))
+				return
+			}
+			filter.Limit = n
+		}
+
+		questions, err := storage.ListQuestions(r.Context(), filter)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, questions)
+	}
+}
+
+// DeleteQuestionById moves a question from the bank to the trash, along
+// with its test cases when cascade is set. A question contests still use
+// is refused unless the request passes force=true.
+func DeleteQuestionById(storage storage.Storage, cascade bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		force := false
+		if value := r.URL.Query().Get("force"); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("force must be true or false")))
+				return
+			}
+			force = b
+		}
+
+		if err := storage.DeleteQuestionById(r.Context(), r.PathValue("id"), cascade, force); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "question deleted successfully"})
+	}
+}
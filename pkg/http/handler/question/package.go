@@ -0,0 +1,234 @@
+package question
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problemcheck"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problempkg"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportQuestion creates a question and its test cases from a Kattis or
+// Polygon package zip, sent either as the raw request body or as the
+// "file" field of a multipart form. ?format= names the format, which is
+// otherwise detected, and ?dry_run=true only validates. The report lists
+// every problem found; a package with errors is rejected with 422.
+func ImportQuestion(storage storage.Storage, blobs blob.Store, cfg config.Blobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format, err := problempkg.ParseFormat(query.Get("format"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		dryRun, err := boolParam(query.Get("dry_run"), "dry_run")
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		data, ok := readArchive(w, r)
+		if !ok {
+			return
+		}
+
+		problem, report, err := problempkg.Read(bytes.NewReader(data), int64(len(data)), format)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		report.DryRun = dryRun
+		if !report.Valid {
+			response.WriteJson(w, http.StatusUnprocessableEntity, report)
+			return
+		}
+		if dryRun {
+			response.WriteJson(w, http.StatusOK, report)
+			return
+		}
+
+		question := problem.Question
+		if userID, ok := middleware.UserIDFromContext(r.Context()); ok {
+			question.CreatedBy, _ = primitive.ObjectIDFromHex(userID)
+		}
+		question.CreatedAt = time.Now()
+		for i := range problem.TestCases {
+			problem.TestCases[i].CreatedAt = question.CreatedAt
+			if err := blob.Prepare(r.Context(), blobs, &problem.TestCases[i], cfg.InlineLimit); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+
+		report.QuestionID, err = storage.ImportQuestion(r.Context(), question, problem.TestCases)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteJson(w, http.StatusCreated, report)
+	}
+}
+
+// ExportQuestion downloads a question and all its test cases as a package
+// zip, in the format named by ?format= (kattis unless given).
+func ExportQuestion(storage storage.Storage, blobs blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, err := problempkg.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if format == "" {
+			format = problempkg.FormatKattis
+		}
+
+		question, err := storage.GetQuestionById(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		for i := range question.TestCases {
+			tc := &question.TestCases[i]
+			if tc.Input, err = blob.Text(r.Context(), blobs, tc.Input, tc.InputBlob); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+			if tc.ExpectedOutput, err = blob.Text(r.Context(), blobs, tc.ExpectedOutput, tc.ExpectedOutputBlob); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+
+		// Build the zip first so a failure can still be reported as JSON
+		var buf bytes.Buffer
+		if err := problempkg.Write(&buf, question, format); err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, problempkg.Slug(question.Title), format))
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(http.StatusOK)
+		buf.WriteTo(w)
+	}
+}
+
+// UploadTestCaseArchive adds the tests in a zip of NN.in/NN.out pairs, and
+// an optional manifest, to a question: appended after its current test
+// cases, or with ?replace=true in place of them, which go to the trash.
+// The archive is sent like a package to ImportQuestion, and ?dry_run=true
+// only validates it. The question's input validator, if any, must accept
+// every input.
+func UploadTestCaseArchive(storage storage.Storage, blobs blob.Store, cfg config.Blobs, judgeClient *judge0.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		dryRun, err := boolParam(query.Get("dry_run"), "dry_run")
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		replace, err := boolParam(query.Get("replace"), "replace")
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		id := r.PathValue("id")
+		// Fail before reading a large archive for a question that isn't there
+		if _, err := storage.GetQuestionById(r.Context(), id); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		data, ok := readArchive(w, r)
+		if !ok {
+			return
+		}
+		testCases, report, err := problempkg.ReadTests(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		report.DryRun, report.Replace = dryRun, replace
+		if !report.Valid {
+			response.WriteJson(w, http.StatusUnprocessableEntity, report)
+			return
+		}
+		if err := problemcheck.ValidateTestCases(r.Context(), storage, blobs, judgeClient, id, testCases); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if dryRun {
+			response.WriteJson(w, http.StatusOK, report)
+			return
+		}
+
+		now := time.Now()
+		for i := range testCases {
+			testCases[i].CreatedAt = now
+			if err := blob.Prepare(r.Context(), blobs, &testCases[i], cfg.InlineLimit); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+
+		author, _ := middleware.UserIDFromContext(r.Context())
+		report.TestCaseIDs, err = storage.AddTestCasesToQuestion(r.Context(), id, testCases, replace, author)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteJson(w, http.StatusCreated, report)
+	}
+}
+
+// readArchive reads an uploaded zip, sent either as the raw request body or
+// as the "file" field of a multipart form, writing the error response
+// itself when it can't.
+func readArchive(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	// Multipart overhead comes on top of the archive itself
+	r.Body = http.MaxBytesReader(w, r.Body, problempkg.MaxArchiveSize+1<<20)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("archive file is required: %v", err)))
+			return nil, false
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil || len(data) > problempkg.MaxArchiveSize {
+		response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("archive is larger than %d MiB", problempkg.MaxArchiveSize>>20)))
+		return nil, false
+	}
+	return data, true
+}
+
+func boolParam(value, name string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
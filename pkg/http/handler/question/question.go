@@ -1,14 +1,23 @@
 package question
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problemcheck"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func CreateQuestion(storage storage.Storage) http.HandlerFunc {
@@ -19,9 +28,19 @@ func CreateQuestion(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		questionId, err := storage.CreateQuestion(questionReq)
+		if err := validation.Struct(questionReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if userID, ok := middleware.UserIDFromContext(r.Context()); ok {
+			questionReq.CreatedBy, _ = primitive.ObjectIDFromHex(userID)
+		}
+		questionReq.CreatedAt = time.Now()
+
+		questionId, err := storage.CreateQuestion(r.Context(), questionReq)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -29,7 +48,9 @@ func CreateQuestion(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
-func GetQuestionById(storage storage.Storage) http.HandlerFunc {
+// GetQuestionById returns a question in full to admins. Everyone else gets
+// its public test cases as samples and a count of the hidden ones.
+func GetQuestionById(storage storage.Storage, blobs blob.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		id := path[strings.LastIndex(path, "/")+1:]
@@ -38,17 +59,70 @@ func GetQuestionById(storage storage.Storage) http.HandlerFunc {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("contest id is required")))
 			return
 		}
-		fmt.Println("Question ID: ", id)
-		question, err := storage.GetQuestionById(id)
+		question, err := storage.GetQuestionById(r.Context(), id)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if middleware.IsAdmin(r.Context()) {
+			response.WriteJson(w, http.StatusOK, question)
+			return
+		}
+
+		view, err := questionView(r.Context(), blobs, question)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
-		response.WriteJson(w, http.StatusOK, question)
+		response.WriteJson(w, http.StatusOK, view)
 	}
 }
 
-func AddTestCaseToQuestion(storage storage.Storage) http.HandlerFunc {
+// questionView leaves out what contestants must not see: private test
+// data and the checker.
+func questionView(ctx context.Context, blobs blob.Store, question *types.QuestionDetail) (*types.QuestionView, error) {
+	view := &types.QuestionView{
+		ID:             question.ID,
+		Title:          question.Title,
+		Description:    question.Description,
+		Statement:      question.Statement,
+		StatementHTML:  question.StatementHTML,
+		Difficulty:     question.Difficulty,
+		Tags:           question.Tags,
+		Points:         question.Points,
+		Cpu_time_limit: question.Cpu_time_limit,
+		Memory_limit:   question.Memory_limit,
+		MaxAttempts:    question.MaxAttempts,
+		Samples:        []types.Sample{},
+	}
+	for _, tc := range question.TestCases {
+		if tc.Visibility != types.VisibilityPublic {
+			view.HiddenTestCases++
+			continue
+		}
+		sample := types.Sample{TestCaseID: tc.ID}
+		var err error
+		sample.Input, err = blob.Text(ctx, blobs, tc.Input, tc.InputBlob)
+		if err == nil {
+			sample.ExpectedOutput, err = blob.Text(ctx, blobs, tc.ExpectedOutput, tc.ExpectedOutputBlob)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Explanations follow the public test cases in order
+		n := len(view.Samples)
+		if question.Statement != nil && n < len(question.Statement.SampleExplanations) {
+			sample.Explanation = question.Statement.SampleExplanations[n]
+		}
+		if question.StatementHTML != nil && n < len(question.StatementHTML.SampleExplanations) {
+			sample.ExplanationHTML = question.StatementHTML.SampleExplanations[n]
+		}
+		view.Samples = append(view.Samples, sample)
+	}
+	return view, nil
+}
+
+func AddTestCaseToQuestion(storage storage.Storage, blobs blob.Store, cfg config.Blobs, judgeClient *judge0.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		parts := strings.Split(path, "/")
@@ -57,9 +131,7 @@ func AddTestCaseToQuestion(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 		questionId := parts[3]
-		
-		fmt.Printf("Extracted question ID: %s\n", questionId)
-		
+
 		if questionId == "" {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("question id is required")))
 			return
@@ -71,9 +143,27 @@ func AddTestCaseToQuestion(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		testCaseId, err := storage.AddTestCaseToQuestion(questionId, testCase)
+		if err := validation.Struct(testCase); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		// Only generation records provenance
+		testCase.Generated = nil
+
+		if err := problemcheck.ValidateTestCases(r.Context(), storage, blobs, judgeClient, questionId, []types.TestCase{testCase}); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		if err := blob.Prepare(r.Context(), blobs, &testCase, cfg.InlineLimit); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		author, _ := middleware.UserIDFromContext(r.Context())
+		testCaseId, err := storage.AddTestCaseToQuestion(r.Context(), questionId, testCase, author)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -91,18 +181,16 @@ func DeleteTestCaseFromQuestionById(storage storage.Storage) http.HandlerFunc {
 		}
 		questionId := parts[3]
 		testCaseId := parts[5]
-		
-		fmt.Printf("Extracted question ID: %s\n", questionId)
-		fmt.Printf("Extracted test case ID: %s\n", testCaseId)
-		
+
 		if questionId == "" || testCaseId == "" {
 			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("question id and test case id are required")))
 			return
 		}
 
-		err := storage.DeleteTestCaseFromQuestionById(questionId, testCaseId)
+		author, _ := middleware.UserIDFromContext(r.Context())
+		err := storage.DeleteTestCaseFromQuestionById(r.Context(), questionId, testCaseId, author)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -118,6 +206,11 @@ func EditQuestionById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
+		if err := validation.Partial(questionReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
 		path := r.URL.Path
 		id := path[strings.LastIndex(path, "/")+1:]
 		
@@ -126,9 +219,10 @@ func EditQuestionById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		err := storage.EditQuestionById(id, questionReq)
+		author, _ := middleware.UserIDFromContext(r.Context())
+		err := storage.EditQuestionById(r.Context(), id, questionReq, author)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
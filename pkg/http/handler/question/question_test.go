@@ -0,0 +1,89 @@
+package question
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/memory"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+func TestGetQuestionByIdHidesPrivateTests(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.New()
+	questionId, err := storage.CreateQuestion(ctx, types.Question{
+		Title:       "Two Sum",
+		Description: "Add two numbers",
+		Statement:   &types.Statement{Legend: "Add them.", SampleExplanations: []string{"1 and 2 make 3."}},
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []types.TestCase{
+		{Input: "1 2", ExpectedOutput: "3", Visibility: types.VisibilityPublic},
+		{Input: "secret", ExpectedOutput: "hidden", Visibility: types.VisibilityPrivate},
+		{Input: "5 5", ExpectedOutput: "10", Visibility: types.VisibilityPublic},
+	} {
+		if _, err := storage.AddTestCaseToQuestion(ctx, questionId, tc, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := GetQuestionById(storage, blob.NewMemoryStore())
+
+	req := httptest.NewRequest("GET", "/api/question/"+questionId, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get returned %d %s", rec.Code, rec.Body)
+	}
+	var view struct {
+		types.QuestionView
+		TestCases []interface{} `json:"test_cases"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	if view.TestCases != nil || view.HiddenTestCases != 1 || len(view.Samples) != 2 {
+		t.Fatalf("contestant view = %s", rec.Body)
+	}
+	if s := view.Samples[0]; s.Input != "1 2" || s.ExpectedOutput != "3" || s.Explanation != "1 and 2 make 3." || s.ExplanationHTML == "" {
+		t.Errorf("first sample = %+v", s)
+	}
+	if s := view.Samples[1]; s.Input != "5 5" || s.Explanation != "" {
+		t.Errorf("second sample = %+v", s)
+	}
+
+	req = req.WithContext(context.WithValue(ctx, middleware.RoleKey, string(types.RoleAdmin)))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var detail types.QuestionDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.TestCases) != 3 {
+		t.Errorf("admin sees %d test cases, want 3", len(detail.TestCases))
+	}
+}
+
+func TestEditQuestionRejectsNegativeMaxAttempts(t *testing.T) {
+	storage := memory.New()
+	questionId, err := storage.CreateQuestion(context.Background(), types.Question{Title: "Two Sum", Description: "Add two numbers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/question/"+questionId, strings.NewReader(`{"max_attempts": -1}`))
+	EditQuestionById(storage).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "max_attempts") {
+		t.Errorf("edit returned %d %s, want 400 on max_attempts", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,45 @@
+package question
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+// ListQuestionRevisions lists every recorded change to a question and its
+// test cases, oldest first, with the fields each one changed.
+func ListQuestionRevisions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := storage.ListQuestionRevisions(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, revisions)
+	}
+}
+
+// RollbackQuestion restores what a revision changed to how it was after
+// that revision. The rollback is itself recorded as a revision.
+func RollbackQuestion(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		number, err := strconv.Atoi(r.PathValue("number"))
+		if err != nil || number < 1 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("revision number must be a positive integer")))
+			return
+		}
+
+		author, _ := middleware.UserIDFromContext(r.Context())
+		if err := storage.RollbackQuestion(r.Context(), r.PathValue("id"), number, author); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "question rolled back successfully"})
+	}
+}
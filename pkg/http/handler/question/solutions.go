@@ -0,0 +1,102 @@
+package question
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problemcheck"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+// GetQuestionSolutions lists a question's reference and known-wrong
+// solutions.
+func GetQuestionSolutions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		solutions, err := storage.GetQuestionSolutions(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]interface{}{"solutions": solutions})
+	}
+}
+
+// SetQuestionSolutions replaces a question's solutions with the list given.
+func SetQuestionSolutions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Solutions []types.Solution `json:"solutions" validate:"max=20,dive"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if err := validation.Struct(req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		names := map[string]bool{}
+		for _, solution := range req.Solutions {
+			if names[solution.Name] {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("solution name %q is used twice", solution.Name)))
+				return
+			}
+			names[solution.Name] = true
+		}
+
+		if err := storage.SetQuestionSolutions(r.Context(), r.PathValue("id"), req.Solutions); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "solutions updated successfully"})
+	}
+}
+
+// ValidateQuestion runs every solution of a question against every test
+// case and reports wrong expected outputs, known-wrong solutions no test
+// catches, and how much of the time limit the reference solutions use.
+func ValidateQuestion(storage storage.Storage, judgeClient *judge0.Client, blobs blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		question, err := storage.GetQuestionById(r.Context(), id)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		solutions, err := storage.GetQuestionSolutions(r.Context(), id)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		tests := make([]problemcheck.Test, 0, len(question.TestCases))
+		for _, tc := range question.TestCases {
+			test := problemcheck.Test{ID: tc.ID.Hex()}
+			test.Input, err = blob.Text(r.Context(), blobs, tc.Input, tc.InputBlob)
+			if err == nil {
+				test.ExpectedOutput, err = blob.Text(r.Context(), blobs, tc.ExpectedOutput, tc.ExpectedOutputBlob)
+			}
+			if err != nil {
+				response.WriteError(w, err)
+				return
+			}
+			tests = append(tests, test)
+		}
+
+		report, err := problemcheck.Check(question, solutions, tests, judgeClient)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, report)
+	}
+}
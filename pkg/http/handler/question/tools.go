@@ -0,0 +1,175 @@
+package question
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problemcheck"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+// GetQuestionValidator returns a question's input validator, or null.
+func GetQuestionValidator(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tools, err := storage.GetQuestionTools(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]interface{}{"validator": tools.Validator})
+	}
+}
+
+// SetQuestionValidator sets the program new test inputs of a question must
+// pass. It reads an input on stdin and exits with an error to reject it.
+// Test cases already there are not checked.
+func SetQuestionValidator(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var validator types.Program
+		if err := json.NewDecoder(r.Body).Decode(&validator); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if err := validation.Struct(validator); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if err := storage.SetQuestionValidator(r.Context(), r.PathValue("id"), &validator); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "validator updated successfully"})
+	}
+}
+
+func DeleteQuestionValidator(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := storage.SetQuestionValidator(r.Context(), r.PathValue("id"), nil); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "validator removed successfully"})
+	}
+}
+
+// GetQuestionGenerators lists a question's test generators.
+func GetQuestionGenerators(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tools, err := storage.GetQuestionTools(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]interface{}{"generators": tools.Generators})
+	}
+}
+
+// SetQuestionGenerators replaces a question's test generators with the
+// list given.
+func SetQuestionGenerators(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Generators []types.Generator `json:"generators" validate:"max=20,dive"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if err := validation.Struct(req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		names := map[string]bool{}
+		for _, generator := range req.Generators {
+			if names[generator.Name] {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("generator name %q is used twice", generator.Name)))
+				return
+			}
+			names[generator.Name] = true
+		}
+
+		if err := storage.SetQuestionGenerators(r.Context(), r.PathValue("id"), req.Generators); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "generators updated successfully"})
+	}
+}
+
+// GenerateTestCases makes test cases with a question's generators and adds
+// them, appended or with "replace" in place of its test cases, unless
+// "dry_run" is set. Expected outputs come from the reference solution named
+// "solution", or the first. Nothing is added if any test fails.
+func GenerateTestCases(storage storage.Storage, judgeClient *judge0.Client, blobs blob.Store, cfg config.Blobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Tests    []problemcheck.GenerateSpec `json:"tests" validate:"required,min=1,max=200,dive"`
+			Solution string                      `json:"solution"`
+			Replace  bool                        `json:"replace"`
+			DryRun   bool                        `json:"dry_run"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if err := validation.Struct(req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		id := r.PathValue("id")
+		question, err := storage.GetQuestionById(r.Context(), id)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		tools, err := storage.GetQuestionTools(r.Context(), id)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		testCases, report, err := problemcheck.Generate(question, tools, req.Tests, req.Solution, judgeClient)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		report.DryRun, report.Replace = req.DryRun, req.Replace
+		if !report.Valid {
+			response.WriteJson(w, http.StatusUnprocessableEntity, report)
+			return
+		}
+		if req.DryRun {
+			response.WriteJson(w, http.StatusOK, report)
+			return
+		}
+
+		for i := range testCases {
+			if err := blob.Prepare(r.Context(), blobs, &testCases[i], cfg.InlineLimit); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+		author, _ := middleware.UserIDFromContext(r.Context())
+		report.TestCaseIDs, err = storage.AddTestCasesToQuestion(r.Context(), id, testCases, req.Replace, author)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		response.WriteJson(w, http.StatusCreated, report)
+	}
+}
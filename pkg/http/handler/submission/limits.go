@@ -0,0 +1,78 @@
+package submission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+// decodeCode decodes a JSON request carrying code into v, writing the
+// error itself when it can't. Bodies are cut off at twice the code size
+// limit, for JSON escaping, plus extra for the other fields.
+func decodeCode(w http.ResponseWriter, r *http.Request, cfg config.Submissions, extra int64, v interface{}) bool {
+	if cfg.MaxCodeSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, 2*int64(cfg.MaxCodeSize)+extra)
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("code is larger than %d bytes", cfg.MaxCodeSize)))
+			return false
+		}
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return false
+	}
+	return true
+}
+
+// checkCodeSize writes a 413 and returns false when code is over the limit.
+func checkCodeSize(w http.ResponseWriter, cfg config.Submissions, code string) bool {
+	if cfg.MaxCodeSize > 0 && len(code) > cfg.MaxCodeSize {
+		response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("code is larger than %d bytes", cfg.MaxCodeSize)))
+		return false
+	}
+	return true
+}
+
+// checkAttempts writes an error and returns false unless question is
+// part of contest and the user has attempts left at it. The question's
+// cap counts the user's submissions to it in every contest, the
+// contest's cap only those in the contest. Counts are read before a
+// submission is judged and stored, so submissions racing each other can
+// all pass; the per-user cooldown keeps that to those made within one.
+func checkAttempts(ctx context.Context, w http.ResponseWriter, storage storage.Storage, userId string, contest *types.ContestDetail, question *types.QuestionDetail) bool {
+	if !slices.ContainsFunc(contest.Questions, func(summary types.QuestionSummary) bool { return summary.ID == question.ID }) {
+		response.WriteJson(w, http.StatusUnprocessableEntity, response.GeneralError(fmt.Errorf("question is not part of the contest")))
+		return false
+	}
+	caps := []struct {
+		limit     int
+		contestId string
+	}{
+		{question.MaxAttempts, ""},
+		{contest.MaxAttempts, contest.ID.Hex()},
+	}
+	for _, c := range caps {
+		if c.limit == 0 {
+			continue
+		}
+		attempts, err := storage.CountSubmissions(ctx, userId, c.contestId, question.ID.Hex())
+		if err != nil {
+			response.WriteError(w, err)
+			return false
+		}
+		if attempts >= c.limit {
+			response.WriteJson(w, http.StatusForbidden, response.GeneralError(fmt.Errorf("no attempts left: at most %d submissions are allowed to this question", c.limit)))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package submission
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+// maxStdin is the longest custom input a run takes, as in the stdin
+// validation tag
+const maxStdin = 1 << 20
+
+// RunResult is one run of a contestant's code. Time is in ms and Memory in
+// KB. Sample runs also carry the sample and whether the output matched it.
+type RunResult struct {
+	TestCaseID     string `json:"test_case_id,omitempty"`
+	Status         string `json:"status"`
+	Passed         *bool  `json:"passed,omitempty"`
+	Stdout         string `json:"stdout"`
+	Stderr         string `json:"stderr"`
+	CompileOutput  string `json:"compile_output,omitempty"`
+	Time           int    `json:"time"`
+	Memory         int    `json:"memory"`
+	ExpectedOutput string `json:"expected_output,omitempty"`
+}
+
+// RunCode runs code within a question's limits on the given stdin, or on
+// each of its public test cases when there is none. Nothing is recorded,
+// so runs are not attempts and carry no penalty.
+func RunCode(storage storage.Storage, judgeClient *judge0.Client, blobs blob.Store, cfg config.Submissions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			QuestionID string  `json:"question_id" validate:"required,mongodb"`
+			Code       string  `json:"code" validate:"required"`
+			LanguageID string  `json:"language_id" validate:"required"`
+			Stdin      *string `json:"stdin" validate:"omitempty,max=1048576"`
+		}
+		// Stdin may be escaped to twice its size too
+		if !decodeCode(w, r, cfg, 2*maxStdin+4<<10, &req) {
+			return
+		}
+		if err := validation.Struct(req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		if !checkCodeSize(w, cfg, req.Code) {
+			return
+		}
+
+		question, err := storage.GetQuestionById(r.Context(), req.QuestionID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		judgeReq := judge0.SubmissionRequest{
+			SourceCode:  req.Code,
+			LanguageID:  req.LanguageID,
+			TimeLimit:   float64(question.Cpu_time_limit) / 1000.0,
+			MemoryLimit: question.Memory_limit,
+		}
+
+		if req.Stdin != nil {
+			judgeReq.Stdin = *req.Stdin
+			result, err := run(judgeClient, judgeReq, false)
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+				return
+			}
+			response.WriteJson(w, http.StatusOK, map[string]interface{}{"results": []RunResult{result}})
+			return
+		}
+
+		results := []RunResult{}
+		for _, testCase := range question.TestCases {
+			if testCase.Visibility != types.VisibilityPublic {
+				continue
+			}
+			judgeReq.Stdin, err = blob.Text(r.Context(), blobs, testCase.Input, testCase.InputBlob)
+			if err == nil {
+				judgeReq.ExpectedOutput, err = blob.Text(r.Context(), blobs, testCase.ExpectedOutput, testCase.ExpectedOutputBlob)
+			}
+			if err != nil {
+				response.WriteError(w, err)
+				return
+			}
+
+			result, err := run(judgeClient, judgeReq, true)
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+				return
+			}
+			result.TestCaseID = testCase.ID.Hex()
+			result.ExpectedOutput = judgeReq.ExpectedOutput
+			results = append(results, result)
+		}
+		if len(results) == 0 {
+			response.WriteError(w, errNoSamples)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]interface{}{"results": results})
+	}
+}
+
+var errNoSamples = storage.Validation("question has no public test cases to run; give stdin instead")
+
+// run executes one request, judging its output when sample is set. A run
+// whose status never arrives is reported as such rather than failing the
+// whole request.
+func run(judgeClient *judge0.Client, req judge0.SubmissionRequest, sample bool) (RunResult, error) {
+	status, err := judgeClient.Run(req)
+	if errors.Is(err, judge0.ErrNoStatus) {
+		result := RunResult{Status: "No result"}
+		if sample {
+			result.Passed = new(bool)
+		}
+		return result, nil
+	}
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	result := RunResult{
+		Status:        status.Status.Description,
+		Stdout:        status.Stdout,
+		Stderr:        status.Stderr,
+		CompileOutput: status.CompileOutput,
+		Memory:        status.Memory,
+	}
+	if seconds, err := strconv.ParseFloat(status.Time, 64); err == nil {
+		result.Time = int(math.Round(seconds * 1000))
+	}
+	if sample {
+		passed := status.Status.ID == judge0.StatusAccepted
+		result.Passed = &passed
+	}
+	return result, nil
+}
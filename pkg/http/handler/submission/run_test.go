@@ -0,0 +1,101 @@
+package submission
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/memory"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// echoJudge is a Judge0 stand-in whose every program prints its stdin.
+func echoJudge(t *testing.T) (*judge0.Client, *[]judge0.SubmissionRequest) {
+	var (
+		mu   sync.Mutex
+		runs []judge0.SubmissionRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == "POST" {
+			var req judge0.SubmissionRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			runs = append(runs, req)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(judge0.SubmissionResponse{Token: "0"})
+			return
+		}
+		req := runs[len(runs)-1]
+		status := judge0.Status{ID: judge0.StatusAccepted, Description: "Accepted"}
+		if req.ExpectedOutput != "" && req.ExpectedOutput != req.Stdin {
+			status = judge0.Status{ID: judge0.StatusWrongAnswer, Description: "Wrong Answer"}
+		}
+		json.NewEncoder(w).Encode(judge0.SubmissionStatus{Status: status, Stdout: req.Stdin, Time: "0.012", Memory: 3000})
+	}))
+	t.Cleanup(server.Close)
+	return judge0.NewClient(server.URL, ""), &runs
+}
+
+func TestRunCode(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.New()
+	judgeClient, runs := echoJudge(t)
+	handler := RunCode(storage, judgeClient, blob.NewMemoryStore(), config.Submissions{MaxCodeSize: 100})
+
+	questionId, err := storage.CreateQuestion(ctx, types.Question{Title: "Echo", Description: "Print the input", Cpu_time_limit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(body string) (int, []RunResult) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/run", strings.NewReader(body)))
+		var got struct {
+			Results []RunResult `json:"results"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &got)
+		return rec.Code, got.Results
+	}
+	request := `{"question_id": "` + questionId + `", "language_id": "71", "code": "print(input())"`
+
+	if status, _ := post(request + `}`); status != http.StatusUnprocessableEntity {
+		t.Errorf("running samples of a question without any returned %d", status)
+	}
+
+	for _, tc := range []types.TestCase{
+		{Input: "1 2", ExpectedOutput: "1 2", Visibility: types.VisibilityPublic},
+		{Input: "secret", ExpectedOutput: "secret", Visibility: types.VisibilityPrivate},
+		{Input: "3", ExpectedOutput: "4", Visibility: types.VisibilityPublic},
+	} {
+		if _, err := storage.AddTestCaseToQuestion(ctx, questionId, tc, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, results := post(request + `}`)
+	if status != http.StatusOK || len(results) != 2 {
+		t.Fatalf("running samples returned %d %+v", status, results)
+	}
+	if r := results[0]; r.Passed == nil || !*r.Passed || r.Stdout != "1 2" || r.Time != 12 || r.Memory != 3000 {
+		t.Errorf("first sample = %+v", r)
+	}
+	if r := results[1]; r.Passed == nil || *r.Passed || r.ExpectedOutput != "4" {
+		t.Errorf("second sample = %+v", r)
+	}
+
+	status, results = post(request + `, "stdin": "custom"}`)
+	if status != http.StatusOK || len(results) != 1 || results[0].Stdout != "custom" || results[0].Passed != nil {
+		t.Fatalf("running on stdin returned %d %+v", status, results)
+	}
+	if last := (*runs)[len(*runs)-1]; last.TimeLimit != 1 || last.ExpectedOutput != "" {
+		t.Errorf("custom run sent %+v", last)
+	}
+}
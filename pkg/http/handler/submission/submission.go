@@ -1,42 +1,72 @@
 package submission
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateSubmission(storage storage.Storage, judgeClient *judge0.Client) http.HandlerFunc {
+func CreateSubmission(storage storage.Storage, judgeClient *judge0.Client, blobs blob.Store, cfg config.Submissions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse submission request
 		var submissionReq struct {
-			QuestionID  string `json:"question_id"`
-			ContestID   string `json:"contest_id"`
-			Code        string `json:"code"`
-			LanguageID  string `json:"language_id"`
+			QuestionID  string `json:"question_id" validate:"required,mongodb"`
+			ContestID   string `json:"contest_id" validate:"required,mongodb"`
+			Code        string `json:"code" validate:"required"`
+			LanguageID  string `json:"language_id" validate:"required"`
 		}
 
-		if err := json.NewDecoder(r.Body).Decode(&submissionReq); err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		if !decodeCode(w, r, cfg, 4<<10, &submissionReq) {
 			return
 		}
 
-		question, err := storage.GetQuestionById(submissionReq.QuestionID)
+		if err := validation.Struct(submissionReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		if !checkCodeSize(w, cfg, submissionReq.Code) {
+			return
+		}
+
+		question, err := storage.GetQuestionById(r.Context(), submissionReq.QuestionID)
 		if err != nil {
-			response.WriteJson(w, http.StatusNotFound, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
 		questionID, _ := primitive.ObjectIDFromHex(submissionReq.QuestionID)
 		contestID, _ := primitive.ObjectIDFromHex(submissionReq.ContestID)
 
-		userID := primitive.NewObjectID() 
+		authUserID, ok := middleware.UserIDFromContext(r.Context())
+		if !ok {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(fmt.Errorf("authentication required")))
+			return
+		}
+		userID, err := primitive.ObjectIDFromHex(authUserID)
+		if err != nil {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(err))
+			return
+		}
+
+		contest, err := storage.GetContestById(r.Context(), submissionReq.ContestID)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		if !checkAttempts(r.Context(), w, storage, authUserID, contest, question) {
+			return
+		}
 
 		submission := types.Submission{
 			ID:          primitive.NewObjectID(),
@@ -54,42 +84,34 @@ func CreateSubmission(storage storage.Storage, judgeClient *judge0.Client) http.
 		judgeReq := judge0.SubmissionRequest{
 			SourceCode:     submissionReq.Code,
 			LanguageID:     submissionReq.LanguageID,
-			TimeLimit:      float64(question[0]["cpu_time_limit"].(int)) / 1000.0, 
-			MemoryLimit:    question[0]["memory_limit"].(int),
+			TimeLimit:      float64(question.Cpu_time_limit) / 1000.0,
+			MemoryLimit:    question.Memory_limit,
 		}
 
-		testCases := question[0]["test_cases"].([]interface{})
+		testCases := question.TestCases
 		
 		totalScore := 0
 		passedTests := 0
 		
-		for _, tc := range testCases {
-			testCase := tc.(map[string]interface{})
-			
+		for _, testCase := range testCases {
 			// Update judge request with test case input/output
-			judgeReq.Stdin = testCase["input"].(string)
-			judgeReq.ExpectedOutput = testCase["expected_output"].(string)
-			
-			token, err := judgeClient.SubmitCode(judgeReq)
+			judgeReq.Stdin, err = blob.Text(r.Context(), blobs, testCase.Input, testCase.InputBlob)
+			if err == nil {
+				judgeReq.ExpectedOutput, err = blob.Text(r.Context(), blobs, testCase.ExpectedOutput, testCase.ExpectedOutputBlob)
+			}
 			if err != nil {
-				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+				response.WriteError(w, err)
 				return
 			}
 			
-			var status *judge0.SubmissionStatus
-			for i := 0; i < 10; i++ { 
-				status, err = judgeClient.GetSubmissionStatus(token)
-				if err != nil {
-					time.Sleep(time.Second)
-					continue
-				}
-				if status.Status.ID != 1 && status.Status.ID != 2 { // Not In Queue or Processing
-					break
-				}
-				time.Sleep(time.Second)
+			// A run whose status never arrives fails the test
+			status, err := judgeClient.Run(judgeReq)
+			if err != nil && !errors.Is(err, judge0.ErrNoStatus) {
+				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+				return
 			}
 			
-			if status.Status.ID == 3 {
+			if err == nil && status.Status.ID == judge0.StatusAccepted {
 				passedTests++
 			}
 		}
@@ -106,9 +128,9 @@ func CreateSubmission(storage storage.Storage, judgeClient *judge0.Client) http.
 		submission.Status = finalStatus
 		submission.Score = totalScore
 		
-		submissionID, err := storage.CreateSubmission(submission)
+		submissionID, err := storage.CreateSubmission(r.Context(), submission)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -118,4 +140,4 @@ func CreateSubmission(storage storage.Storage, judgeClient *judge0.Client) http.
 			"score": totalScore,
 		})
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,83 @@
+package submission
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/memory"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateSubmissionLimits(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.New()
+	judgeClient, _ := echoJudge(t)
+	handler := CreateSubmission(storage, judgeClient, blob.NewMemoryStore(), config.Submissions{MaxCodeSize: 100})
+
+	questionCap := 3
+	questionId, err := storage.CreateQuestion(ctx, types.Question{Title: "Echo", Description: "Print the input", MaxAttempts: &questionCap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.AddTestCaseToQuestion(ctx, questionId, types.TestCase{Input: "1", ExpectedOutput: "1", Visibility: types.VisibilityPrivate}, ""); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	newContest := func(maxAttempts int, link bool) string {
+		t.Helper()
+		contestId, err := storage.CreateContest(ctx, types.Contest{Title: "Weekly", Description: "Weekly", StartTime: start, EndTime: start.Add(time.Hour), MaxAttempts: &maxAttempts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link {
+			if err := storage.LinkQuestionToContest(ctx, contestId, questionId, types.ProblemPlacement{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return contestId
+	}
+	capped, uncapped, unrelated := newContest(2, true), newContest(0, true), newContest(0, false)
+
+	userCtx := context.WithValue(ctx, middleware.UserIDKey, primitive.NewObjectID().Hex())
+	submit := func(contestId, code string) int {
+		t.Helper()
+		body := `{"question_id": "` + questionId + `", "contest_id": "` + contestId + `", "language_id": "71", "code": "` + code + `"}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/submissions", strings.NewReader(body)).WithContext(userCtx))
+		return rec.Code
+	}
+
+	if status := submit(capped, strings.Repeat("x", 101)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized code returned %d", status)
+	}
+	if status := submit(capped, strings.Repeat("x", 10000)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body returned %d", status)
+	}
+	if status := submit(unrelated, "print(input())"); status != http.StatusUnprocessableEntity {
+		t.Errorf("submission through a contest without the question returned %d", status)
+	}
+	// The contest's cap is lower than the question's, so it applies first
+	for i := 0; i < 2; i++ {
+		if status := submit(capped, "print(input())"); status != http.StatusCreated {
+			t.Fatalf("submission %d returned %d", i+1, status)
+		}
+	}
+	if status := submit(capped, "print(input())"); status != http.StatusForbidden {
+		t.Errorf("submission past the contest's cap returned %d", status)
+	}
+	// The question's cap counts submissions in every contest
+	if status := submit(uncapped, "print(input())"); status != http.StatusCreated {
+		t.Fatalf("submission through another contest returned %d", status)
+	}
+	if status := submit(uncapped, "print(input())"); status != http.StatusForbidden {
+		t.Errorf("submission past the question's cap returned %d", status)
+	}
+}
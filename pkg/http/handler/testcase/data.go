@@ -0,0 +1,153 @@
+package testcase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+// Part names one half of a test case's data.
+type Part string
+
+const (
+	PartInput  Part = "input"
+	PartOutput Part = "output"
+)
+
+// GetTestCaseData streams a test case's input or expected output as plain
+// text, from blob storage or from the document.
+func GetTestCaseData(storage storage.Storage, blobs blob.Store, part Part) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		testCase, err := storage.GetTestCaseById(r.Context(), r.PathValue("id"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		inline, ref := testCase.Input, testCase.InputBlob
+		if part == PartOutput {
+			inline, ref = testCase.ExpectedOutput, testCase.ExpectedOutputBlob
+		}
+		if ref != nil {
+			writeBlob(w, r, blobs, *ref)
+			return
+		}
+
+		text, _ := blob.Text(r.Context(), blobs, inline, nil)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(text)))
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, text)
+	}
+}
+
+// PutTestCaseData streams the request body into blob storage as a test
+// case's new input or expected output. A new input must pass the question's
+// validator. The change is recorded as a revision like any other edit.
+func PutTestCaseData(storage storage.Storage, blobs blob.Store, cfg config.Blobs, judgeClient *judge0.Client, part Part) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		// Fail before reading a large body for a test case that isn't there
+		if _, err := storage.GetTestCaseById(r.Context(), id); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		ref, ok := putBlob(w, r, blobs, cfg)
+		if !ok {
+			return
+		}
+
+		var update types.TestCase
+		if part == PartOutput {
+			update.ExpectedOutputBlob = &ref
+		} else {
+			update.InputBlob = &ref
+			if err := validateInput(r.Context(), storage, blobs, judgeClient, id, update); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+		author, _ := middleware.UserIDFromContext(r.Context())
+		if err := storage.EditTestCaseById(r.Context(), id, update, author); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]interface{}{"test_case_id": id, "part": part, "blob": ref})
+	}
+}
+
+// UploadBlob streams the request body into blob storage and returns its
+// reference, for test cases to point at with input_blob or
+// expected_output_blob.
+func UploadBlob(blobs blob.Store, cfg config.Blobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, ok := putBlob(w, r, blobs, cfg)
+		if !ok {
+			return
+		}
+		response.WriteJson(w, http.StatusCreated, ref)
+	}
+}
+
+// DownloadBlob streams a blob by its hash.
+func DownloadBlob(blobs blob.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := blobs.Stat(r.Context(), r.PathValue("hash"))
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+		writeBlob(w, r, blobs, ref)
+	}
+}
+
+func putBlob(w http.ResponseWriter, r *http.Request, blobs blob.Store, cfg config.Blobs) (types.BlobRef, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxSize)
+	ref, err := blobs.Put(r.Context(), r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(fmt.Errorf("upload is larger than %d bytes", cfg.MaxSize)))
+			return types.BlobRef{}, false
+		}
+		response.WriteError(w, err)
+		return types.BlobRef{}, false
+	}
+	return ref, true
+}
+
+// writeBlob streams a blob. Blobs never change, so their hash is a strong
+// ETag.
+func writeBlob(w http.ResponseWriter, r *http.Request, blobs blob.Store, ref types.BlobRef) {
+	etag := `"` + ref.Hash + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rc, err := blobs.Open(r.Context(), ref.Hash)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.FormatInt(ref.Size, 10))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
@@ -1,16 +1,23 @@
 package testcase
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"fmt"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/problemcheck"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
 )
 
-func CreateTestCase(storage storage.Storage) http.HandlerFunc {
+func CreateTestCase(storage storage.Storage, blobs blob.Store, cfg config.Blobs) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var testCaseReq types.TestCase
 		if err := json.NewDecoder(r.Body).Decode(&testCaseReq); err != nil {
@@ -18,9 +25,21 @@ func CreateTestCase(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		testCaseId, err := storage.CreateTestCase(testCaseReq)
+		if err := validation.Struct(testCaseReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+		// Only generation records provenance
+		testCaseReq.Generated = nil
+
+		if err := blob.Prepare(r.Context(), blobs, &testCaseReq, cfg.InlineLimit); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		testCaseId, err := storage.CreateTestCase(r.Context(), testCaseReq)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+			response.WriteError(w, err)
 			return
 		}
 
@@ -28,7 +47,7 @@ func CreateTestCase(storage storage.Storage) http.HandlerFunc {
 	}
 }
 
-func EditTestCaseById(storage storage.Storage) http.HandlerFunc {
+func EditTestCaseById(storage storage.Storage, blobs blob.Store, cfg config.Blobs, judgeClient *judge0.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		id := path[strings.LastIndex(path, "/")+1:]
@@ -44,11 +63,39 @@ func EditTestCaseById(storage storage.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.EditTestCaseById(id, testCaseReq); err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(err))
+		if err := validation.Partial(testCaseReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if testCaseReq.Input != nil || testCaseReq.InputBlob != nil {
+			if err := validateInput(r.Context(), storage, blobs, judgeClient, id, testCaseReq); err != nil {
+				response.WriteError(w, err)
+				return
+			}
+		}
+
+		if err := blob.Prepare(r.Context(), blobs, &testCaseReq, cfg.InlineLimit); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		author, _ := middleware.UserIDFromContext(r.Context())
+		if err := storage.EditTestCaseById(r.Context(), id, testCaseReq, author); err != nil {
+			response.WriteError(w, err)
 			return
 		}
 
 		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "test case updated successfully"})
 	}
-}
\ No newline at end of file
+}
+
+// validateInput runs the input validator of the question listing a test
+// case, if any, on the test case's new input.
+func validateInput(ctx context.Context, storage storage.Storage, blobs blob.Store, judgeClient *judge0.Client, id string, testCase types.TestCase) error {
+	questionId, err := storage.GetTestCaseQuestionId(ctx, id)
+	if err != nil || questionId == "" {
+		return err
+	}
+	return problemcheck.ValidateTestCases(ctx, storage, blobs, judgeClient, questionId, []types.TestCase{testCase})
+}
@@ -0,0 +1,38 @@
+package trash
+
+import (
+	"net/http"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+// ListTrash lists deleted contests, questions and test cases, most
+// recently deleted first.
+func ListTrash(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := storage.ListTrash(r.Context())
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, items)
+	}
+}
+
+// Restore takes an item out of the trash, along with anything deleted
+// together with it.
+func Restore(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := types.ContentKind(r.PathValue("kind"))
+
+		if err := storage.RestoreDeleted(r.Context(), kind, r.PathValue("id")); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": string(kind) + " restored successfully"})
+	}
+}
@@ -0,0 +1,141 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/middleware"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func ListUsers(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		filter := types.UserFilter{
+			Query: query.Get("q"),
+			Role:  types.Role(query.Get("role")),
+			Page:  1,
+			Limit: defaultPageSize,
+		}
+
+		if page := query.Get("page"); page != "" {
+			n, err := strconv.Atoi(page)
+			if err != nil || n < 1 {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("page must be a positive integer")))
+				return
+			}
+			filter.Page = n
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 || n > maxPageSize {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("limit must be between 1 and %d", maxPageSize)))
+				return
+			}
+			filter.Limit = n
+		}
+
+		if disabled := query.Get("disabled"); disabled != "" {
+			b, err := strconv.ParseBool(disabled)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("disabled must be true or false")))
+				return
+			}
+			filter.Disabled = &b
+		}
+
+		users, err := storage.ListUsers(r.Context(), filter)
+		if err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, users)
+	}
+}
+
+func UpdateUserRole(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if isSelf(r, id) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("admins cannot change their own role")))
+			return
+		}
+
+		var roleReq types.RoleUpdate
+		if err := json.NewDecoder(r.Body).Decode(&roleReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		if err := validation.Struct(roleReq); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if err := storage.UpdateUserRole(r.Context(), id, roleReq.Role); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "user role updated successfully"})
+	}
+}
+
+// SetUserDisabled returns the handler for both the disable and enable
+// endpoints.
+func SetUserDisabled(storage storage.Storage, disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if isSelf(r, id) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("admins cannot disable their own account")))
+			return
+		}
+
+		if err := storage.SetUserDisabled(r.Context(), id, disabled); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		message := "user enabled successfully"
+		if disabled {
+			message = "user disabled successfully"
+		}
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": message})
+	}
+}
+
+func DeleteUserById(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if isSelf(r, id) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("admins cannot delete their own account")))
+			return
+		}
+
+		if err := storage.DeleteUserById(r.Context(), id); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "user deleted successfully"})
+	}
+}
+
+// isSelf guards admins against locking themselves out.
+func isSelf(r *http.Request, id string) bool {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	return ok && userID == id
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/mailer"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/roster"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+const maxRosterSize = 5 << 20
+
+// ImportUsers accepts a roster CSV either as the raw request body or as the
+// "file" field of a multipart form. ?dry_run=true only validates, and
+// ?invite=true emails invitations instead of generating passwords.
+func ImportUsers(storage storage.Storage, mail mailer.Mailer, mailCfg config.Mail) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		opts := roster.Options{
+			Mailer:    mail,
+			InviteURL: mailCfg.InviteURL,
+			InviteTTL: mailCfg.InviteTTL,
+		}
+
+		for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "invite": &opts.Invite} {
+			if value := query.Get(name); value != "" {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("%s must be true or false", name)))
+					return
+				}
+				*target = b
+			}
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRosterSize)
+
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("roster file is required: %v", err)))
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		rows, err := roster.Parse(body)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		report := roster.Import(r.Context(), storage, rows, opts)
+
+		status := http.StatusOK
+		if !opts.DryRun && report.Created > 0 {
+			status = http.StatusCreated
+		}
+		response.WriteJson(w, status, report)
+	}
+}
+
+func AcceptInvitation(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.AcceptInvitationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		if err := validation.Struct(req); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err))
+			return
+		}
+
+		if err := storage.AcceptInvitation(r.Context(), roster.HashToken(req.Token), req.Password); err != nil {
+			response.WriteError(w, err)
+			return
+		}
+
+		response.WriteJson(w, http.StatusOK, map[string]string{"status": "success", "message": "invitation accepted, you can now log in"})
+	}
+}
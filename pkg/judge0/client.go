@@ -3,6 +3,7 @@ package judge0
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -21,6 +22,7 @@ type SubmissionRequest struct {
     ExpectedOutput string  `json:"expected_output"`
     TimeLimit     float64  `json:"time_limit"`
     MemoryLimit   int      `json:"memory_limit"`
+    CommandLineArguments string `json:"command_line_arguments,omitempty"`
 }
 
 type SubmissionResponse struct {
@@ -32,8 +34,9 @@ type SubmissionStatus struct {
     Stdout    string `json:"stdout"`
     Time      string `json:"time"`
     Memory    int    `json:"memory"`
-    stderr    string `json:"stderr"`
+    Stderr    string `json:"stderr"`
     Message   string `json:"message"`
+    CompileOutput string `json:"compile_output"`
     ExitCode  int    `json:"exit_code"`
 }
 
@@ -108,4 +111,46 @@ func (c *Client) GetSubmissionStatus(token string) (*SubmissionStatus, error) {
     }
 
     return &status, nil
-} 
\ No newline at end of file
+}
+
+// Judge0 status ids
+const (
+    StatusInQueue           = 1
+    StatusProcessing        = 2
+    StatusAccepted          = 3
+    StatusWrongAnswer       = 4
+    StatusTimeLimitExceeded = 5
+    StatusCompilationError  = 6
+)
+
+// ErrNoStatus is returned by Run when a submission was made but its status
+// could never be read.
+var ErrNoStatus = errors.New("judge0: no submission status")
+
+// Run submits req and waits for its result, polling once a second for up
+// to ten seconds. The last status seen is returned even if the run has not
+// finished by then.
+func (c *Client) Run(req SubmissionRequest) (*SubmissionStatus, error) {
+    token, err := c.SubmitCode(req)
+    if err != nil {
+        return nil, err
+    }
+
+    var status *SubmissionStatus
+    for i := 0; i < 10; i++ {
+        current, err := c.GetSubmissionStatus(token)
+        if err != nil {
+            time.Sleep(time.Second)
+            continue
+        }
+        status = current
+        if status.Status.ID != StatusInQueue && status.Status.ID != StatusProcessing {
+            break
+        }
+        time.Sleep(time.Second)
+    }
+    if status == nil {
+        return nil, fmt.Errorf("%w for %s", ErrNoStatus, token)
+    }
+    return status, nil
+}
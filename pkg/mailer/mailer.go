@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// New returns an SMTP mailer, or a mailer that only logs messages when no
+// SMTP host is configured so local development works without a relay.
+func New(cfg config.Mail) Mailer {
+	if cfg.Host == "" {
+		return LogMailer{}
+	}
+	return NewSMTPMailer(cfg)
+}
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg config.Mail) *SMTPMailer {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		from: cfg.From,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("error sending mail to %s: %v", to, err)
+	}
+	return nil
+}
+
+type LogMailer struct{}
+
+func (LogMailer) Send(to string, subject string, body string) error {
+	slog.Info("mail not sent, no SMTP host configured", slog.String("to", to), slog.String("subject", subject), slog.String("body", body))
+	return nil
+}
@@ -17,42 +17,79 @@ const (
 	RoleKey      contextKey = "role"      
 )
 
+// UserLookup is the part of storage.Storage the middleware needs to make
+// sure a token's user still exists and has not been disabled.
+type UserLookup interface {
+	GetUserById(ctx context.Context, id string) (*types.User, error)
+}
+
 type AuthMiddleware struct {
 	secretKey []byte
+	users     UserLookup
 }
 
-func NewAuthMiddleware(secretKey string) *AuthMiddleware {
+func NewAuthMiddleware(secretKey string, users UserLookup) *AuthMiddleware {
 	return &AuthMiddleware{
 		secretKey: []byte(secretKey),
+		users:     users,
 	}
 }
 
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("access_token")
+		ctx, err := m.authenticate(r)
 		if err != nil {
 			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(err))
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		tokenString := cookie.Value
-		claims := jwt.MapClaims{}
+// Optional identifies the user like Authenticate when the request carries a
+// valid token, and otherwise passes it on anonymously, for endpoints that
+// show more to signed-in users or admins.
+func (m *AuthMiddleware) Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctx, err := m.authenticate(r); err == nil {
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return m.secretKey, nil
-		})
+// authenticate returns the request's context with the user its token
+// belongs to.
+func (m *AuthMiddleware) authenticate(r *http.Request) (context.Context, error) {
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil || !token.Valid {
-			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(err))
-			return
-		}
+	tokenString := cookie.Value
+	claims := jwt.MapClaims{}
 
-		// Update the context values to use the custom keys
-		ctx := context.WithValue(r.Context(), UserIDKey, claims["user_id"])
-		ctx = context.WithValue(ctx, StudentIDKey, claims["student_id"])
-		ctx = context.WithValue(ctx, RoleKey, claims["role"])
-		next.ServeHTTP(w, r.WithContext(ctx))
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return m.secretKey, nil
 	})
+
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	// Tokens live for a day, so check the account on every request to
+	// pick up role changes and disabled or deleted users immediately
+	userID, _ := claims["user_id"].(string)
+	user, err := m.users.GetUserById(r.Context(), userID)
+	if err != nil || user.Disabled {
+		return nil, fmt.Errorf("account is not active")
+	}
+
+	// Update the context values to use the custom keys
+	ctx := context.WithValue(r.Context(), UserIDKey, user.ID.Hex())
+	ctx = context.WithValue(ctx, StudentIDKey, user.StudentId)
+	ctx = context.WithValue(ctx, RoleKey, string(user.Role))
+	return ctx, nil
 }
 
 func (m *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
@@ -65,3 +102,14 @@ func (m *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
         next.ServeHTTP(w, r)
     })
 }
+
+// UserIDFromContext returns the id of the user authenticated by Authenticate.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(UserIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+// IsAdmin reports whether the request was authenticated as an admin.
+func IsAdmin(ctx context.Context) bool {
+	return ctx.Value(RoleKey) == string(types.RoleAdmin)
+}
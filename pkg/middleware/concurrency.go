@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// ConcurrencyLimit refuses requests with 429 while size of the requests it
+// wraps are already being served, so a slow backend such as the judge is
+// not handed an ever growing queue.
+type ConcurrencyLimit struct {
+	slots      chan struct{}
+	retryAfter time.Duration
+}
+
+// NewConcurrencyLimit allows size requests at once, or any number if size
+// is not positive. Refused clients are told to retry after retryAfter.
+func NewConcurrencyLimit(size int, retryAfter time.Duration) *ConcurrencyLimit {
+	c := &ConcurrencyLimit{retryAfter: retryAfter}
+	if size > 0 {
+		c.slots = make(chan struct{}, size)
+	}
+	return c
+}
+
+func (c *ConcurrencyLimit) Limit(next http.Handler) http.Handler {
+	if c.slots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case c.slots <- struct{}{}:
+			defer func() { <-c.slots }()
+			next.ServeHTTP(w, r)
+		default:
+			TooManyRequests(w, c.retryAfter)
+		}
+	})
+}
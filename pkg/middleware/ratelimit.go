@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/ratelimit"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/utils/response"
+)
+
+const ClientIPKey contextKey = "client_ip"
+
+type RateLimitMiddleware struct {
+	limiter    *ratelimit.Limiter
+	trustProxy bool
+}
+
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, trustProxy bool) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		limiter:    limiter,
+		trustProxy: trustProxy,
+	}
+}
+
+// LimitByIP allows each client address limit.Burst requests to the wrapped
+// handler, refilled one per limit.Interval. The scope keeps buckets of
+// different routes apart.
+func (m *RateLimitMiddleware) LimitByIP(scope string, limit ratelimit.Limit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := m.ClientIP(r)
+
+		result, err := m.limiter.Allow(r.Context(), scope+":ip:"+ip, limit)
+		if err != nil {
+			slog.Error("rate limiter unavailable", slog.String("scope", scope), slog.String("error", err.Error()))
+		}
+		if !result.Allowed {
+			TooManyRequests(w, result.RetryAfter)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ClientIPKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LimitByUser is LimitByIP keyed by the authenticated user instead, for
+// routes behind Authenticate. Anonymous requests fall back to the address.
+func (m *RateLimitMiddleware) LimitByUser(scope string, limit ratelimit.Limit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := scope + ":ip:" + m.ClientIP(r)
+		if userID, ok := UserIDFromContext(r.Context()); ok {
+			key = scope + ":user:" + userID
+		}
+
+		result, err := m.limiter.Allow(r.Context(), key, limit)
+		if err != nil {
+			slog.Error("rate limiter unavailable", slog.String("scope", scope), slog.String("error", err.Error()))
+		}
+		if !result.Allowed {
+			TooManyRequests(w, result.RetryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP returns the address of the caller, honouring X-Real-IP and
+// X-Forwarded-For only when the server sits behind a trusted proxy.
+func (m *RateLimitMiddleware) ClientIP(r *http.Request) string {
+	if m.trustProxy {
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return strings.TrimSpace(ip)
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TooManyRequests writes a 429 with a Retry-After header rounded up to
+// whole seconds.
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	response.WriteJson(w, http.StatusTooManyRequests, response.GeneralError(fmt.Errorf("too many requests, retry after %d seconds", seconds)))
+}
@@ -0,0 +1,289 @@
+// Package problemcheck runs a question's solutions against its test cases
+// through the execution backend, to catch wrong expected outputs and time
+// limits that don't fit the reference solutions.
+package problemcheck
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+const (
+	// parallel bounds the runs in flight at once
+	parallel = 4
+	// headroom is how many times the time limit solutions may run for, so
+	// slow ones are measured rather than cut off at the limit
+	headroom = 2
+	// tightRatio is the share of the time limit above which the slowest
+	// reference solution is reported as too close to it
+	tightRatio = 0.5
+	// outputExcerpt bounds the output shown for a wrong answer
+	outputExcerpt = 256
+)
+
+// Runner runs one program on one input. *judge0.Client is one.
+type Runner interface {
+	Run(req judge0.SubmissionRequest) (*judge0.SubmissionStatus, error)
+}
+
+// Test is one test case with its data read in full.
+type Test struct {
+	ID             string
+	Input          string
+	ExpectedOutput string
+}
+
+type Verdict string
+
+const (
+	VerdictAccepted          Verdict = "accepted"
+	VerdictWrongAnswer       Verdict = "wrong_answer"
+	VerdictTimeLimitExceeded Verdict = "time_limit_exceeded"
+	VerdictCompilationError  Verdict = "compilation_error"
+	VerdictRuntimeError      Verdict = "runtime_error"
+	// VerdictError is a run the backend could not complete
+	VerdictError Verdict = "error"
+)
+
+// Report is the outcome of checking a question. It is Valid when every
+// reference solution passes every test within the time limit and every
+// known-wrong solution fails at least one.
+type Report struct {
+	Valid     bool `json:"valid"`
+	TimeLimit int  `json:"cpu_time_limit"`
+	// MaxTime (ms) and MaxMemory (KB) are the most any reference solution
+	// used on one test
+	MaxTime   int `json:"max_time"`
+	MaxMemory int `json:"max_memory"`
+	// TimeRatio is MaxTime over the time limit, 0 when there is none
+	TimeRatio float64 `json:"time_ratio"`
+	// Mismatches are tests a reference solution gave another answer for,
+	// whose expected output is likely wrong
+	Mismatches []Mismatch       `json:"mismatches"`
+	Solutions  []SolutionResult `json:"solutions"`
+	Warnings   []string         `json:"warnings"`
+}
+
+type Mismatch struct {
+	Test       int    `json:"test"`
+	TestCaseID string `json:"test_case_id"`
+	Solution   string `json:"solution"`
+	Output     string `json:"output"`
+}
+
+type SolutionResult struct {
+	Name      string                    `json:"name"`
+	Expected  types.SolutionExpectation `json:"expected"`
+	OK        bool                      `json:"ok"`
+	Passed    int                       `json:"passed"`
+	Failed    int                       `json:"failed"`
+	MaxTime   int                       `json:"max_time"`
+	MaxMemory int                       `json:"max_memory"`
+	Results   []TestResult              `json:"results"`
+}
+
+// TestResult is one solution's run on one test. Test counts from 1 in
+// question order.
+type TestResult struct {
+	Test       int     `json:"test"`
+	TestCaseID string  `json:"test_case_id"`
+	Verdict    Verdict `json:"verdict"`
+	Time       int     `json:"time"`
+	Memory     int     `json:"memory"`
+	Message    string  `json:"message,omitempty"`
+	Output     string  `json:"output,omitempty"`
+
+	stdout string
+}
+
+// Check runs every solution against every test. Only a failure to reach
+// the backend is an error; everything the runs show is in the report.
+func Check(question *types.QuestionDetail, solutions []types.Solution, tests []Test, runner Runner) (*Report, error) {
+	references := 0
+	for _, solution := range solutions {
+		if solution.Expected == types.SolutionAccepted {
+			references++
+		}
+	}
+	if references == 0 {
+		return nil, storage.Validation("question has no reference solution to check it with")
+	}
+	if len(tests) == 0 {
+		return nil, storage.Validation("question has no test cases to check")
+	}
+
+	results := make([][]TestResult, len(solutions))
+	for i := range results {
+		results[i] = make([]TestResult, len(tests))
+	}
+	if err := runAll(question, solutions, tests, runner, results); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		TimeLimit:  question.Cpu_time_limit,
+		Mismatches: []Mismatch{},
+		Solutions:  []SolutionResult{},
+		Warnings:   []string{},
+	}
+	for i, solution := range solutions {
+		sr := SolutionResult{Name: solution.Name, Expected: solution.Expected, Results: results[i]}
+		for _, result := range results[i] {
+			if result.Verdict == VerdictAccepted {
+				sr.Passed++
+			} else {
+				sr.Failed++
+			}
+			sr.MaxTime = max(sr.MaxTime, result.Time)
+			sr.MaxMemory = max(sr.MaxMemory, result.Memory)
+			if solution.Expected == types.SolutionAccepted && result.Verdict == VerdictWrongAnswer {
+				report.Mismatches = append(report.Mismatches, Mismatch{
+					Test:       result.Test,
+					TestCaseID: result.TestCaseID,
+					Solution:   solution.Name,
+					Output:     result.Output,
+				})
+			}
+		}
+
+		if solution.Expected == types.SolutionAccepted {
+			sr.OK = sr.Failed == 0
+			report.MaxTime = max(report.MaxTime, sr.MaxTime)
+			report.MaxMemory = max(report.MaxMemory, sr.MaxMemory)
+		} else {
+			sr.OK = sr.Failed > 0
+			if !sr.OK {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("known-wrong solution %q passes every test", solution.Name))
+			}
+		}
+		report.Solutions = append(report.Solutions, sr)
+	}
+
+	if question.Cpu_time_limit > 0 {
+		report.TimeRatio = math.Round(float64(report.MaxTime)/float64(question.Cpu_time_limit)*100) / 100
+		if report.TimeRatio > tightRatio {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("the slowest reference solution takes %d ms, %.0f%% of the time limit", report.MaxTime, report.TimeRatio*100))
+		}
+	} else {
+		report.Warnings = append(report.Warnings, "question has no time limit; runs used the backend's default")
+	}
+
+	report.Valid = true
+	for _, sr := range report.Solutions {
+		report.Valid = report.Valid && sr.OK
+	}
+	return report, nil
+}
+
+// runAll fills in results[solution][test].
+func runAll(question *types.QuestionDetail, solutions []types.Solution, tests []Test, runner Runner, results [][]TestResult) error {
+	return forEach(len(solutions)*len(tests), func(i int) error {
+		s, t := i/len(tests), i%len(tests)
+		result, err := run(question, solutions[s], tests[t], runner)
+		if err != nil {
+			return err
+		}
+		result.Test = t + 1
+		results[s][t] = result
+		return nil
+	})
+}
+
+// forEach calls fn for 0 to n-1, a few at a time, stopping at the first
+// error.
+func forEach(n int, fn func(i int) error) error {
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+func run(question *types.QuestionDetail, solution types.Solution, test Test, runner Runner) (TestResult, error) {
+	result := TestResult{TestCaseID: test.ID}
+	status, err := runner.Run(judge0.SubmissionRequest{
+		SourceCode:     solution.Code,
+		LanguageID:     solution.LanguageID,
+		Stdin:          test.Input,
+		ExpectedOutput: test.ExpectedOutput,
+		TimeLimit:      float64(question.Cpu_time_limit*headroom) / 1000.0,
+		MemoryLimit:    question.Memory_limit,
+	})
+	if errors.Is(err, judge0.ErrNoStatus) {
+		result.Verdict, result.Message = VerdictError, "no result from the execution backend"
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if seconds, err := strconv.ParseFloat(status.Time, 64); err == nil {
+		result.Time = int(math.Round(seconds * 1000))
+	}
+	result.Memory = status.Memory
+	result.stdout = status.Stdout
+
+	switch id := status.Status.ID; {
+	case id == judge0.StatusAccepted:
+		result.Verdict = VerdictAccepted
+	case id == judge0.StatusWrongAnswer:
+		result.Verdict = VerdictWrongAnswer
+		result.Output = excerpt(status.Stdout)
+	case id == judge0.StatusTimeLimitExceeded:
+		result.Verdict = VerdictTimeLimitExceeded
+	case id == judge0.StatusCompilationError:
+		result.Verdict, result.Message = VerdictCompilationError, excerpt(status.CompileOutput)
+	case id >= 7 && id <= 12:
+		result.Verdict, result.Message = VerdictRuntimeError, status.Status.Description
+	default:
+		result.Verdict, result.Message = VerdictError, status.Status.Description
+	}
+	// Runs get headroom to be measured; past the limit is still too slow
+	if result.Verdict == VerdictAccepted && question.Cpu_time_limit > 0 && result.Time > question.Cpu_time_limit {
+		result.Verdict = VerdictTimeLimitExceeded
+	}
+	return result, nil
+}
+
+func excerpt(s string) string {
+	if len(s) <= outputExcerpt {
+		return s
+	}
+	return strings.ToValidUTF8(s[:outputExcerpt], "") + "..."
+}
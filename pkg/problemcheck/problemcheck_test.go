@@ -0,0 +1,192 @@
+package problemcheck
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// fakeRunner "runs" programs whose source names what they do: "echo"
+// prints the input, "double" prints it twice, "slow" echoes in 900 ms,
+// "broken" fails to compile, "args" prints its arguments and "positive"
+// exits with an error on input with a minus sign.
+type fakeRunner struct {
+	mu   sync.Mutex
+	runs int
+}
+
+func (f *fakeRunner) Run(req judge0.SubmissionRequest) (*judge0.SubmissionStatus, error) {
+	f.mu.Lock()
+	f.runs++
+	f.mu.Unlock()
+
+	status := &judge0.SubmissionStatus{Time: "0.100", Memory: 1024}
+	var stdout string
+	switch req.SourceCode {
+	case "echo":
+		stdout = req.Stdin
+	case "double":
+		stdout = req.Stdin + req.Stdin
+	case "slow":
+		stdout, status.Time = req.Stdin, "0.900"
+	case "broken":
+		status.Status.ID, status.CompileOutput = judge0.StatusCompilationError, "syntax error"
+		return status, nil
+	case "args":
+		stdout = req.CommandLineArguments + "\n"
+	case "positive":
+		if strings.Contains(req.Stdin, "-") {
+			status.Status = judge0.Status{ID: 11, Description: "Runtime Error (NZEC)"}
+			status.Stderr = "negative number"
+			return status, nil
+		}
+	}
+	status.Stdout = stdout
+	status.Status.ID = judge0.StatusWrongAnswer
+	if stdout == req.ExpectedOutput {
+		status.Status.ID = judge0.StatusAccepted
+	}
+	return status, nil
+}
+
+func TestCheck(t *testing.T) {
+	question := &types.QuestionDetail{Cpu_time_limit: 1000}
+	tests := []Test{
+		{ID: "a", Input: "1", ExpectedOutput: "1"},
+		{ID: "b", Input: "2", ExpectedOutput: "3"},
+		{ID: "c", Input: "4", ExpectedOutput: "4"},
+	}
+	solutions := []types.Solution{
+		{Name: "main", Code: "slow", Expected: types.SolutionAccepted},
+		{Name: "double", Code: "double", Expected: types.SolutionWrong},
+		{Name: "broken", Code: "broken", Expected: types.SolutionWrong},
+	}
+
+	runner := &fakeRunner{}
+	report, err := Check(question, solutions, tests, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runner.runs != 9 {
+		t.Errorf("runs = %d, want 9", runner.runs)
+	}
+	if report.Valid {
+		t.Error("report valid with a wrong expected output")
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].TestCaseID != "b" || report.Mismatches[0].Test != 2 || report.Mismatches[0].Output != "2" {
+		t.Errorf("mismatches = %+v", report.Mismatches)
+	}
+	if report.MaxTime != 900 || report.TimeRatio != 0.9 {
+		t.Errorf("max time = %d, ratio = %v", report.MaxTime, report.TimeRatio)
+	}
+	main := report.Solutions[0]
+	if main.OK || main.Passed != 2 || main.Failed != 1 {
+		t.Errorf("reference solution = %+v", main)
+	}
+	if !report.Solutions[1].OK || !report.Solutions[2].OK {
+		t.Errorf("wrong solutions not caught: %+v", report.Solutions[1:])
+	}
+	if report.Solutions[2].Results[0].Verdict != VerdictCompilationError || report.Solutions[2].Results[0].Message != "syntax error" {
+		t.Errorf("broken result = %+v", report.Solutions[2].Results[0])
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "90%") {
+		t.Errorf("warnings = %v", report.Warnings)
+	}
+}
+
+func TestCheckPasses(t *testing.T) {
+	question := &types.QuestionDetail{Cpu_time_limit: 500}
+	tests := []Test{{ID: "a", Input: "1", ExpectedOutput: "1"}}
+
+	report, err := Check(question, []types.Solution{
+		{Name: "main", Code: "echo", Expected: types.SolutionAccepted},
+		{Name: "lucky", Code: "echo", Expected: types.SolutionWrong},
+	}, tests, &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], `"lucky" passes every test`) {
+		t.Errorf("report = %+v", report)
+	}
+
+	// Over the limit counts as too slow even though the run had headroom
+	report, err = Check(question, []types.Solution{{Name: "main", Code: "slow", Expected: types.SolutionAccepted}}, tests, &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Solutions[0].Results[0].Verdict != VerdictTimeLimitExceeded || report.TimeRatio != 1.8 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestCheckNeedsReferenceSolution(t *testing.T) {
+	_, err := Check(&types.QuestionDetail{}, []types.Solution{{Name: "wa", Expected: types.SolutionWrong}}, []Test{{}}, &fakeRunner{})
+	if !errors.Is(err, storage.ErrValidation) {
+		t.Errorf("err = %v, want ErrValidation", err)
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	verdicts, err := ValidateInputs(&types.QuestionDetail{}, types.Program{Code: "positive"}, []string{"1 2", "-3", "4"}, &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verdicts[0].Valid || verdicts[1].Valid || !verdicts[2].Valid {
+		t.Errorf("verdicts = %+v", verdicts)
+	}
+	err = Rejections(verdicts)
+	if !errors.Is(err, storage.ErrValidation) || !strings.Contains(err.Error(), "test 2: Runtime Error (NZEC): negative number") {
+		t.Errorf("Rejections = %v", err)
+	}
+	if err := Rejections(verdicts[:1]); err != nil {
+		t.Errorf("Rejections of valid inputs = %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	question := &types.QuestionDetail{Cpu_time_limit: 1000}
+	tools := &types.QuestionTools{
+		Solutions: []types.Solution{
+			{Name: "wrong", Code: "double", Expected: types.SolutionWrong},
+			{Name: "main", Code: "echo", Expected: types.SolutionAccepted},
+		},
+		Validator:  &types.Program{Code: "positive"},
+		Generators: []types.Generator{{Name: "rand", Code: "args"}},
+	}
+	specs := []GenerateSpec{
+		{Generator: "rand", Args: "10", Seed: 7, Visibility: types.VisibilityPublic, Subtask: 1},
+		{Generator: "rand", Args: "-5", Seed: 8},
+	}
+
+	testCases, report, err := Generate(question, tools, specs, "", &fakeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Solution != "main" {
+		t.Errorf("report = %+v", report)
+	}
+	tc := testCases[0]
+	if tc.Input != "10 7\n" || tc.ExpectedOutput != "10 7\n" || tc.Visibility != types.VisibilityPublic || tc.Subtask != 1 {
+		t.Errorf("generated test case = %+v", tc)
+	}
+	if g := tc.Generated; g == nil || g.Generator != "rand" || g.Args != "10" || g.Seed != 7 || g.Solution != "main" {
+		t.Errorf("provenance = %+v", tc.Generated)
+	}
+	if !strings.HasPrefix(report.Tests[1].Error, "validator rejected the input") {
+		t.Errorf("second test = %+v", report.Tests[1])
+	}
+
+	_, _, err = Generate(question, tools, []GenerateSpec{{Generator: "missing"}}, "", &fakeRunner{})
+	if !errors.Is(err, storage.ErrValidation) {
+		t.Errorf("unknown generator: %v", err)
+	}
+	_, _, err = Generate(question, tools, specs, "wrong", &fakeRunner{})
+	if !errors.Is(err, storage.ErrValidation) {
+		t.Errorf("known-wrong solution accepted as reference: %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package problemcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/judge0"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// toolTimeLimit is the time limit, in seconds, for validators and
+// generators, which are not bound by the question's
+const toolTimeLimit = 10
+
+// InputVerdict is an input validator's judgement of one test input. Test
+// counts from 1.
+type InputVerdict struct {
+	Test    int    `json:"test"`
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateInputs runs validator on each input, given on stdin. An input is
+// valid when the validator exits normally; what it printed explains a
+// rejection.
+func ValidateInputs(question *types.QuestionDetail, validator types.Program, inputs []string, runner Runner) ([]InputVerdict, error) {
+	verdicts := make([]InputVerdict, len(inputs))
+	err := forEach(len(inputs), func(i int) error {
+		verdicts[i] = InputVerdict{Test: i + 1}
+		status, err := runTool(question, validator, inputs[i], "", runner)
+		if err != nil {
+			return err
+		}
+		verdicts[i].Valid = status != nil && finished(status)
+		if !verdicts[i].Valid {
+			verdicts[i].Message = failure(status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return verdicts, nil
+}
+
+// Rejections returns the verdicts that rejected their input, as a
+// storage.ErrValidation error naming them, or nil if there are none.
+func Rejections(verdicts []InputVerdict) error {
+	var rejected []string
+	for _, v := range verdicts {
+		if !v.Valid {
+			rejected = append(rejected, fmt.Sprintf("test %d: %s", v.Test, v.Message))
+		}
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	return storage.Validation("input validator rejected %s", strings.Join(rejected, "; "))
+}
+
+// ValidateTestCases runs a question's input validator, if it has one, on
+// the inputs of testCases, returning a validation error naming those it
+// rejects.
+func ValidateTestCases(ctx context.Context, store storage.Storage, blobs blob.Store, runner Runner, questionId string, testCases []types.TestCase) error {
+	tools, err := store.GetQuestionTools(ctx, questionId)
+	if err != nil || tools.Validator == nil {
+		return err
+	}
+	question, err := store.GetQuestionById(ctx, questionId)
+	if err != nil {
+		return err
+	}
+
+	inputs := make([]string, 0, len(testCases))
+	for _, tc := range testCases {
+		input, err := blob.Text(ctx, blobs, tc.Input, tc.InputBlob)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+	}
+
+	verdicts, err := ValidateInputs(question, *tools.Validator, inputs, runner)
+	if err != nil {
+		return err
+	}
+	return Rejections(verdicts)
+}
+
+// GenerateSpec asks for one test case from a generator, run with Args and
+// then Seed as its command-line arguments.
+type GenerateSpec struct {
+	Generator  string           `json:"generator" validate:"required"`
+	Args       string           `json:"args" validate:"max=1000"`
+	Seed       int64            `json:"seed"`
+	Visibility types.Visibility `json:"visibility" validate:"omitempty,oneof=public private"`
+	Subtask    int              `json:"subtask" validate:"gte=0,lte=1000"`
+}
+
+// Generated is the outcome of one GenerateSpec. Test counts from 1.
+type Generated struct {
+	Test       int    `json:"test"`
+	Generator  string `json:"generator"`
+	Seed       int64  `json:"seed"`
+	InputSize  int    `json:"input_size"`
+	OutputSize int    `json:"output_size"`
+	Error      string `json:"error,omitempty"`
+}
+
+// GenerateReport describes a generation run. It is Valid when every test
+// was generated, passed the validator and was solved by the solution.
+type GenerateReport struct {
+	Valid       bool        `json:"valid"`
+	DryRun      bool        `json:"dry_run"`
+	Replace     bool        `json:"replace"`
+	Solution    string      `json:"solution"`
+	Tests       []Generated `json:"tests"`
+	TestCaseIDs []string    `json:"test_case_ids,omitempty"`
+}
+
+// Generate makes a test case for each spec: its input is what the
+// generator prints, checked by the question's validator if it has one, and
+// its expected output what the named reference solution, or the first one,
+// prints for it. Each test case records how it was made.
+func Generate(question *types.QuestionDetail, tools *types.QuestionTools, specs []GenerateSpec, solutionName string, runner Runner) ([]types.TestCase, *GenerateReport, error) {
+	solution, err := referenceSolution(tools.Solutions, solutionName)
+	if err != nil {
+		return nil, nil, err
+	}
+	generators := map[string]types.Generator{}
+	for _, g := range tools.Generators {
+		generators[g.Name] = g
+	}
+	for _, spec := range specs {
+		if _, ok := generators[spec.Generator]; !ok {
+			return nil, nil, storage.Validation("question has no generator named %q", spec.Generator)
+		}
+	}
+
+	now := time.Now()
+	testCases := make([]types.TestCase, len(specs))
+	results := make([]Generated, len(specs))
+	err = forEach(len(specs), func(i int) error {
+		spec := specs[i]
+		results[i] = Generated{Test: i + 1, Generator: spec.Generator, Seed: spec.Seed}
+		input, output, problem, err := generate(question, tools.Validator, generators[spec.Generator], spec, solution, runner)
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			results[i].Error = problem
+			return nil
+		}
+
+		results[i].InputSize, results[i].OutputSize = len(input), len(output)
+		visibility := spec.Visibility
+		if visibility == "" {
+			visibility = types.VisibilityPrivate
+		}
+		testCases[i] = types.TestCase{
+			Input:          input,
+			ExpectedOutput: output,
+			Visibility:     visibility,
+			Subtask:        spec.Subtask,
+			CreatedAt:      now,
+			Generated: &types.Generation{
+				Generator:   spec.Generator,
+				Args:        spec.Args,
+				Seed:        spec.Seed,
+				Solution:    solution.Name,
+				GeneratedAt: now,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &GenerateReport{Valid: true, Solution: solution.Name, Tests: results}
+	for _, result := range results {
+		report.Valid = report.Valid && result.Error == ""
+	}
+	return testCases, report, nil
+}
+
+// generate runs one spec through generator, validator and solution. What
+// goes wrong with the programs is returned as problem; err is for the
+// backend itself failing.
+func generate(question *types.QuestionDetail, validator *types.Program, generator types.Generator, spec GenerateSpec, solution types.Solution, runner Runner) (input, output, problem string, err error) {
+	args := strings.TrimSpace(spec.Args + " " + strconv.FormatInt(spec.Seed, 10))
+	status, err := runTool(question, types.Program{LanguageID: generator.LanguageID, Code: generator.Code}, "", args, runner)
+	if err != nil {
+		return "", "", "", err
+	}
+	if status == nil || !finished(status) {
+		return "", "", "generator failed: " + failure(status), nil
+	}
+	input = status.Stdout
+	if input == "" {
+		return "", "", "generator printed nothing", nil
+	}
+
+	if validator != nil {
+		status, err := runTool(question, *validator, input, "", runner)
+		if err != nil {
+			return "", "", "", err
+		}
+		if status == nil || !finished(status) {
+			return "", "", "validator rejected the input: " + failure(status), nil
+		}
+	}
+
+	result, err := run(question, solution, Test{Input: input}, runner)
+	if err != nil {
+		return "", "", "", err
+	}
+	// Without an expected output to compare with, any normal exit may be
+	// judged a wrong answer
+	if result.Verdict == VerdictWrongAnswer && question.Cpu_time_limit > 0 && result.Time > question.Cpu_time_limit {
+		result.Verdict = VerdictTimeLimitExceeded
+	}
+	if result.Verdict != VerdictAccepted && result.Verdict != VerdictWrongAnswer {
+		problem := fmt.Sprintf("solution %q: %s", solution.Name, result.Verdict)
+		if result.Message != "" {
+			problem += ": " + result.Message
+		}
+		return "", "", problem, nil
+	}
+	return input, result.stdout, "", nil
+}
+
+// runTool runs a validator or generator. A nil status with no error means
+// the backend never reported one.
+func runTool(question *types.QuestionDetail, program types.Program, stdin, args string, runner Runner) (*judge0.SubmissionStatus, error) {
+	status, err := runner.Run(judge0.SubmissionRequest{
+		SourceCode:           program.Code,
+		LanguageID:           program.LanguageID,
+		Stdin:                stdin,
+		TimeLimit:            toolTimeLimit,
+		MemoryLimit:          question.Memory_limit,
+		CommandLineArguments: args,
+	})
+	if errors.Is(err, judge0.ErrNoStatus) {
+		return nil, nil
+	}
+	return status, err
+}
+
+// finished reports whether a program ran to a normal exit. Tools are run
+// without an expected output, which Judge0 may still judge as wrong.
+func finished(status *judge0.SubmissionStatus) bool {
+	return status.Status.ID == judge0.StatusAccepted || status.Status.ID == judge0.StatusWrongAnswer
+}
+
+// failure describes why a tool did not finish, with what it printed.
+func failure(status *judge0.SubmissionStatus) string {
+	if status == nil {
+		return "no result from the execution backend"
+	}
+	message := status.Status.Description
+	for _, detail := range []string{status.CompileOutput, status.Stderr, status.Stdout} {
+		if detail = strings.TrimSpace(detail); detail != "" {
+			return message + ": " + excerpt(detail)
+		}
+	}
+	return message
+}
+
+func referenceSolution(solutions []types.Solution, name string) (types.Solution, error) {
+	for _, solution := range solutions {
+		if solution.Expected != types.SolutionAccepted {
+			continue
+		}
+		if name == "" || solution.Name == name {
+			return solution, nil
+		}
+	}
+	if name != "" {
+		return types.Solution{}, storage.Validation("question has no reference solution named %q", name)
+	}
+	return types.Solution{}, storage.Validation("question has no reference solution to produce expected outputs")
+}
@@ -0,0 +1,189 @@
+package problempkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxArchiveSize is the largest package accepted, compressed
+	MaxArchiveSize = 64 << 20
+	// maxFileSize bounds any one file in a package once uncompressed
+	maxFileSize = 8 << 20
+	// maxTotalSize bounds everything read from a package, against zip bombs
+	maxTotalSize = 256 << 20
+)
+
+// archive is an opened package zip. Names are relative to the package root,
+// which is the top-level directory when the whole package was zipped inside
+// one.
+type archive struct {
+	files  map[string]*zip.File
+	read   int64
+	report *issues
+}
+
+func openArchive(r io.ReaderAt, size int64, report *issues) (*archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a zip archive: %v", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+		if strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") {
+			report.errorf(f.Name, "path leaves the package")
+			continue
+		}
+		if strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store" {
+			continue
+		}
+		files[name] = f
+	}
+
+	a := &archive{files: files, report: report}
+	if root := a.root(); root != "" {
+		trimmed := map[string]*zip.File{}
+		for name, f := range files {
+			if rest, ok := strings.CutPrefix(name, root+"/"); ok {
+				trimmed[rest] = f
+			}
+		}
+		a.files = trimmed
+	}
+	return a, nil
+}
+
+// root returns the directory holding the package, if it is not the top of
+// the zip: a single top-level directory with everything inside it.
+func (a *archive) root() string {
+	for _, marker := range []string{"problem.yaml", "problem.xml"} {
+		if a.has(marker) {
+			return ""
+		}
+	}
+	dir := ""
+	for name := range a.files {
+		top, _, nested := strings.Cut(name, "/")
+		if !nested || (dir != "" && top != dir) {
+			return ""
+		}
+		dir = top
+	}
+	return dir
+}
+
+func (a *archive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+// list returns the files under dir, sorted, with dir stripped. An empty dir
+// lists every file.
+func (a *archive) list(dir string) []string {
+	prefix := dir + "/"
+	if dir == "" {
+		prefix = ""
+	}
+	names := []string{}
+	for name := range a.files {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bytes reads a file, reporting and returning false if it is missing or too
+// large.
+func (a *archive) bytes(name string) ([]byte, bool) {
+	f, ok := a.files[name]
+	if !ok {
+		a.report.errorf(name, "file is missing")
+		return nil, false
+	}
+	if f.UncompressedSize64 > maxFileSize {
+		a.report.errorf(name, "file is larger than %d MiB", maxFileSize>>20)
+		return nil, false
+	}
+	if a.read+int64(f.UncompressedSize64) > maxTotalSize {
+		a.report.errorf(name, "package is larger than %d MiB uncompressed", maxTotalSize>>20)
+		return nil, false
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		a.report.errorf(name, "cannot open: %v", err)
+		return nil, false
+	}
+	defer rc.Close()
+	// The header's size can lie, so the read itself is bounded too
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err != nil {
+		a.report.errorf(name, "cannot read: %v", err)
+		return nil, false
+	}
+	if len(data) > maxFileSize {
+		a.report.errorf(name, "file is larger than %d MiB", maxFileSize>>20)
+		return nil, false
+	}
+	a.read += int64(len(data))
+	return data, true
+}
+
+// text reads a UTF-8 text file with line endings normalised to \n.
+func (a *archive) text(name string) (string, bool) {
+	data, ok := a.bytes(name)
+	if !ok {
+		return "", false
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		a.report.errorf(name, "file is not valid UTF-8")
+		return "", false
+	}
+	if bytes.Contains(data, []byte("\r\n")) {
+		a.report.crlf = true
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return string(data), true
+}
+
+// first returns the first of names present in the package.
+func (a *archive) first(names ...string) (string, bool) {
+	for _, name := range names {
+		if a.has(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// writer writes a package zip.
+type writer struct {
+	zw  *zip.Writer
+	err error
+}
+
+func (w *writer) file(name, content string) {
+	if w.err != nil {
+		return
+	}
+	f, err := w.zw.Create(name)
+	if err != nil {
+		w.err = err
+		return
+	}
+	_, w.err = io.WriteString(f, content)
+}
@@ -0,0 +1,354 @@
+package problempkg
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// kattisConfig is problem.yaml, in both the legacy format and the 2023
+// draft. Name and keywords may be a string or a list or map.
+type kattisConfig struct {
+	Name           interface{} `yaml:"name"`
+	Type           string      `yaml:"type"`
+	Validation     string      `yaml:"validation"`
+	ValidatorFlags string      `yaml:"validator_flags"`
+	Keywords       interface{} `yaml:"keywords"`
+	Limits         struct {
+		TimeLimit float64 `yaml:"time_limit"`
+		Memory    int     `yaml:"memory"`
+	} `yaml:"limits"`
+}
+
+// kattisExport is the problem.yaml written on export, in the 2023 draft
+// format.
+type kattisExport struct {
+	ProblemFormatVersion string   `yaml:"problem_format_version"`
+	Name                 string   `yaml:"name"`
+	Keywords             []string `yaml:"keywords,omitempty"`
+	ValidatorFlags       string   `yaml:"validator_flags,omitempty"`
+	Limits               struct {
+		TimeLimit float64 `yaml:"time_limit,omitempty"`
+		Memory    int     `yaml:"memory,omitempty"`
+	} `yaml:"limits,omitempty"`
+}
+
+var kattisStatements = []string{
+	"statement/problem.en.md",
+	"problem_statement/problem.en.md",
+	"problem_statement/problem.md",
+	"statement/problem.en.tex",
+	"problem_statement/problem.en.tex",
+	"problem_statement/problem.tex",
+}
+
+var (
+	mdTitle   = regexp.MustCompile(`^\s*#[ \t]+(.+?)[ \t#]*\n`)
+	mdHeading = regexp.MustCompile(`(?m)^#{1,3}[ \t]+(.+?)[ \t#]*$`)
+)
+
+func readKattis(a *archive, problem *Problem) {
+	report := a.report
+	q := &problem.Question
+
+	var cfg kattisConfig
+	if text, ok := a.text("problem.yaml"); ok {
+		if err := yaml.Unmarshal([]byte(text), &cfg); err != nil {
+			report.errorf("problem.yaml", "cannot parse: %v", err)
+		}
+	}
+
+	q.Title = kattisName(cfg.Name)
+	q.Tags = kattisKeywords(cfg.Keywords)
+	if cfg.Type == "interactive" || strings.Contains(cfg.Validation, "interactive") {
+		report.errorf("problem.yaml", "interactive problems are not supported")
+	}
+
+	switch {
+	case cfg.Limits.TimeLimit > 0:
+		q.Cpu_time_limit = int(math.Round(cfg.Limits.TimeLimit * 1000))
+	case a.has(".timelimit"):
+		text, _ := a.text(".timelimit")
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil || seconds <= 0 {
+			report.errorf(".timelimit", "not a time limit in seconds")
+		}
+		q.Cpu_time_limit = int(math.Round(seconds * 1000))
+	default:
+		report.warnf("problem.yaml", "no time limit given, so the judge's default applies")
+	}
+	if cfg.Limits.Memory > 0 {
+		q.Memory_limit = cfg.Limits.Memory * 1024
+	} else {
+		report.warnf("problem.yaml", "no memory limit given, so the judge's default applies")
+	}
+
+	readKattisStatement(a, q)
+	q.Checker = readKattisChecker(a, cfg)
+
+	for _, group := range []struct {
+		dir        string
+		visibility types.Visibility
+	}{
+		{"data/sample", types.VisibilityPublic},
+		{"data/secret", types.VisibilityPrivate},
+	} {
+		problem.TestCases = append(problem.TestCases, readKattisData(a, group.dir, group.visibility)...)
+	}
+}
+
+func readKattisStatement(a *archive, q *types.Question) {
+	report := a.report
+	file, ok := a.first(kattisStatements...)
+	if !ok {
+		// Fall back to a statement in any language
+		for _, dir := range []string{"statement", "problem_statement"} {
+			for _, name := range a.list(dir) {
+				if matched, _ := path.Match("problem.*.*", name); matched && (path.Ext(name) == ".md" || path.Ext(name) == ".tex") {
+					file, ok = dir+"/"+name, true
+					report.warnf(file, "no English statement, so this one was used")
+					break
+				}
+			}
+			if ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		report.errorf("problem_statement", "no problem statement found")
+		return
+	}
+
+	text, ok := a.text(file)
+	if !ok {
+		return
+	}
+
+	var parts map[section]string
+	if path.Ext(file) == ".tex" {
+		title, body := texDocument(text)
+		if q.Title == "" {
+			q.Title = title
+		}
+		parts = texSections(body)
+		report.warnf(file, "statement was converted from LaTeX; check its formatting")
+	} else {
+		// A leading top-level heading is the title
+		if m := mdTitle.FindStringSubmatchIndex(text); m != nil {
+			if q.Title == "" {
+				q.Title = text[m[2]:m[3]]
+			}
+			text = text[m[1]:]
+		}
+		headings := []heading{}
+		for _, m := range mdHeading.FindAllStringSubmatchIndex(text, -1) {
+			headings = append(headings, heading{start: m[0], end: m[1], title: text[m[2]:m[3]]})
+		}
+		parts = splitSections(text, headings)
+	}
+	q.Statement = statementFrom(parts, file, report)
+}
+
+// readKattisChecker returns the custom output validator, or the default
+// one with its flags.
+func readKattisChecker(a *archive, cfg kattisConfig) *types.Checker {
+	report := a.report
+	dir, files := "output_validator", a.list("output_validator")
+	if len(files) == 0 {
+		for _, name := range a.list("output_validators") {
+			validator, _, _ := strings.Cut(name, "/")
+			dir = "output_validators/" + validator
+			files = a.list(dir)
+			break
+		}
+	}
+
+	if len(files) == 0 {
+		if strings.HasPrefix(cfg.Validation, "custom") {
+			report.errorf("output_validators", "problem.yaml asks for a custom validator but there is none")
+		}
+		if cfg.ValidatorFlags == "" {
+			return nil
+		}
+		return &types.Checker{Name: "default", Args: cfg.ValidatorFlags}
+	}
+
+	sources := []string{}
+	for _, name := range files {
+		if _, ok := extensionLanguages[path.Ext(name)]; ok {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) == 0 {
+		report.warnf(dir, "output validator has no source file in a known language, so it was not kept")
+		return nil
+	}
+	if len(files) > 1 {
+		report.warnf(dir, "output validator has %d files; only %s was kept", len(files), sources[0])
+	}
+	source, ok := a.text(dir + "/" + sources[0])
+	if !ok {
+		return nil
+	}
+	return &types.Checker{
+		Name:     sources[0],
+		Language: extensionLanguages[path.Ext(sources[0])],
+		Source:   source,
+		Args:     cfg.ValidatorFlags,
+	}
+}
+
+// readKattisData reads the .in/.ans pairs under dir, including test groups
+// in subdirectories, in name order.
+func readKattisData(a *archive, dir string, visibility types.Visibility) []types.TestCase {
+	report := a.report
+	names := a.list(dir)
+	present := map[string]bool{}
+	for _, name := range names {
+		present[name] = true
+	}
+
+	testCases := []types.TestCase{}
+	for _, name := range names {
+		file := dir + "/" + name
+		switch path.Ext(name) {
+		case ".in":
+			answer := strings.TrimSuffix(name, ".in") + ".ans"
+			if !present[answer] {
+				report.errorf(file, "input has no answer file %s", answer)
+				continue
+			}
+			input, ok := a.text(file)
+			output, ok2 := a.text(dir + "/" + answer)
+			if !ok || !ok2 {
+				continue
+			}
+			testCases = append(testCases, types.TestCase{Input: input, ExpectedOutput: output, Visibility: visibility})
+		case ".ans":
+			if !present[strings.TrimSuffix(name, ".ans")+".in"] {
+				report.errorf(file, "answer has no input file")
+			}
+		case ".interaction":
+			report.errorf(file, "interactive problems are not supported")
+		}
+	}
+	return testCases
+}
+
+func kattisName(name interface{}) string {
+	switch name := name.(type) {
+	case string:
+		return strings.TrimSpace(name)
+	case map[string]interface{}:
+		if en, ok := name["en"].(string); ok {
+			return strings.TrimSpace(en)
+		}
+		langs := make([]string, 0, len(name))
+		for lang := range name {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			if s, ok := name[lang].(string); ok {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+func kattisKeywords(keywords interface{}) []string {
+	tags := []string{}
+	switch keywords := keywords.(type) {
+	case string:
+		tags = strings.Fields(keywords)
+	case []interface{}:
+		for _, k := range keywords {
+			if s := strings.TrimSpace(fmt.Sprint(k)); s != "" {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return tags
+}
+
+func writeKattis(w *writer, question *types.QuestionDetail) {
+	cfg := kattisExport{
+		ProblemFormatVersion: "2023-07-draft",
+		Name:                 question.Title,
+		Keywords:             question.Tags,
+	}
+	cfg.Limits.TimeLimit = float64(question.Cpu_time_limit) / 1000
+	// Kattis limits memory in whole MiB
+	cfg.Limits.Memory = (question.Memory_limit + 1023) / 1024
+
+	if c := question.Checker; c != nil {
+		flags, custom := kattisChecker(c)
+		cfg.ValidatorFlags = flags
+		if custom {
+			w.file("output_validator/"+sourceName(c, "validator"), c.Source)
+		}
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.file("problem.yaml", string(data))
+
+	s := statementOf(question)
+	var b strings.Builder
+	b.WriteString(s.Legend + "\n")
+	for _, part := range []struct{ heading, text string }{
+		{"Input", s.InputFormat},
+		{"Output", s.OutputFormat},
+		{"Constraints", s.Constraints},
+		{"Notes", s.Notes},
+	} {
+		if part.text != "" {
+			fmt.Fprintf(&b, "\n## %s\n\n%s\n", part.heading, part.text)
+		}
+	}
+	w.file("statement/problem.en.md", b.String())
+
+	samples, secret := 0, 0
+	for _, tc := range question.TestCases {
+		var name string
+		if tc.Visibility == types.VisibilityPublic {
+			samples++
+			name = fmt.Sprintf("data/sample/%03d", samples)
+		} else {
+			secret++
+			name = fmt.Sprintf("data/secret/%03d", secret)
+		}
+		w.file(name+".in", dataText(tc.Input))
+		w.file(name+".ans", dataText(tc.ExpectedOutput))
+	}
+}
+
+// kattisChecker maps a checker onto Kattis's default validator flags, or
+// reports that its source must be written out as a custom validator.
+func kattisChecker(c *types.Checker) (flags string, custom bool) {
+	switch c.Name {
+	case "default":
+		return c.Args, false
+	case "std::wcmp.cpp", "std::lcmp.cpp", "std::ncmp.cpp", "std::fcmp.cpp":
+		return "", false
+	case "std::rcmp4.cpp":
+		return "float_tolerance 1e-4", false
+	case "std::rcmp.cpp", "std::rcmp6.cpp":
+		return "float_tolerance 1e-6", false
+	case "std::rcmp9.cpp":
+		return "float_tolerance 1e-9", false
+	}
+	return c.Args, c.Source != ""
+}
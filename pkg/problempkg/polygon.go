@@ -0,0 +1,377 @@
+package problempkg
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// polygonProblem is the part of problem.xml mapped onto a question.
+type polygonProblem struct {
+	XMLName    xml.Name           `xml:"problem"`
+	Revision   int                `xml:"revision,attr,omitempty"`
+	ShortName  string             `xml:"short-name,attr,omitempty"`
+	Names      []polygonName      `xml:"names>name"`
+	Statements []polygonStatement `xml:"statements>statement"`
+	Testsets   []polygonTestset   `xml:"judging>testset"`
+	Checker    *polygonChecker    `xml:"assets>checker"`
+	Interactor *struct{}          `xml:"assets>interactor"`
+	Tags       []polygonTag       `xml:"tags>tag"`
+}
+
+type polygonName struct {
+	Language string `xml:"language,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+type polygonStatement struct {
+	Language string `xml:"language,attr"`
+	Path     string `xml:"path,attr"`
+	Type     string `xml:"type,attr"`
+}
+
+type polygonTestset struct {
+	Name          string        `xml:"name,attr"`
+	TimeLimit     int           `xml:"time-limit"`
+	MemoryLimit   int64         `xml:"memory-limit"`
+	TestCount     int           `xml:"test-count"`
+	InputPattern  string        `xml:"input-path-pattern"`
+	AnswerPattern string        `xml:"answer-path-pattern"`
+	Tests         []polygonTest `xml:"tests>test"`
+}
+
+type polygonTest struct {
+	Method string `xml:"method,attr"`
+	Cmd    string `xml:"cmd,attr,omitempty"`
+	Sample bool   `xml:"sample,attr,omitempty"`
+}
+
+type polygonChecker struct {
+	Name   string        `xml:"name,attr,omitempty"`
+	Type   string        `xml:"type,attr"`
+	Source polygonSource `xml:"source"`
+}
+
+type polygonSource struct {
+	Path string `xml:"path,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type polygonTag struct {
+	Value string `xml:"value,attr"`
+}
+
+// polygonProperties is statements/<language>/problem-properties.json.
+type polygonProperties struct {
+	Name   string `json:"name"`
+	Legend string `json:"legend"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Notes  string `json:"notes"`
+}
+
+func readPolygon(a *archive, problem *Problem) {
+	report := a.report
+	q := &problem.Question
+
+	text, ok := a.text("problem.xml")
+	if !ok {
+		return
+	}
+	var p polygonProblem
+	if err := xml.Unmarshal([]byte(text), &p); err != nil {
+		report.errorf("problem.xml", "cannot parse: %v", err)
+		return
+	}
+	if p.Interactor != nil {
+		report.errorf("problem.xml", "interactive problems are not supported")
+	}
+
+	language := ""
+	for _, name := range p.Names {
+		if name.Language == "english" || language == "" {
+			language, q.Title = name.Language, strings.TrimSpace(name.Value)
+		}
+	}
+	if language == "" {
+		language = "english"
+	}
+	for _, tag := range p.Tags {
+		q.Tags = append(q.Tags, tag.Value)
+	}
+
+	readPolygonStatement(a, p, language, q)
+	q.Checker = readPolygonChecker(a, p.Checker)
+
+	if len(p.Testsets) == 0 {
+		report.errorf("problem.xml", "no testset")
+		return
+	}
+	testset := p.Testsets[0]
+	for _, ts := range p.Testsets {
+		if ts.Name == "tests" {
+			testset = ts
+		}
+	}
+	if len(p.Testsets) > 1 {
+		report.warnf("problem.xml", "only the %q testset was imported", testset.Name)
+	}
+	q.Cpu_time_limit = testset.TimeLimit
+	q.Memory_limit = int(testset.MemoryLimit / 1024)
+	if q.Cpu_time_limit == 0 {
+		report.warnf("problem.xml", "no time limit given, so the judge's default applies")
+	}
+	if q.Memory_limit == 0 {
+		report.warnf("problem.xml", "no memory limit given, so the judge's default applies")
+	}
+
+	problem.TestCases = readPolygonTests(a, testset)
+}
+
+func readPolygonStatement(a *archive, p polygonProblem, language string, q *types.Question) {
+	report := a.report
+	fallback := func(file string) {
+		if language != "english" {
+			report.warnf(file, "no English statement, so the %s one was used", language)
+		}
+		report.warnf(file, "statement was converted from LaTeX; check its formatting")
+	}
+
+	// Sections as separate files, as in full packages
+	dir := "statement-sections/" + language
+	if a.has(dir + "/legend.tex") {
+		parts := map[section]string{}
+		for s, name := range map[section]string{
+			sectionLegend: "legend.tex",
+			sectionInput:  "input.tex",
+			sectionOutput: "output.tex",
+			sectionNotes:  "notes.tex",
+		} {
+			if a.has(dir + "/" + name) {
+				text, _ := a.text(dir + "/" + name)
+				parts[s] = texToMarkdown(text)
+			}
+		}
+		if a.has(dir + "/interaction.tex") {
+			parts[sectionInteraction] = "interactive"
+		}
+		q.Statement = statementFrom(parts, dir, report)
+		fallback(dir)
+		return
+	}
+
+	file := "statements/" + language + "/problem-properties.json"
+	if a.has(file) {
+		text, _ := a.text(file)
+		var props polygonProperties
+		if err := json.Unmarshal([]byte(text), &props); err != nil {
+			report.errorf(file, "cannot parse: %v", err)
+			return
+		}
+		q.Statement = statementFrom(map[section]string{
+			sectionLegend: texToMarkdown(props.Legend),
+			sectionInput:  texToMarkdown(props.Input),
+			sectionOutput: texToMarkdown(props.Output),
+			sectionNotes:  texToMarkdown(props.Notes),
+		}, file, report)
+		fallback(file)
+		return
+	}
+
+	// A whole problem.tex, as listed in problem.xml
+	for _, st := range p.Statements {
+		if st.Language != language || st.Type != "application/x-tex" || !a.has(st.Path) {
+			continue
+		}
+		text, _ := a.text(st.Path)
+		title, body := texDocument(text)
+		if q.Title == "" {
+			q.Title = title
+		}
+		q.Statement = statementFrom(texSections(body), st.Path, report)
+		fallback(st.Path)
+		return
+	}
+	report.errorf("statements", "no %s statement found", language)
+}
+
+func readPolygonChecker(a *archive, c *polygonChecker) *types.Checker {
+	report := a.report
+	if c == nil {
+		report.warnf("problem.xml", "no checker given, so output is compared exactly")
+		return nil
+	}
+	checker := &types.Checker{Name: c.Name, Language: polygonLanguage(c.Source.Type)}
+	if c.Source.Path != "" {
+		if a.has(c.Source.Path) {
+			checker.Source, _ = a.text(c.Source.Path)
+		} else if !strings.HasPrefix(c.Name, "std::") {
+			report.warnf(c.Source.Path, "checker source is missing from the package")
+		}
+		if checker.Name == "" {
+			checker.Name = path.Base(c.Source.Path)
+		}
+	}
+	if checker.Name == "" {
+		return nil
+	}
+	return checker
+}
+
+// readPolygonTests reads the testset's tests, which a standard package
+// leaves out when they are generated.
+func readPolygonTests(a *archive, testset polygonTestset) []types.TestCase {
+	report := a.report
+	inputs, answers := testset.InputPattern, testset.AnswerPattern
+	if inputs == "" {
+		inputs = "tests/%02d"
+	}
+	if answers == "" {
+		answers = inputs + ".a"
+	}
+
+	testCases := []types.TestCase{}
+	for i, test := range testset.Tests {
+		input := fmt.Sprintf(inputs, i+1)
+		answer := fmt.Sprintf(answers, i+1)
+		if !a.has(input) {
+			if test.Method == "generated" {
+				report.errorf(input, "test %d is generated by %q and not in the package; export a full package from Polygon", i+1, test.Cmd)
+			} else {
+				report.errorf(input, "test %d is missing", i+1)
+			}
+			continue
+		}
+		if !a.has(answer) {
+			report.errorf(answer, "test %d has no answer; export a full package from Polygon, which includes them", i+1)
+			continue
+		}
+		in, ok := a.text(input)
+		out, ok2 := a.text(answer)
+		if !ok || !ok2 {
+			continue
+		}
+		visibility := types.VisibilityPrivate
+		if test.Sample {
+			visibility = types.VisibilityPublic
+		}
+		testCases = append(testCases, types.TestCase{Input: in, ExpectedOutput: out, Visibility: visibility})
+	}
+	if testset.TestCount != len(testset.Tests) {
+		report.warnf("problem.xml", "testset says it has %d tests but lists %d", testset.TestCount, len(testset.Tests))
+	}
+	return testCases
+}
+
+// polygonLanguage maps a Polygon source type such as cpp.g++17 to a
+// language.
+func polygonLanguage(sourceType string) string {
+	t := strings.ToLower(sourceType)
+	for _, prefix := range []struct{ prefix, language string }{
+		{"cpp", "cpp"},
+		{"c.", "c"},
+		{"python", "python"},
+		{"pypy", "python"},
+		{"java", "java"},
+		{"kotlin", "kotlin"},
+		{"rust", "rust"},
+		{"go", "go"},
+		{"pas", "pascal"},
+		{"delphi", "pascal"},
+	} {
+		if strings.HasPrefix(t, prefix.prefix) {
+			return prefix.language
+		}
+	}
+	return t
+}
+
+var polygonSourceTypes = map[string]string{
+	"c":      "c.gcc",
+	"cpp":    "cpp.g++17",
+	"python": "python.3",
+	"java":   "java11",
+	"kotlin": "kotlin",
+	"rust":   "rust",
+	"go":     "go",
+	"pascal": "pas.fpc",
+}
+
+func writePolygon(w *writer, question *types.QuestionDetail) {
+	p := polygonProblem{
+		Revision:  1,
+		ShortName: Slug(question.Title),
+		Names:     []polygonName{{Language: "english", Value: question.Title}},
+	}
+	for _, tag := range question.Tags {
+		p.Tags = append(p.Tags, polygonTag{Value: tag})
+	}
+
+	testset := polygonTestset{
+		Name:          "tests",
+		TimeLimit:     question.Cpu_time_limit,
+		MemoryLimit:   int64(question.Memory_limit) * 1024,
+		TestCount:     len(question.TestCases),
+		InputPattern:  "tests/%02d",
+		AnswerPattern: "tests/%02d.a",
+	}
+	for i, tc := range question.TestCases {
+		testset.Tests = append(testset.Tests, polygonTest{Method: "manual", Sample: tc.Visibility == types.VisibilityPublic})
+		w.file(fmt.Sprintf("tests/%02d", i+1), dataText(tc.Input))
+		w.file(fmt.Sprintf("tests/%02d.a", i+1), dataText(tc.ExpectedOutput))
+	}
+	p.Testsets = []polygonTestset{testset}
+
+	if c := question.Checker; c != nil {
+		p.Checker = polygonCheckerFor(c)
+		if c.Source != "" {
+			p.Checker.Source = polygonSource{
+				Path: "files/" + sourceName(c, "check"),
+				Type: polygonSourceTypes[c.Language],
+			}
+			w.file(p.Checker.Source.Path, c.Source)
+		}
+	}
+
+	data, err := xml.MarshalIndent(p, "", "    ")
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.file("problem.xml", xml.Header+string(data)+"\n")
+
+	s := statementOf(question)
+	dir := "statement-sections/english/"
+	w.file(dir+"name.tex", question.Title)
+	w.file(dir+"legend.tex", markdownToTex(s.Legend))
+	input := s.InputFormat
+	if s.Constraints != "" {
+		// Polygon has no constraints section; they usually close the input
+		input = strings.TrimSpace(input + "\n\n" + s.Constraints)
+	}
+	w.file(dir+"input.tex", markdownToTex(input))
+	w.file(dir+"output.tex", markdownToTex(s.OutputFormat))
+	if s.Notes != "" {
+		w.file(dir+"notes.tex", markdownToTex(s.Notes))
+	}
+}
+
+// polygonCheckerFor maps a checker onto a Polygon one, turning Kattis's
+// default validator into the matching standard checker.
+func polygonCheckerFor(c *types.Checker) *polygonChecker {
+	switch {
+	case strings.HasPrefix(c.Name, "std::"):
+		return &polygonChecker{Name: c.Name, Type: "testlib"}
+	case c.Name != "default":
+		return &polygonChecker{Type: "testlib"}
+	}
+	name := "std::wcmp.cpp"
+	if strings.Contains(c.Args, "float_tolerance") || strings.Contains(c.Args, "float_absolute_tolerance") || strings.Contains(c.Args, "float_relative_tolerance") {
+		name = "std::rcmp6.cpp"
+	}
+	return &polygonChecker{Name: name, Type: "testlib"}
+}
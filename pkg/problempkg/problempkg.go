@@ -0,0 +1,343 @@
+// Package problempkg reads and writes whole problems as zip archives in the
+// Kattis/ICPC problem package format and the Codeforces Polygon package
+// format: statement, limits, test data and checker.
+package problempkg
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+type Format string
+
+const (
+	FormatKattis  Format = "kattis"
+	FormatPolygon Format = "polygon"
+)
+
+// ParseFormat parses a format name; "icpc" is an alias for kattis, and ""
+// leaves the format to be detected.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return "", nil
+	case "kattis", "icpc":
+		return FormatKattis, nil
+	case "polygon":
+		return FormatPolygon, nil
+	}
+	return "", fmt.Errorf("unknown package format %q, expected kattis or polygon", name)
+}
+
+// Problem is a question and its test cases, in order, read from a package.
+type Problem struct {
+	Question  types.Question
+	TestCases []types.TestCase
+}
+
+// Issue is a problem found in a package. File is relative to the package
+// root and empty for problems with the package as a whole.
+type Issue struct {
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report describes what an import found. A package with any errors is not
+// Valid and must not be imported; warnings point out what was lost or
+// guessed in mapping it onto a question.
+type Report struct {
+	Format      Format `json:"format"`
+	Valid       bool   `json:"valid"`
+	DryRun      bool   `json:"dry_run"`
+	Title       string `json:"title,omitempty"`
+	TimeLimit   int    `json:"cpu_time_limit"`
+	MemoryLimit int    `json:"memory_limit"`
+	Checker     string `json:"checker,omitempty"`
+	TestCases   int    `json:"test_cases"`
+	Samples     int    `json:"samples"`
+	QuestionID  string `json:"question_id,omitempty"`
+	issues
+}
+
+// issues collects what is wrong with an archive as it is read.
+type issues struct {
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+
+	crlf bool
+}
+
+func newIssues() issues {
+	return issues{Errors: []Issue{}, Warnings: []Issue{}}
+}
+
+func (r *issues) errorf(file, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Issue{File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *issues) warnf(file, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+// Read reads a package zip of the given format, or of whichever format it
+// looks like if format is empty. Only an unreadable archive is an error;
+// everything wrong inside it goes in the report, and the problem is only
+// usable when the report is Valid.
+func Read(r io.ReaderAt, size int64, format Format) (*Problem, *Report, error) {
+	report := &Report{issues: newIssues()}
+	a, err := openArchive(r, size, &report.issues)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if format == "" {
+		switch {
+		case a.has("problem.xml"):
+			format = FormatPolygon
+		case a.has("problem.yaml") || len(a.list("data")) > 0:
+			format = FormatKattis
+		default:
+			return nil, nil, errors.New("cannot tell the package format: expected problem.yaml (kattis) or problem.xml (polygon)")
+		}
+	}
+	report.Format = format
+
+	problem := &Problem{}
+	switch format {
+	case FormatKattis:
+		readKattis(a, problem)
+	case FormatPolygon:
+		readPolygon(a, problem)
+	default:
+		return nil, nil, fmt.Errorf("unknown package format %q", format)
+	}
+
+	check(problem, report)
+	return problem, report, nil
+}
+
+// check fills in the summary and validates the problem as the API would.
+func check(problem *Problem, report *Report) {
+	q := &problem.Question
+	if q.Tags == nil {
+		q.Tags = []string{}
+	}
+	if report.crlf {
+		report.warnf("", "Windows line endings were converted to \\n")
+	}
+	if len(problem.TestCases) == 0 {
+		report.errorf("", "package has no test data")
+	}
+
+	report.Title = q.Title
+	report.TimeLimit = q.Cpu_time_limit
+	report.MemoryLimit = q.Memory_limit
+	if q.Checker != nil {
+		report.Checker = q.Checker.Name
+	}
+	report.TestCases = len(problem.TestCases)
+	for _, tc := range problem.TestCases {
+		if tc.Visibility == types.VisibilityPublic {
+			report.Samples++
+		}
+	}
+
+	if err := validation.Struct(*q); err != nil {
+		var errs validator.ValidationErrors
+		if errors.As(err, &errs) {
+			for _, fe := range errs {
+				report.errorf("", "%s", validation.Message(fe))
+			}
+		} else {
+			report.errorf("", "%v", err)
+		}
+	}
+	report.Valid = len(report.Errors) == 0
+}
+
+// Write writes question and its test cases as a package zip. Public test
+// cases become samples.
+func Write(w io.Writer, question *types.QuestionDetail, format Format) error {
+	zw := zip.NewWriter(w)
+	pw := &writer{zw: zw}
+	switch format {
+	case FormatKattis:
+		writeKattis(pw, question)
+	case FormatPolygon:
+		writePolygon(pw, question)
+	default:
+		return fmt.Errorf("unknown package format %q", format)
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	return zw.Close()
+}
+
+// statementOf returns question's statement, falling back to its plain
+// description.
+func statementOf(question *types.QuestionDetail) types.Statement {
+	if question.Statement != nil {
+		return *question.Statement
+	}
+	return types.Statement{Legend: question.Description}
+}
+
+// dataText returns test data as the text fed to or expected from a
+// program, like the judge sends it.
+func dataText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+var slugUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug returns a file-name-safe short name for a title.
+func Slug(title string) string {
+	slug := strings.Trim(slugUnsafe.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	if slug == "" {
+		return "problem"
+	}
+	return slug
+}
+
+var extensionLanguages = map[string]string{
+	".c":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".cxx":  "cpp",
+	".py":   "python",
+	".java": "java",
+	".kt":   "kotlin",
+	".rs":   "rust",
+	".go":   "go",
+	".pas":  "pascal",
+}
+
+var languageExtensions = map[string]string{
+	"c":      ".c",
+	"cpp":    ".cpp",
+	"python": ".py",
+	"java":   ".java",
+	"kotlin": ".kt",
+	"rust":   ".rs",
+	"go":     ".go",
+	"pascal": ".pas",
+}
+
+// sourceName returns the file a checker's source is written to.
+func sourceName(checker *types.Checker, fallback string) string {
+	name := path.Base(checker.Name)
+	if name == "." || strings.Contains(name, "::") || path.Ext(name) == "" {
+		name = fallback
+	}
+	if path.Ext(name) == "" {
+		name += languageExtensions[checker.Language]
+	}
+	return name
+}
+
+// section names a part of a statement split at its headings.
+type section int
+
+const (
+	sectionLegend section = iota
+	sectionInput
+	sectionOutput
+	sectionConstraints
+	sectionNotes
+	// sectionSkipped is dropped, such as examples, which come from tests
+	sectionSkipped
+	sectionInteraction
+)
+
+var headingSections = map[string]section{
+	"input":               sectionInput,
+	"input format":        sectionInput,
+	"inputfile":           sectionInput,
+	"output":              sectionOutput,
+	"output format":       sectionOutput,
+	"outputfile":          sectionOutput,
+	"constraints":         sectionConstraints,
+	"limits":              sectionConstraints,
+	"note":                sectionNotes,
+	"notes":               sectionNotes,
+	"explanation":         sectionNotes,
+	"example":             sectionSkipped,
+	"examples":            sectionSkipped,
+	"sample":              sectionSkipped,
+	"samples":             sectionSkipped,
+	"sample input":        sectionSkipped,
+	"sample output":       sectionSkipped,
+	"sample input/output": sectionSkipped,
+	"interaction":         sectionInteraction,
+}
+
+// heading is a heading found in statement text.
+type heading struct {
+	start, end int
+	title      string
+}
+
+// splitSections splits text at the headings that name statement sections.
+// Headings not naming a section stay in the text.
+func splitSections(text string, headings []heading) map[section]string {
+	parts := map[section]string{}
+	current, from := sectionLegend, 0
+	for _, h := range headings {
+		// "Sample Input 2" is a sample heading like "Sample Input"
+		title := strings.TrimRight(strings.ToLower(strings.TrimSpace(h.title)), " 0123456789")
+		next, ok := headingSections[title]
+		if !ok {
+			continue
+		}
+		parts[current] += text[from:h.start]
+		current, from = next, h.end
+	}
+	parts[current] += text[from:]
+	for s, part := range parts {
+		parts[s] = strings.TrimSpace(part)
+	}
+	return parts
+}
+
+// statementFrom builds a statement from split sections, reporting what
+// could not be kept.
+func statementFrom(parts map[section]string, file string, report *issues) *types.Statement {
+	if parts[sectionInteraction] != "" {
+		report.errorf(file, "interactive problems are not supported")
+	}
+	s := &types.Statement{
+		Legend:       parts[sectionLegend],
+		InputFormat:  parts[sectionInput],
+		OutputFormat: parts[sectionOutput],
+		Constraints:  parts[sectionConstraints],
+		Notes:        parts[sectionNotes],
+	}
+	if s.Legend == "" && s.InputFormat == "" && s.OutputFormat == "" {
+		report.errorf(file, "statement is empty")
+	}
+	return s
+}
@@ -0,0 +1,204 @@
+package problempkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+func zipOf(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func read(t *testing.T, files map[string]string, format Format) (*Problem, *Report) {
+	t.Helper()
+	r := zipOf(t, files)
+	problem, report, err := Read(r, r.Size(), format)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return problem, report
+}
+
+func TestReadKattis(t *testing.T) {
+	problem, report := read(t, map[string]string{
+		"hello/problem.yaml":                     "name: Hello\nkeywords: [implementation, easy]\nvalidation: custom\nlimits:\n  time_limit: 1.5\n  memory: 256\n",
+		"hello/problem_statement/problem.en.md":  "Print a greeting for $n$ people.\n\n## Input\n\nOne integer $n$.\n\n## Output\n\nThe greeting.\n\n## Sample Input 1\n\nsee below\n",
+		"hello/data/sample/1.in":                 "1\r\n",
+		"hello/data/sample/1.ans":                "hi\r\n",
+		"hello/data/secret/g1/01.in":             "2\n",
+		"hello/data/secret/g1/01.ans":            "hi hi\n",
+		"hello/output_validators/check/check.py": "print('ok')\n",
+	}, "")
+
+	if !report.Valid {
+		t.Fatalf("report not valid: %+v", report.Errors)
+	}
+	if report.Format != FormatKattis || report.Samples != 1 || report.TestCases != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	q := problem.Question
+	if q.Title != "Hello" || q.Cpu_time_limit != 1500 || q.Memory_limit != 256*1024 {
+		t.Errorf("question = %q %d %d", q.Title, q.Cpu_time_limit, q.Memory_limit)
+	}
+	if len(q.Tags) != 2 || q.Tags[0] != "implementation" {
+		t.Errorf("tags = %v", q.Tags)
+	}
+	if q.Statement.Legend != "Print a greeting for $n$ people." || q.Statement.InputFormat != "One integer $n$." || q.Statement.OutputFormat != "The greeting." {
+		t.Errorf("statement = %+v", q.Statement)
+	}
+	if q.Checker == nil || q.Checker.Name != "check.py" || q.Checker.Language != "python" {
+		t.Errorf("checker = %+v", q.Checker)
+	}
+	if tc := problem.TestCases[0]; tc.Input != "1\n" || tc.Visibility != types.VisibilityPublic {
+		t.Errorf("sample = %+v", tc)
+	}
+	if tc := problem.TestCases[1]; tc.ExpectedOutput != "hi hi\n" || tc.Visibility != types.VisibilityPrivate {
+		t.Errorf("secret = %+v", tc)
+	}
+}
+
+func TestReadPolygon(t *testing.T) {
+	problem, report := read(t, map[string]string{
+		"problem.xml": `<?xml version="1.0" encoding="utf-8"?>
+<problem revision="3" short-name="sum">
+    <names><name language="russian" value="Сумма"/><name language="english" value="Sum"/></names>
+    <judging>
+        <testset name="tests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>2</test-count>
+            <input-path-pattern>tests/%02d</input-path-pattern>
+            <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+            <tests><test method="manual" sample="true"/><test cmd="gen 5" method="generated"/></tests>
+        </testset>
+    </judging>
+    <assets><checker name="std::ncmp.cpp" type="testlib"><source path="files/check.cpp" type="cpp.g++17"/></checker></assets>
+    <tags><tag value="math"/></tags>
+</problem>`,
+		"statement-sections/english/legend.tex": "Add \\textbf{two} numbers~$a$ and $b$ % comment\n",
+		"statement-sections/english/input.tex":  "Two integers $a, b$ ($1 \\le a, b \\le 10^9$).",
+		"statement-sections/english/output.tex": "\\begin{itemize}\n\\item Their sum.\n\\end{itemize}",
+		"tests/01":                              "1 2\n",
+		"tests/01.a":                            "3\n",
+		"tests/02":                              "5 5\n",
+		"tests/02.a":                            "10\n",
+	}, FormatPolygon)
+
+	if !report.Valid {
+		t.Fatalf("report not valid: %+v", report.Errors)
+	}
+	q := problem.Question
+	if q.Title != "Sum" || q.Cpu_time_limit != 2000 || q.Memory_limit != 256*1024 {
+		t.Errorf("question = %q %d %d", q.Title, q.Cpu_time_limit, q.Memory_limit)
+	}
+	if q.Statement.Legend != "Add **two** numbers $a$ and $b$" {
+		t.Errorf("legend = %q", q.Statement.Legend)
+	}
+	if q.Statement.InputFormat != "Two integers $a, b$ ($1 \\le a, b \\le 10^9$)." {
+		t.Errorf("input = %q", q.Statement.InputFormat)
+	}
+	if q.Statement.OutputFormat != "- Their sum." {
+		t.Errorf("output = %q", q.Statement.OutputFormat)
+	}
+	if q.Checker == nil || q.Checker.Name != "std::ncmp.cpp" || q.Checker.Language != "cpp" {
+		t.Errorf("checker = %+v", q.Checker)
+	}
+	if len(problem.TestCases) != 2 || problem.TestCases[0].Visibility != types.VisibilityPublic || problem.TestCases[1].Visibility != types.VisibilityPrivate {
+		t.Errorf("test cases = %+v", problem.TestCases)
+	}
+}
+
+func TestReadReportsErrors(t *testing.T) {
+	_, report := read(t, map[string]string{
+		"problem.yaml":                 "name: Broken\n",
+		"problem_statement/problem.md": "Legend.",
+		"data/sample/1.in":             "1\n",
+		"data/secret/1.in":             "\xff\xfe\n",
+		"data/secret/1.ans":            "2\n",
+		"data/secret/2.ans":            "3\n",
+	}, "")
+
+	if report.Valid {
+		t.Fatal("report is valid")
+	}
+	want := map[string]bool{"data/sample/1.in": false, "data/secret/1.in": false, "data/secret/2.ans": false}
+	for _, issue := range report.Errors {
+		if _, ok := want[issue.File]; ok {
+			want[issue.File] = true
+		}
+	}
+	for file, found := range want {
+		if !found {
+			t.Errorf("no error for %s in %+v", file, report.Errors)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	question := &types.QuestionDetail{
+		Title: "Round Trip",
+		Statement: &types.Statement{
+			Legend:       "Given **n**, print $n^2$.",
+			InputFormat:  "One integer $n$.",
+			OutputFormat: "One integer.",
+			Notes:        "Use `long long`.",
+		},
+		Tags:           []string{"math"},
+		Cpu_time_limit: 1000,
+		Memory_limit:   65536,
+		Checker:        &types.Checker{Name: "std::wcmp.cpp"},
+		TestCases: []types.TestCaseDetail{
+			{Input: "2\n", ExpectedOutput: "4\n", Visibility: types.VisibilityPublic},
+			{Input: "3\n", ExpectedOutput: "9\n", Visibility: types.VisibilityPrivate},
+		},
+	}
+
+	for _, format := range []Format{FormatKattis, FormatPolygon} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, question, format); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			r := bytes.NewReader(buf.Bytes())
+			problem, report, err := Read(r, r.Size(), "")
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !report.Valid || report.Format != format {
+				t.Fatalf("report = %+v", report)
+			}
+
+			q := problem.Question
+			if q.Title != question.Title || q.Cpu_time_limit != 1000 || q.Memory_limit != 65536 {
+				t.Errorf("question = %q %d %d", q.Title, q.Cpu_time_limit, q.Memory_limit)
+			}
+			if !reflect.DeepEqual(q.Statement, question.Statement) {
+				t.Errorf("statement = %+v", q.Statement)
+			}
+			if len(problem.TestCases) != 2 || problem.TestCases[1].ExpectedOutput != "9\n" || problem.TestCases[1].Visibility != types.VisibilityPrivate {
+				t.Errorf("test cases = %+v", problem.TestCases)
+			}
+			if strings.Join(q.Tags, ",") != "math" {
+				t.Errorf("tags = %v", q.Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package problempkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// ManifestName is the optional file in a test archive that orders its
+// tests and sets their visibility and subtask.
+const ManifestName = "manifest.json"
+
+// maxTests bounds the number of tests in one test archive
+const maxTests = 1000
+
+// Manifest lists an archive's tests in the order they are added. Tests it
+// leaves out follow in name order, private and in no subtask.
+type Manifest struct {
+	Tests []ManifestTest `json:"tests"`
+}
+
+type ManifestTest struct {
+	// Name is the file name without .in, e.g. "01" or "group1/03"
+	Name       string           `json:"name"`
+	Visibility types.Visibility `json:"visibility,omitempty"`
+	Subtask    int              `json:"subtask,omitempty"`
+}
+
+// TestSummary describes one test read from an archive.
+type TestSummary struct {
+	Name       string           `json:"name"`
+	Visibility types.Visibility `json:"visibility"`
+	Subtask    int              `json:"subtask,omitempty"`
+	InputSize  int              `json:"input_size"`
+	OutputSize int              `json:"output_size"`
+}
+
+// TestsReport describes what a test archive upload found, like Report does
+// for whole packages.
+type TestsReport struct {
+	Valid       bool          `json:"valid"`
+	DryRun      bool          `json:"dry_run"`
+	Replace     bool          `json:"replace"`
+	TestCases   int           `json:"test_cases"`
+	Public      int           `json:"public"`
+	Tests       []TestSummary `json:"tests"`
+	TestCaseIDs []string      `json:"test_case_ids,omitempty"`
+	issues
+}
+
+// ReadTests reads a zip of test data: NN.in files, each paired with an
+// NN.out (or NN.ans) expected output, and an optional manifest. Like Read,
+// only an unreadable archive is an error, and the test cases are only
+// usable when the report is Valid.
+func ReadTests(r io.ReaderAt, size int64) ([]types.TestCase, *TestsReport, error) {
+	report := &TestsReport{Tests: []TestSummary{}, issues: newIssues()}
+	a, err := openArchive(r, size, &report.issues)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inputs := map[string]string{}
+	outputs := map[string]string{}
+	for _, name := range a.list("") {
+		base, ext := strings.TrimSuffix(name, path.Ext(name)), path.Ext(name)
+		switch {
+		case name == ManifestName:
+		case ext == ".in":
+			inputs[base] = name
+		case ext == ".out" || ext == ".ans":
+			if other, ok := outputs[base]; ok {
+				report.errorf(name, "test %s already has expected output %s", base, other)
+				continue
+			}
+			outputs[base] = name
+		default:
+			report.warnf(name, "ignored: not a .in, .out or .ans file")
+		}
+	}
+	for base, name := range outputs {
+		if _, ok := inputs[base]; !ok {
+			report.errorf(name, "expected output has no %s.in", base)
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for base, name := range inputs {
+		if _, ok := outputs[base]; !ok {
+			report.errorf(name, "input has no %s.out or %s.ans", base, base)
+			continue
+		}
+		names = append(names, base)
+	}
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+	if len(inputs) == 0 {
+		report.errorf("", "archive has no .in files")
+	}
+	if len(names) > maxTests {
+		report.errorf("", "archive has %d tests, at most %d are allowed", len(names), maxTests)
+		names = nil
+	}
+
+	order := readManifest(a, names, report)
+	testCases := []types.TestCase{}
+	for _, entry := range order {
+		input, ok := a.text(inputs[entry.Name])
+		if !ok {
+			continue
+		}
+		output, ok := a.text(outputs[entry.Name])
+		if !ok {
+			continue
+		}
+		testCases = append(testCases, types.TestCase{
+			Input:          input,
+			ExpectedOutput: output,
+			Visibility:     entry.Visibility,
+			Subtask:        entry.Subtask,
+		})
+		report.Tests = append(report.Tests, TestSummary{
+			Name:       entry.Name,
+			Visibility: entry.Visibility,
+			Subtask:    entry.Subtask,
+			InputSize:  len(input),
+			OutputSize: len(output),
+		})
+		if entry.Visibility == types.VisibilityPublic {
+			report.Public++
+		}
+	}
+
+	if report.crlf {
+		report.warnf("", "Windows line endings were converted to \\n")
+	}
+	report.TestCases = len(testCases)
+	report.Valid = len(report.Errors) == 0
+	return testCases, report, nil
+}
+
+// readManifest returns the tests among names in the order and with the
+// settings the manifest gives them.
+func readManifest(a *archive, names []string, report *TestsReport) []ManifestTest {
+	order := []ManifestTest{}
+	listed := map[string]bool{}
+	if a.has(ManifestName) {
+		data, ok := a.bytes(ManifestName)
+		if !ok {
+			return nil
+		}
+		var manifest Manifest
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&manifest); err != nil {
+			report.errorf(ManifestName, "invalid manifest: %v", err)
+			return nil
+		}
+
+		known := map[string]bool{}
+		for _, name := range names {
+			known[name] = true
+		}
+		for _, test := range manifest.Tests {
+			test.Name = strings.TrimSuffix(test.Name, ".in")
+			switch {
+			case !known[test.Name]:
+				report.errorf(ManifestName, "test %q is not in the archive", test.Name)
+				continue
+			case listed[test.Name]:
+				report.errorf(ManifestName, "test %q is listed twice", test.Name)
+				continue
+			}
+			if test.Visibility == "" {
+				test.Visibility = types.VisibilityPrivate
+			}
+			if test.Visibility != types.VisibilityPublic && test.Visibility != types.VisibilityPrivate {
+				report.errorf(ManifestName, "test %q: visibility must be public or private", test.Name)
+			}
+			if test.Subtask < 0 || test.Subtask > 1000 {
+				report.errorf(ManifestName, "test %q: subtask must be between 0 and 1000", test.Name)
+			}
+			listed[test.Name] = true
+			order = append(order, test)
+		}
+	}
+
+	unlisted := 0
+	for _, name := range names {
+		if !listed[name] {
+			order = append(order, ManifestTest{Name: name, Visibility: types.VisibilityPrivate})
+			unlisted++
+		}
+	}
+	if len(listed) > 0 && unlisted > 0 {
+		report.warnf(ManifestName, "%d tests are not listed and were added last as private", unlisted)
+	}
+	return order
+}
+
+// naturalLess orders names with numbers by their value, so "2" comes
+// before "10" whether or not it is zero-padded.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, _ := strconv.Atoi(da)
+			nb, _ := strconv.Atoi(db)
+			if na != nb {
+				return na < nb
+			}
+			if da != db {
+				return len(da) < len(db)
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && i < 9 && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
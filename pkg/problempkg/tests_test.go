@@ -0,0 +1,109 @@
+package problempkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+func readTests(t *testing.T, files map[string]string) ([]types.TestCase, *TestsReport) {
+	t.Helper()
+	r := zipOf(t, files)
+	testCases, report, err := ReadTests(r, r.Size())
+	if err != nil {
+		t.Fatalf("ReadTests: %v", err)
+	}
+	return testCases, report
+}
+
+func TestReadTests(t *testing.T) {
+	testCases, report := readTests(t, map[string]string{
+		"tests/1.in":          "1\r\n",
+		"tests/1.out":         "1\r\n",
+		"tests/2.in":          "2\n",
+		"tests/2.ans":         "4\n",
+		"tests/10.in":         "10\n",
+		"tests/10.out":        "100\n",
+		"tests/README.txt":    "notes",
+		"tests/manifest.json": `{"tests": [{"name": "2", "visibility": "public", "subtask": 1}, {"name": "10.in", "subtask": 2}]}`,
+	})
+	if !report.Valid {
+		t.Fatalf("report not valid: %+v", report.Errors)
+	}
+
+	var got []string
+	for _, tc := range testCases {
+		got = append(got, strings.TrimSpace(tc.Input.(string))+":"+string(tc.Visibility)+":"+string(rune('0'+tc.Subtask)))
+	}
+	if want := "2:public:1 10:private:2 1:private:0"; strings.Join(got, " ") != want {
+		t.Errorf("tests = %v, want %s", got, want)
+	}
+	if testCases[2].ExpectedOutput != "1\n" {
+		t.Errorf("line endings not normalised: %q", testCases[2].ExpectedOutput)
+	}
+	if report.TestCases != 3 || report.Public != 1 || len(report.Warnings) != 3 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestReadTestsNaturalOrder(t *testing.T) {
+	testCases, report := readTests(t, map[string]string{
+		"10.in": "c", "10.out": "c",
+		"2.in": "b", "2.out": "b",
+		"01.in": "a", "01.out": "a",
+	})
+	if !report.Valid {
+		t.Fatalf("report not valid: %+v", report.Errors)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if testCases[i].Input != want || testCases[i].Visibility != types.VisibilityPrivate {
+			t.Errorf("test %d = %+v, want input %q", i, testCases[i], want)
+		}
+	}
+}
+
+func TestReadTestsReportsErrors(t *testing.T) {
+	_, report := readTests(t, map[string]string{
+		"1.in":          "1\n",
+		"2.in":          "2\n",
+		"2.out":         "2\n",
+		"2.ans":         "2\n",
+		"3.out":         "3\n",
+		"4.in":          "\xff\xfe",
+		"4.out":         "4\n",
+		"manifest.json": `{"tests": [{"name": "9"}, {"name": "2", "visibility": "hidden"}]}`,
+	})
+	if report.Valid {
+		t.Fatal("broken archive reported valid")
+	}
+	want := []string{
+		"1.in: input has no 1.out or 1.ans",
+		"2.out: test 2 already has expected output 2.ans",
+		"3.out: expected output has no 3.in",
+		"manifest.json: test \"9\" is not in the archive",
+		"manifest.json: test \"2\": visibility must be public or private",
+		"4.in: file is not valid UTF-8",
+	}
+	var got []string
+	for _, issue := range report.Errors {
+		got = append(got, issue.File+": "+issue.Message)
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			found = found || g == w
+		}
+		if !found {
+			t.Errorf("missing error %q in %v", w, got)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	for _, pair := range [][2]string{{"2", "10"}, {"01", "2"}, {"1", "01"}, {"a9", "a10"}, {"g1/10", "g2/01"}, {"a", "ab"}} {
+		if !naturalLess(pair[0], pair[1]) || naturalLess(pair[1], pair[0]) {
+			t.Errorf("naturalLess(%q, %q) wrong", pair[0], pair[1])
+		}
+	}
+}
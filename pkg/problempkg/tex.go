@@ -0,0 +1,199 @@
+package problempkg
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Statements in packages are usually LaTeX. Only the handful of commands
+// problem statements lean on are converted to Markdown; math is left as is
+// since statements keep LaTeX math between $ delimiters anyway. Anything
+// else passes through, which is why imports converted from LaTeX warn.
+
+var (
+	texComment  = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+	texHeading  = regexp.MustCompile(`\\(?:sub)*section\*?\{([^{}]*)\}|\\(InputFile|OutputFile|Examples?|Notes?|Interaction|Scoring)\b`)
+	texName     = regexp.MustCompile(`\\problemname\{([^{}]*)\}`)
+	texProblem  = regexp.MustCompile(`\\begin\{problem\}\{([^{}]*)\}(?:\{[^{}]*\}){0,4}`)
+	texExamples = regexp.MustCompile(`(?s)\\begin\{example\}.*?\\end\{example\}|\\exmp(?:file)?\{[^{}]*\}\{[^{}]*\}%?`)
+	texDisplay  = regexp.MustCompile(`(?s)\\\[(.*?)\\\]`)
+	texEnv      = regexp.MustCompile(`\\(?:begin|end)\{(?:itemize|enumerate|center|problem|document)\}`)
+	texItem     = regexp.MustCompile(`(?m)^\s*\\item\s*`)
+	texDropped  = regexp.MustCompile(`\\(?:illustration|includegraphics)(?:\[[^\]]*\])?(?:\{[^{}]*\}){1,3}`)
+	texBlank    = regexp.MustCompile(`\n{3,}`)
+)
+
+var texWrappers = []struct {
+	pattern     *regexp.Regexp
+	open, close string
+}{
+	{regexp.MustCompile(`\\textbf\{([^{}]*)\}`), "**", "**"},
+	{regexp.MustCompile(`\\(?:emph|textit)\{([^{}]*)\}`), "*", "*"},
+	{regexp.MustCompile(`\\(?:texttt|t|tt)\{([^{}]*)\}`), "`", "`"},
+	{regexp.MustCompile(`\\(?:underline|textrm|textsf|textsc|mbox)\{([^{}]*)\}`), "", ""},
+}
+
+var texUnescapes = strings.NewReplacer(`\%`, "%", `\&`, "&", `\#`, "#", `\{`, "{", `\}`, "}", "~", " ", "---", "—", "--", "–", "<<", "«", ">>", "»", `\\`, "\n")
+
+// texDocument strips what surrounds the text of a LaTeX statement and
+// returns its title, if it names one, and its body.
+func texDocument(tex string) (string, string) {
+	tex = texComment.ReplaceAllString(tex, "$1")
+	title := ""
+	if m := texName.FindStringSubmatch(tex); m != nil {
+		title = strings.TrimSpace(m[1])
+	}
+	if m := texProblem.FindStringSubmatch(tex); m != nil {
+		title = strings.TrimSpace(m[1])
+	}
+	if _, body, ok := strings.Cut(tex, `\begin{document}`); ok {
+		tex, _, _ = strings.Cut(body, `\end{document}`)
+	}
+	tex = texName.ReplaceAllString(tex, "")
+	tex = texProblem.ReplaceAllString(tex, "")
+	tex = texExamples.ReplaceAllString(tex, "")
+	return title, tex
+}
+
+// texSections splits a LaTeX statement at its \section headings and the
+// \InputFile style headings of Polygon's problem.tex.
+func texSections(tex string) map[section]string {
+	headings := []heading{}
+	for _, m := range texHeading.FindAllStringSubmatchIndex(tex, -1) {
+		h := heading{start: m[0], end: m[1]}
+		if m[2] >= 0 {
+			h.title = tex[m[2]:m[3]]
+		} else {
+			h.title = tex[m[4]:m[5]]
+		}
+		headings = append(headings, h)
+	}
+	parts := splitSections(tex, headings)
+	for s, part := range parts {
+		parts[s] = texToMarkdown(part)
+	}
+	return parts
+}
+
+// texToMarkdown converts LaTeX text to Markdown, leaving math alone.
+func texToMarkdown(tex string) string {
+	tex = texComment.ReplaceAllString(tex, "$1")
+	tex = texDropped.ReplaceAllString(tex, "")
+	tex = texDisplay.ReplaceAllString(tex, "$$$$$1$$$$")
+	tex = texEnv.ReplaceAllString(tex, "")
+	tex = texItem.ReplaceAllString(tex, "\n- ")
+
+	var b strings.Builder
+	for i, part := range splitMath(tex) {
+		if i%2 == 1 {
+			b.WriteString(part)
+			continue
+		}
+		// Innermost first, so nested commands unwrap from the inside out
+		for changed := true; changed; {
+			changed = false
+			for _, w := range texWrappers {
+				next := w.pattern.ReplaceAllString(part, w.open+"$1"+w.close)
+				changed = changed || next != part
+				part = next
+			}
+		}
+		b.WriteString(texUnescapes.Replace(part))
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(texBlank.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var (
+	mdBold   = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	mdItalic = regexp.MustCompile(`\*([^*\n]+)\*`)
+	mdCode   = regexp.MustCompile("`([^`\n]+)`")
+	mdItem   = regexp.MustCompile(`^\s*[-*+]\s+`)
+)
+
+var texEscapes = strings.NewReplacer("%", `\%`, "&", `\&`, "#", `\#`, "—", "---", "–", "--")
+
+// markdownToTex converts Markdown as written by texToMarkdown back to
+// LaTeX, leaving math alone.
+func markdownToTex(md string) string {
+	var b strings.Builder
+	for i, part := range splitMath(md) {
+		if i%2 == 1 {
+			b.WriteString(part)
+			continue
+		}
+		part = texEscapes.Replace(part)
+		part = mdCode.ReplaceAllString(part, `\texttt{$1}`)
+		part = mdBold.ReplaceAllString(part, `\textbf{$1}`)
+		part = mdItalic.ReplaceAllString(part, `\emph{$1}`)
+		b.WriteString(part)
+	}
+
+	var out []string
+	inList := false
+	for _, line := range strings.Split(b.String(), "\n") {
+		if loc := mdItem.FindStringIndex(line); loc != nil {
+			if !inList {
+				out = append(out, `\begin{itemize}`)
+				inList = true
+			}
+			out = append(out, `\item `+line[loc[1]:])
+			continue
+		}
+		if inList && strings.TrimSpace(line) == "" {
+			out = append(out, `\end{itemize}`)
+			inList = false
+		}
+		out = append(out, line)
+	}
+	if inList {
+		out = append(out, `\end{itemize}`)
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitMath splits text into alternating text and math, the math keeping
+// its $ or $$ delimiters. Escaped \$ is text.
+func splitMath(text string) []string {
+	parts := []string{}
+	start, i := 0, 0
+	for i < len(text) {
+		if text[i] == '\\' {
+			i += 2
+			continue
+		}
+		if text[i] != '$' {
+			i++
+			continue
+		}
+		delim := "$"
+		if strings.HasPrefix(text[i:], "$$") {
+			delim = "$$"
+		}
+		end := closingDollar(text, i+len(delim), delim)
+		if end < 0 {
+			break
+		}
+		parts = append(parts, text[start:i], text[i:end+len(delim)])
+		i = end + len(delim)
+		start = i
+	}
+	return append(parts, text[start:])
+}
+
+func closingDollar(text string, from int, delim string) int {
+	for i := from; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], delim) {
+			return i
+		}
+	}
+	return -1
+}
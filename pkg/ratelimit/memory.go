@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps limiter state in process memory. It is suitable for a
+// single instance; multi-instance deployments should share a Mongo store.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+	now    func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]State),
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.states[key]
+	if exists && state.ExpiresAt.Before(s.now()) {
+		delete(s.states, key)
+		return State{}, false, nil
+	}
+	return state, exists, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(state *State, exists bool)) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.states[key]
+	if exists && state.ExpiresAt.Before(s.now()) {
+		state, exists = State{}, false
+	}
+
+	fn(&state, exists)
+	s.states[key] = state
+	return state, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
+}
+
+// Sweep drops expired entries so long-running processes don't accumulate
+// state for every address that has ever connected.
+func (s *MemoryStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, state := range s.states {
+		if state.ExpiresAt.Before(now) {
+			delete(s.states, key)
+		}
+	}
+}
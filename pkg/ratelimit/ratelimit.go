@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket holding at most Burst tokens that is
+// refilled with one token every Interval.
+type Limit struct {
+	Interval time.Duration
+	Burst    int
+}
+
+// LockoutPolicy controls progressive lockout after repeated failures.
+// Every Threshold consecutive failures lock the key out for BaseDuration,
+// doubling with each further lockout up to MaxDuration. Failures older
+// than Window are forgotten.
+type LockoutPolicy struct {
+	Threshold    int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	Window       time.Duration
+}
+
+// State is the persisted bucket and failure bookkeeping for a single key.
+type State struct {
+	Tokens      float64   `bson:"tokens"`
+	RefilledAt  time.Time `bson:"refilled_at"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	Lockouts    int       `bson:"lockouts"`
+	LockedUntil time.Time `bson:"locked_until"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// Store persists limiter state. Update must apply fn and save the result
+// atomically with respect to other callers updating the same key.
+type Store interface {
+	Get(ctx context.Context, key string) (State, bool, error)
+	Update(ctx context.Context, key string, fn func(state *State, exists bool)) (State, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+type Lockout struct {
+	Locked   bool
+	Until    time.Time
+	Failures int
+	Lockouts int
+}
+
+type Limiter struct {
+	store  Store
+	policy LockoutPolicy
+	now    func() time.Time
+}
+
+func New(store Store, policy LockoutPolicy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Allow takes one token from the bucket for key. A limit without an
+// Interval allows everything.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Interval <= 0 {
+		return Result{Allowed: true}, nil
+	}
+	now := l.now()
+	var result Result
+
+	_, err := l.store.Update(ctx, "bucket:"+key, func(state *State, exists bool) {
+		result = Result{}
+		if !exists {
+			state.Tokens = float64(limit.Burst)
+			state.RefilledAt = now
+		}
+
+		elapsed := now.Sub(state.RefilledAt)
+		if elapsed > 0 && limit.Interval > 0 {
+			state.Tokens = math.Min(float64(limit.Burst), state.Tokens+float64(elapsed)/float64(limit.Interval))
+		}
+		state.RefilledAt = now
+
+		if state.Tokens >= 1 {
+			state.Tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = time.Duration((1 - state.Tokens) * float64(limit.Interval))
+		}
+		state.ExpiresAt = now.Add(time.Duration(limit.Burst) * limit.Interval)
+	})
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+
+	return result, nil
+}
+
+// LockedFor returns how long key remains locked out, or zero if it is not.
+func (l *Limiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	now := l.now()
+	state, exists, err := l.store.Get(ctx, "lockout:"+key)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	if state.LockedUntil.After(now) {
+		return state.LockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt for key and locks it out once the policy
+// threshold is reached. The returned Lockout reports Locked only for the
+// failure that triggered a new lockout.
+func (l *Limiter) Fail(ctx context.Context, key string) (Lockout, error) {
+	now := l.now()
+	var lockout Lockout
+
+	_, err := l.store.Update(ctx, "lockout:"+key, func(state *State, exists bool) {
+		lockout = Lockout{}
+		if l.policy.Window > 0 && now.Sub(state.LastFailure) > l.policy.Window {
+			state.Failures = 0
+		}
+		state.Failures++
+		state.LastFailure = now
+
+		if l.policy.Threshold > 0 && state.Failures >= l.policy.Threshold {
+			duration := l.policy.MaxDuration
+			if state.Lockouts < 32 {
+				if d := l.policy.BaseDuration << state.Lockouts; d > 0 && (duration <= 0 || d < duration) {
+					duration = d
+				}
+			}
+			state.Lockouts++
+			state.Failures = 0
+			state.LockedUntil = now.Add(duration)
+			lockout.Locked = true
+		}
+
+		lockout.Until = state.LockedUntil
+		lockout.Failures = state.Failures
+		lockout.Lockouts = state.Lockouts
+
+		state.ExpiresAt = now.Add(l.policy.Window)
+		if state.LockedUntil.Add(l.policy.MaxDuration).After(state.ExpiresAt) {
+			state.ExpiresAt = state.LockedUntil.Add(l.policy.MaxDuration)
+		}
+	})
+	if err != nil {
+		return Lockout{}, err
+	}
+
+	return lockout, nil
+}
+
+// Reset clears failures and lockout history for key after a success.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, "lockout:"+key)
+}
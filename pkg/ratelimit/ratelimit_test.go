@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a settable time for the limiter and its store.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(policy LockoutPolicy) (*Limiter, *MemoryStore, *clock) {
+	c := &clock{t: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = c.now
+	limiter := New(store, policy)
+	limiter.now = c.now
+	return limiter, store, c
+}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		after      time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "burst then refill",
+			limit: Limit{Interval: time.Second, Burst: 3},
+			steps: []step{
+				{0, true, 0},
+				{0, true, 0},
+				{0, true, 0},
+				{0, false, time.Second},
+				{500 * time.Millisecond, false, 500 * time.Millisecond},
+				{500 * time.Millisecond, true, 0},
+				{0, false, time.Second},
+			},
+		},
+		{
+			name:  "refill is capped at burst",
+			limit: Limit{Interval: time.Second, Burst: 2},
+			steps: []step{
+				{0, true, 0},
+				{0, true, 0},
+				{time.Minute, true, 0},
+				{0, true, 0},
+				{0, false, time.Second},
+			},
+		},
+		{
+			name:  "cooldown of one",
+			limit: Limit{Interval: 10 * time.Second, Burst: 1},
+			steps: []step{
+				{0, true, 0},
+				{4 * time.Second, false, 6 * time.Second},
+				{6 * time.Second, true, 0},
+			},
+		},
+		{
+			name:  "no interval is no limit",
+			limit: Limit{Burst: 1},
+			steps: []step{
+				{0, true, 0},
+				{0, true, 0},
+				{0, true, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, _, c := newTestLimiter(LockoutPolicy{})
+			for i, s := range tt.steps {
+				c.advance(s.after)
+				result, err := limiter.Allow(context.Background(), "key", tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != s.allowed || result.RetryAfter != s.retryAfter {
+					t.Errorf("step %d: got %+v, want allowed %v retry after %v", i, result, s.allowed, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowKeepsKeysApart(t *testing.T) {
+	limiter, _, _ := newTestLimiter(LockoutPolicy{})
+	limit := Limit{Interval: time.Minute, Burst: 1}
+	ctx := context.Background()
+	for _, key := range []string{"a", "b"} {
+		if result, _ := limiter.Allow(ctx, key, limit); !result.Allowed {
+			t.Errorf("first request for %s refused", key)
+		}
+	}
+	if result, _ := limiter.Allow(ctx, "a", limit); result.Allowed {
+		t.Error("second request for a allowed")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, BaseDuration: time.Minute, MaxDuration: 4 * time.Minute, Window: 15 * time.Minute}
+	type step struct {
+		after     time.Duration
+		fail      bool // Fail, or else Reset
+		locked    bool
+		lockedFor time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "threshold locks",
+			steps: []step{
+				{0, true, false, 0},
+				{0, true, false, 0},
+				{0, true, true, time.Minute},
+			},
+		},
+		{
+			name: "lockouts double up to the maximum",
+			steps: []step{
+				{0, true, false, 0}, {0, true, false, 0}, {0, true, true, time.Minute},
+				{time.Minute, true, false, 0}, {0, true, false, 0}, {0, true, true, 2 * time.Minute},
+				{2 * time.Minute, true, false, 0}, {0, true, false, 0}, {0, true, true, 4 * time.Minute},
+				{4 * time.Minute, true, false, 0}, {0, true, false, 0}, {0, true, true, 4 * time.Minute},
+			},
+		},
+		{
+			name: "failures outside the window are forgotten",
+			steps: []step{
+				{0, true, false, 0},
+				{0, true, false, 0},
+				{16 * time.Minute, true, false, 0},
+				{0, true, false, 0},
+				{0, true, true, time.Minute},
+			},
+		},
+		{
+			name: "reset clears failures and history",
+			steps: []step{
+				{0, true, false, 0}, {0, true, false, 0}, {0, true, true, time.Minute},
+				{time.Minute, false, false, 0},
+				{0, true, false, 0}, {0, true, false, 0}, {0, true, true, time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, _, c := newTestLimiter(policy)
+			ctx := context.Background()
+			for i, s := range tt.steps {
+				c.advance(s.after)
+				if s.fail {
+					lockout, err := limiter.Fail(ctx, "key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if lockout.Locked != s.locked {
+						t.Errorf("step %d: locked = %v, want %v", i, lockout.Locked, s.locked)
+					}
+				} else if err := limiter.Reset(ctx, "key"); err != nil {
+					t.Fatal(err)
+				}
+				lockedFor, err := limiter.LockedFor(ctx, "key")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if lockedFor != s.lockedFor {
+					t.Errorf("step %d: locked for %v, want %v", i, lockedFor, s.lockedFor)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	_, store, c := newTestLimiter(LockoutPolicy{})
+	ctx := context.Background()
+	store.Update(ctx, "key", func(state *State, exists bool) {
+		state.Failures = 1
+		state.ExpiresAt = c.now().Add(time.Minute)
+	})
+
+	if state, ok, _ := store.Get(ctx, "key"); !ok || state.Failures != 1 {
+		t.Fatalf("Get = %+v, %v before expiry", state, ok)
+	}
+	c.advance(2 * time.Minute)
+	store.Update(ctx, "key", func(state *State, exists bool) {
+		if exists || state.Failures != 0 {
+			t.Errorf("Update saw expired state %+v", state)
+		}
+		state.ExpiresAt = c.now().Add(-time.Second)
+	})
+	store.Sweep()
+	if len(store.states) != 0 {
+		t.Errorf("Sweep kept %d expired entries", len(store.states))
+	}
+}
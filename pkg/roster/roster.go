@@ -0,0 +1,328 @@
+package roster
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/mailer"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
+)
+
+const (
+	StatusValid   = "valid"
+	StatusCreated = "created"
+	StatusInvited = "invited"
+	StatusError   = "error"
+)
+
+// Row is one student from a roster CSV. Line is the 1-based line number in
+// the file so errors can be reported back against the spreadsheet.
+type Row struct {
+	Line      int
+	Name      string
+	Email     string
+	StudentId string
+	Role      string
+	Team      string
+}
+
+type Options struct {
+	DryRun    bool
+	Invite    bool
+	Mailer    mailer.Mailer
+	InviteURL string
+	InviteTTL time.Duration
+}
+
+type RowResult struct {
+	Line      int      `json:"line"`
+	Email     string   `json:"email,omitempty"`
+	StudentId string   `json:"studentId,omitempty"`
+	Status    string   `json:"status"`
+	UserID    string   `json:"user_id,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Total   int         `json:"total"`
+	Valid   int         `json:"valid"`
+	Created int         `json:"created"`
+	Failed  int         `json:"failed"`
+	Rows    []RowResult `json:"rows"`
+}
+
+var columnAliases = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"studentid":  "studentId",
+	"student_id": "studentId",
+	"student id": "studentId",
+	"role":       "role",
+	"team":       "team",
+}
+
+// Parse reads a roster with a header row naming the columns name, email,
+// studentId and optionally role and team, in any order. Only problems with
+// the file as a whole are returned as errors; per-row problems are left for
+// Import to report.
+func Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("roster is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading roster header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		column, ok := columnAliases[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, dup := columns[column]; dup {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[column] = i
+	}
+	for _, required := range []string{"name", "email", "studentId"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required column %q", required)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading roster: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, Row{
+			Line:      line,
+			Name:      field(record, "name"),
+			Email:     strings.ToLower(field(record, "email")),
+			StudentId: field(record, "studentId"),
+			Role:      strings.ToLower(field(record, "role")),
+			Team:      field(record, "team"),
+		})
+	}
+
+	return rows, nil
+}
+
+// Import validates every row and, unless DryRun is set, creates the valid
+// ones. Rows are independent: an invalid row is reported and skipped
+// without affecting the rest of the roster.
+func Import(ctx context.Context, storage storage.Storage, rows []Row, opts Options) *Report {
+	report := &Report{
+		DryRun: opts.DryRun,
+		Total:  len(rows),
+		Rows:   make([]RowResult, 0, len(rows)),
+	}
+
+	seenEmails := make(map[string]int)
+	seenStudentIds := make(map[string]int)
+
+	for _, row := range rows {
+		result := RowResult{
+			Line:      row.Line,
+			Email:     row.Email,
+			StudentId: row.StudentId,
+		}
+
+		result.Errors = validateRow(ctx, storage, row, seenEmails, seenStudentIds)
+		if row.Email != "" {
+			seenEmails[row.Email] = row.Line
+		}
+		if row.StudentId != "" {
+			seenStudentIds[row.StudentId] = row.Line
+		}
+
+		if len(result.Errors) > 0 {
+			result.Status = StatusError
+			report.Failed++
+			report.Rows = append(report.Rows, result)
+			continue
+		}
+
+		report.Valid++
+		if opts.DryRun {
+			result.Status = StatusValid
+			report.Rows = append(report.Rows, result)
+			continue
+		}
+
+		createRow(ctx, storage, row, opts, &result)
+		if result.Status == StatusError {
+			report.Failed++
+		} else {
+			report.Created++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report
+}
+
+func validateRow(ctx context.Context, store storage.Storage, row Row, seenEmails, seenStudentIds map[string]int) []string {
+	var errs []string
+
+	// Apply exactly the rules the signup endpoint uses; the password is
+	// generated by us so a placeholder stands in for it here.
+	signup := types.SignupRequest{
+		Name:      row.Name,
+		Email:     row.Email,
+		Password:  "placeholder",
+		StudentId: row.StudentId,
+	}
+	if err := validation.Struct(signup); err != nil {
+		var validateErrs validator.ValidationErrors
+		if errors.As(err, &validateErrs) {
+			for _, fe := range validateErrs {
+				errs = append(errs, validation.Message(fe))
+			}
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	switch types.Role(row.Role) {
+	case "", types.RoleUser, types.RoleAdmin:
+	default:
+		errs = append(errs, fmt.Sprintf("role %q is invalid", row.Role))
+	}
+
+	if line, dup := seenEmails[row.Email]; dup && row.Email != "" {
+		errs = append(errs, fmt.Sprintf("email %s is repeated from line %d", row.Email, line))
+	} else if row.Email != "" {
+		_, err := store.GetUserByEmail(ctx, row.Email)
+		if err == nil {
+			errs = append(errs, fmt.Sprintf("user with email %s already exists", row.Email))
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			errs = append(errs, fmt.Sprintf("could not check email: %v", err))
+		}
+	}
+
+	if line, dup := seenStudentIds[row.StudentId]; dup && row.StudentId != "" {
+		errs = append(errs, fmt.Sprintf("student ID %s is repeated from line %d", row.StudentId, line))
+	} else if row.StudentId != "" {
+		_, err := store.GetUserByStudentId(ctx, row.StudentId)
+		if err == nil {
+			errs = append(errs, fmt.Sprintf("user with student ID %s already exists", row.StudentId))
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			errs = append(errs, fmt.Sprintf("could not check student ID: %v", err))
+		}
+	}
+
+	return errs
+}
+
+func createRow(ctx context.Context, storage storage.Storage, row Row, opts Options, result *RowResult) {
+	user := types.User{
+		Name:      row.Name,
+		Email:     row.Email,
+		StudentId: row.StudentId,
+		Role:      types.Role(row.Role),
+		Team:      row.Team,
+	}
+
+	var token string
+	if opts.Invite {
+		var err error
+		token, err = randomString(32)
+		if err != nil {
+			result.Status = StatusError
+			result.Errors = append(result.Errors, err.Error())
+			return
+		}
+		user.InviteTokenHash = HashToken(token)
+		user.InviteExpiresAt = time.Now().Add(opts.InviteTTL)
+	} else {
+		password, err := randomString(12)
+		if err != nil {
+			result.Status = StatusError
+			result.Errors = append(result.Errors, err.Error())
+			return
+		}
+		user.Password = password
+		result.Password = password
+	}
+
+	userID, err := storage.InsertUser(ctx, user)
+	if err != nil {
+		result.Status = StatusError
+		result.Password = ""
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	result.UserID = userID
+
+	if !opts.Invite {
+		result.Status = StatusCreated
+		return
+	}
+
+	result.Status = StatusInvited
+	if err := opts.Mailer.Send(user.Email, "You're invited to the BDCOE contest portal", inviteBody(user, opts.InviteURL, token, opts.InviteTTL)); err != nil {
+		// The account exists either way; the admin can re-run with the
+		// failed rows once mail is fixed.
+		result.Errors = append(result.Errors, err.Error())
+	}
+}
+
+func inviteBody(user types.User, inviteURL, token string, ttl time.Duration) string {
+	link := token
+	if inviteURL != "" {
+		link = inviteURL + "?token=" + token
+	}
+
+	return fmt.Sprintf("Hi %s,\n\nAn account has been created for you (student ID %s) on the BDCOE contest portal.\n"+
+		"Choose a password to activate it within %s:\n\n%s\n", user.Name, user.StudentId, ttl, link)
+}
+
+// HashToken returns the form of an invitation token that is stored, so a
+// leaked users collection doesn't expose usable invitations.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random value: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)[:n], nil
+}
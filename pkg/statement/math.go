@@ -0,0 +1,216 @@
+package statement
+
+import (
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// LaTeX math is not typeset here. It is kept out of Markdown parsing, so
+// underscores and asterisks in it survive, and written out escaped between
+// \( \) or \[ \] for KaTeX or MathJax to typeset in the browser.
+
+var kindMath = ast.NewNodeKind("Math")
+
+type mathNode struct {
+	ast.BaseInline
+	tex     []byte
+	display bool
+}
+
+func (n *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Tex": string(n.tex)}, nil)
+}
+
+var kindMathBlock = ast.NewNodeKind("MathBlock")
+
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind {
+	return kindMathBlock
+}
+
+func (n *mathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathBlockParser parses display math between lines holding only $$, so
+// it can span lines Markdown would otherwise read as lists or headings.
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !isFence(line[pos:]) {
+		return nil, parser.NoChildren
+	}
+	advanceLine(reader, line, segment)
+	return &mathBlock{}, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if isFence(util.TrimLeftSpace(line)) {
+		advanceLine(reader, line, segment)
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	advanceLine(reader, line, segment)
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// advanceLine moves reader to the newline ending line, which the parser
+// consumes itself.
+func advanceLine(reader text.Reader, line []byte, segment text.Segment) {
+	n := segment.Len()
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		n--
+	}
+	reader.Advance(n)
+}
+
+// isFence reports whether line holds only $$.
+func isFence(line []byte) bool {
+	return string(util.TrimRightSpace(line)) == "$$"
+}
+
+// mathParser parses $inline$ and $$display$$ math within a paragraph.
+// Display math may span its lines. A single $ only opens math when
+// followed by a non-space and only closes it when preceded by one and not
+// followed by a digit, so "costs $5 or $6" stays text.
+type mathParser struct{}
+
+func (mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	opener := 1
+	if len(line) > 1 && line[1] == '$' {
+		opener = 2
+	}
+	if opener == 1 && (len(line) < 2 || util.IsSpace(line[1])) {
+		return nil
+	}
+
+	l, pos := block.Position()
+	block.Advance(opener)
+	var tex []byte
+	for {
+		line, _ := block.PeekLine()
+		if line == nil || (opener == 1 && len(tex) > 0) {
+			// Unclosed, or inline math running past the end of its line
+			block.SetPosition(l, pos)
+			return nil
+		}
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if line[i] != '$' || !closes(line, i, opener) {
+				continue
+			}
+			tex = append(tex, line[:i]...)
+			if len(util.TrimLeftSpace(util.TrimRightSpace(tex))) == 0 {
+				block.SetPosition(l, pos)
+				return nil
+			}
+			block.Advance(i + opener)
+			return &mathNode{tex: tex, display: opener == 2}
+		}
+		tex = append(tex, line...)
+		block.AdvanceLine()
+	}
+}
+
+// closes reports whether the $ at line[i] closes math opened with opener
+// dollars.
+func closes(line []byte, i int, opener int) bool {
+	if opener == 2 {
+		return i+1 < len(line) && line[i+1] == '$'
+	}
+	if i == 0 || util.IsSpace(line[i-1]) {
+		return false
+	}
+	return i+1 >= len(line) || line[i+1] < '0' || line[i+1] > '9'
+}
+
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, renderMath)
+	reg.Register(kindMathBlock, renderMathBlock)
+}
+
+func renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<div class="math math-display">\[`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		w.WriteString(html.EscapeString(string(segment.Value(source))))
+	}
+	w.WriteString(`\]</div>` + "\n")
+	return ast.WalkSkipChildren, nil
+}
+
+func renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*mathNode)
+	if n.display {
+		w.WriteString(`<span class="math math-display">\[`)
+		w.WriteString(html.EscapeString(string(n.tex)))
+		w.WriteString(`\]</span>`)
+	} else {
+		w.WriteString(`<span class="math math-inline">\(`)
+		w.WriteString(html.EscapeString(string(n.tex)))
+		w.WriteString(`\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// mathExtension adds LaTeX math to goldmark.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 700)),
+		parser.WithInlineParsers(util.Prioritized(mathParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 500)))
+}
@@ -0,0 +1,76 @@
+// Package statement renders problem statements written in Markdown with
+// LaTeX math to sanitised HTML.
+package statement
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Version identifies the renderer's output. Bump it when a change to the
+// renderer or sanitiser should reach statements rendered before it;
+// cached HTML of an older version is rendered again when read.
+const Version = 1
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, mathExtension{}),
+)
+
+// policy is applied to the rendered HTML even though goldmark already
+// drops raw HTML, so nothing a setter writes can reach contestants as
+// script.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^math math-(inline|display)$`)).OnElements("span", "div")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	return p
+}()
+
+// Markdown renders one part of a statement to sanitised HTML.
+func Markdown(source string) string {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		// Converting to a buffer only fails on a writer error
+		panic(err)
+	}
+	return policy.Sanitize(buf.String())
+}
+
+// Render renders every part of s.
+func Render(s types.Statement) types.RenderedStatement {
+	rendered := types.RenderedStatement{
+		Statement: types.Statement{
+			Legend:       Markdown(s.Legend),
+			InputFormat:  Markdown(s.InputFormat),
+			OutputFormat: Markdown(s.OutputFormat),
+			Constraints:  Markdown(s.Constraints),
+			Notes:        Markdown(s.Notes),
+		},
+		Version: Version,
+	}
+	for _, explanation := range s.SampleExplanations {
+		rendered.SampleExplanations = append(rendered.SampleExplanations, Markdown(explanation))
+	}
+	return rendered
+}
+
+// Cached returns cached if it is a current rendering of s, and otherwise
+// renders s. It returns nil for a question without a statement.
+func Cached(s *types.Statement, cached *types.RenderedStatement) *types.RenderedStatement {
+	if s == nil {
+		return nil
+	}
+	if cached != nil && cached.Version == Version {
+		return cached
+	}
+	rendered := Render(*s)
+	return &rendered
+}
@@ -0,0 +1,52 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "Print *one* line.", "<p>Print <em>one</em> line.</p>\n"},
+		{"inline math", "Given $a_i \\le 10^9$ and $b_i$.", `<p>Given <span class="math math-inline">\(a_i \le 10^9\)</span> and <span class="math math-inline">\(b_i\)</span>.</p>` + "\n"},
+		{"display math", "$$\\sum_{i=1}^n a_i < 2^{31}$$", `<p><span class="math math-display">\[\sum_{i=1}^n a_i &lt; 2^{31}\]</span></p>` + "\n"},
+		{"display block", "$$\n1 + \\dots\n+ n\n$$", `<div class="math math-display">\[1 + \dots` + "\n+ n\n" + `\]</div>` + "\n"},
+		{"prices", "It costs $5 or $6.", "<p>It costs $5 or $6.</p>\n"},
+		{"escaped dollar", "Pay \\$x$ now", "<p>Pay $x$ now</p>\n"},
+		{"math in code", "`$a_i$`", "<p><code>$a_i$</code></p>\n"},
+		{"raw html", "<script>alert(1)</script>\n\nok", "\n<p>ok</p>\n"},
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Markdown(tt.source); got != tt.want {
+				t.Errorf("Markdown(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	if Cached(nil, nil) != nil {
+		t.Error("a question without a statement has no rendering")
+	}
+
+	s := &types.Statement{Legend: "**bold**", SampleExplanations: []string{"$1+2=3$"}}
+	rendered := Cached(s, nil)
+	if rendered.Version != Version || !strings.Contains(rendered.Legend, "<strong>bold</strong>") || len(rendered.SampleExplanations) != 1 {
+		t.Fatalf("rendered = %+v", rendered)
+	}
+	if Cached(s, rendered) != rendered {
+		t.Error("a current rendering should be reused")
+	}
+	stale := &types.RenderedStatement{Version: Version - 1}
+	if got := Cached(s, stale); got == stale || got.Legend == "" {
+		t.Errorf("stale rendering not replaced: %+v", got)
+	}
+}
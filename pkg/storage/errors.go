@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel error kinds returned by every Storage implementation. Match them
+// with errors.Is; the concrete error carries a message meant for clients.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrInvalidID  = errors.New("invalid id")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidID reports an id that is not well formed, e.g. InvalidID("contest").
+func InvalidID(entity string) error {
+	return &Error{Kind: ErrInvalidID, Message: fmt.Sprintf("invalid %s id format", entity)}
+}
+
+func Conflict(format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(format string, args ...interface{}) error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) ListQuestions(ctx context.Context, filter types.QuestionFilter) (*types.QuestionList, error) {
+	var author primitive.ObjectID
+	if filter.Author != "" {
+		var err error
+		if author, err = parseID(filter.Author, "author"); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matched := []types.Question{}
+	for _, question := range m.questions {
+		if question.DeletedAt != nil {
+			continue
+		}
+		if filter.Difficulty != "" && question.Difficulty != filter.Difficulty {
+			continue
+		}
+		if !author.IsZero() && question.CreatedBy != author {
+			continue
+		}
+		if !hasTags(question.Tags, filter.Tags) {
+			continue
+		}
+		matched = append(matched, question)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID.Hex() > matched[j].ID.Hex()
+	})
+
+	questions := []types.QuestionBankEntry{}
+	for _, question := range page(matched, filter.Page, filter.Limit) {
+		entry := clone(types.QuestionBankEntry{
+			ID:         question.ID,
+			Title:      question.Title,
+			Difficulty: question.Difficulty,
+			Tags:       question.Tags,
+			Points:     question.Points,
+			CreatedBy:  question.CreatedBy,
+			CreatedAt:  question.CreatedAt,
+		})
+		entry.Contests = m.questionUsage(question.ID)
+		questions = append(questions, entry)
+	}
+
+	return &types.QuestionList{
+		Questions: questions,
+		Total:     int64(len(matched)),
+		Page:      filter.Page,
+		Limit:     filter.Limit,
+	}, nil
+}
+
+// hasTags reports whether tags includes every one of want, like $all.
+func hasTags(tags, want []string) bool {
+	for _, tag := range want {
+		found := false
+		for _, existing := range tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// questionUsage counts the live contests using a question. Callers must
+// hold m.mu.
+func (m *Memory) questionUsage(id primitive.ObjectID) int {
+	n := 0
+	for _, contest := range m.contests {
+		if contest.DeletedAt == nil && storage.ProblemIndex(contest.Problems, id) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Memory) LinkQuestionToContest(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error {
+	contestObjID, err := parseID(contestId, "contest")
+	if err != nil {
+		return err
+	}
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateProblems(contestObjID, func(problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		if _, ok := m.liveQuestion(questionObjID); !ok {
+			return nil, storage.NotFound("no question found with the given id")
+		}
+		if storage.ProblemIndex(problems, questionObjID) >= 0 {
+			return nil, storage.Conflict("question is already in the contest")
+		}
+		return storage.PlaceProblem(problems, storage.NewProblem(questionObjID, placement), placement.Position), nil
+	})
+}
+
+func (m *Memory) UpdateContestProblem(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error {
+	contestObjID, err := parseID(contestId, "contest")
+	if err != nil {
+		return err
+	}
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateProblems(contestObjID, func(problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		index := storage.ProblemIndex(problems, questionObjID)
+		if index < 0 {
+			return nil, storage.NotFound("no question found with the given id in the contest")
+		}
+
+		position := placement.Position
+		if position == 0 {
+			position = index + 1
+		}
+		remaining := append(append([]types.ContestProblem{}, problems[:index]...), problems[index+1:]...)
+		return storage.PlaceProblem(remaining, storage.NewProblem(questionObjID, placement), position), nil
+	})
+}
+
+func (m *Memory) ReorderContestProblems(ctx context.Context, contestId string, questionIds []string) error {
+	contestObjID, err := parseID(contestId, "contest")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateProblems(contestObjID, func(problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		return storage.ReorderProblems(problems, questionIds)
+	})
+}
+
+// updateProblems replaces a live contest's problems list with what change
+// makes of it, provided the labels stay unique. Callers must hold m.mu.
+func (m *Memory) updateProblems(contestId primitive.ObjectID, change func(problems []types.ContestProblem) ([]types.ContestProblem, error)) error {
+	contest, ok := m.liveContest(contestId)
+	if !ok {
+		return storage.NotFound("no contest found with the given id")
+	}
+
+	problems, err := change(contest.Problems)
+	if err != nil {
+		return err
+	}
+	if err := storage.CheckProblemLabels(problems); err != nil {
+		return err
+	}
+
+	contest.Problems = problems
+	m.contests[contestId] = clone(contest)
+	return nil
+}
+
+func (m *Memory) DeleteQuestionById(ctx context.Context, id string, cascade bool, force bool) error {
+	objectId, err := parseID(id, "question")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.liveQuestion(objectId); !ok {
+		return storage.NotFound("no question found with the given id")
+	}
+	if n := m.questionUsage(objectId); n > 0 && !force {
+		return storage.Conflict("question is used by %d contests; remove it from them first or force the delete", n)
+	}
+
+	m.trashQuestion(objectId, newDeletion(), cascade)
+	return nil
+}
+
+func (m *Memory) GetQuestionSolutions(ctx context.Context, questionId string) ([]types.Solution, error) {
+	objectId, err := parseID(questionId, "question")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+	if question.Solutions == nil {
+		return []types.Solution{}, nil
+	}
+	return slices.Clone(question.Solutions), nil
+}
+
+func (m *Memory) SetQuestionSolutions(ctx context.Context, questionId string, solutions []types.Solution) error {
+	return m.updateQuestionTools(questionId, func(question *types.Question) {
+		question.Solutions = solutions
+	})
+}
+
+func (m *Memory) GetQuestionTools(ctx context.Context, questionId string) (*types.QuestionTools, error) {
+	objectId, err := parseID(questionId, "question")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+	tools := clone(types.QuestionTools{
+		Solutions:  question.Solutions,
+		Validator:  question.Validator,
+		Generators: question.Generators,
+	})
+	if tools.Solutions == nil {
+		tools.Solutions = []types.Solution{}
+	}
+	if tools.Generators == nil {
+		tools.Generators = []types.Generator{}
+	}
+	return &tools, nil
+}
+
+func (m *Memory) SetQuestionValidator(ctx context.Context, questionId string, validator *types.Program) error {
+	return m.updateQuestionTools(questionId, func(question *types.Question) {
+		question.Validator = validator
+	})
+}
+
+func (m *Memory) SetQuestionGenerators(ctx context.Context, questionId string, generators []types.Generator) error {
+	return m.updateQuestionTools(questionId, func(question *types.Question) {
+		question.Generators = generators
+	})
+}
+
+func (m *Memory) updateQuestionTools(questionId string, update func(question *types.Question)) error {
+	objectId, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return storage.NotFound("no question found with the given id")
+	}
+	update(&question)
+	m.questions[objectId] = clone(question)
+	return nil
+}
@@ -0,0 +1,1028 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/statement"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Memory is a storage.Storage kept entirely in process memory. It mirrors
+// the MongoDB implementation closely enough to back handler tests and local
+// development; the shared suite in pkg/storage/storagetest keeps the two in
+// step.
+//
+// Documents are passed through BSON on the way in and out, so callers get
+// the same copies, millisecond-precision UTC times and decoded types they
+// would get from Mongo.
+type Memory struct {
+	mu          sync.RWMutex
+	users       map[primitive.ObjectID]types.User
+	contests    map[primitive.ObjectID]types.Contest
+	questions   map[primitive.ObjectID]types.Question
+	testCases   map[primitive.ObjectID]types.TestCase
+	submissions map[primitive.ObjectID]types.Submission
+	revisions   []types.Revision
+	audit       []types.AuditEntry
+}
+
+func New() *Memory {
+	return &Memory{
+		users:       make(map[primitive.ObjectID]types.User),
+		contests:    make(map[primitive.ObjectID]types.Contest),
+		questions:   make(map[primitive.ObjectID]types.Question),
+		testCases:   make(map[primitive.ObjectID]types.TestCase),
+		submissions: make(map[primitive.ObjectID]types.Submission),
+	}
+}
+
+func (m *Memory) Close(ctx context.Context) error {
+	return nil
+}
+
+// clone round-trips v through BSON, decoding embedded documents as bson.M
+// like the MongoDB client does. It only fails for values Mongo could not
+// have stored either, which is a programming error.
+func clone[T any](v T) T {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("memory: cannot encode %T: %v", v, err))
+	}
+	dec, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("memory: cannot decode %T: %v", v, err))
+	}
+	dec.DefaultDocumentM()
+	var out T
+	if err := dec.Decode(&out); err != nil {
+		panic(fmt.Sprintf("memory: cannot decode %T: %v", v, err))
+	}
+	return out
+}
+
+func parseID(id string, entity string) (primitive.ObjectID, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, storage.InvalidID(entity)
+	}
+	return objectId, nil
+}
+
+// User operations
+func (m *Memory) CreateUser(ctx context.Context, name, email, password, studentId string, role types.Role) (string, error) {
+	return m.InsertUser(ctx, types.User{
+		Name:      name,
+		Email:     email,
+		StudentId: studentId,
+		Password:  password,
+		Role:      role,
+	})
+}
+
+func (m *Memory) InsertUser(ctx context.Context, user types.User) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.Email == user.Email {
+			return "", storage.Conflict("user with email %s already exists", user.Email)
+		}
+		if existing.StudentId == user.StudentId {
+			return "", storage.Conflict("user with student ID %s already exists", user.StudentId)
+		}
+	}
+
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	if user.Role == "" {
+		user.Role = types.RoleUser
+	}
+	m.users[user.ID] = clone(user)
+
+	return user.ID.Hex(), nil
+}
+
+func (m *Memory) findUser(match func(types.User) bool, notFound string) (*types.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if match(user) {
+			user = clone(user)
+			return &user, nil
+		}
+	}
+	return nil, storage.NotFound("%s", notFound)
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	return m.findUser(func(u types.User) bool { return u.Email == email }, "no user found with the given email")
+}
+
+func (m *Memory) GetUserByStudentId(ctx context.Context, studentId string) (*types.User, error) {
+	return m.findUser(func(u types.User) bool { return u.StudentId == studentId }, "no user found with the given student ID")
+}
+
+func (m *Memory) AcceptInvitation(ctx context.Context, tokenHash string, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, user := range m.users {
+		if user.InviteTokenHash != "" && user.InviteTokenHash == tokenHash && user.InviteExpiresAt.After(now) {
+			user.Password = password
+			user.UpdatedAt = now
+			user.InviteTokenHash = ""
+			user.InviteExpiresAt = time.Time{}
+			m.users[id] = clone(user)
+			return nil
+		}
+	}
+
+	return storage.Validation("invitation is invalid or has expired")
+}
+
+func (m *Memory) GetUserById(ctx context.Context, id string) (*types.User, error) {
+	objectId, err := parseID(id, "user")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[objectId]
+	if !ok {
+		return nil, storage.NotFound("no user found with the given id")
+	}
+	user = clone(user)
+	return &user, nil
+}
+
+func (m *Memory) UpdateUserProfile(ctx context.Context, id string, profile types.ProfileUpdate) error {
+	if profile == (types.ProfileUpdate{}) {
+		return nil
+	}
+
+	return m.updateUser(id, func(user *types.User) {
+		if profile.Name != "" {
+			user.Name = profile.Name
+		}
+		if profile.Avatar != "" {
+			user.Avatar = profile.Avatar
+		}
+		if profile.Bio != "" {
+			user.Bio = profile.Bio
+		}
+		if profile.PreferredLanguage != "" {
+			user.PreferredLanguage = profile.PreferredLanguage
+		}
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (m *Memory) ListUsers(ctx context.Context, filter types.UserFilter) (*types.UserList, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+	matched := []types.User{}
+	for _, user := range m.users {
+		if query != "" &&
+			!strings.Contains(strings.ToLower(user.Name), query) &&
+			!strings.Contains(strings.ToLower(user.Email), query) &&
+			!strings.Contains(strings.ToLower(user.StudentId), query) {
+			continue
+		}
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if filter.Disabled != nil && user.Disabled != *filter.Disabled {
+			continue
+		}
+		matched = append(matched, clone(user))
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID.Hex() > matched[j].ID.Hex()
+	})
+
+	users := page(matched, filter.Page, filter.Limit)
+	return &types.UserList{
+		Users: users,
+		Total: int64(len(matched)),
+		Page:  filter.Page,
+		Limit: filter.Limit,
+	}, nil
+}
+
+func page[T any](items []T, page, limit int) []T {
+	start := (page - 1) * limit
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return items[start:end]
+}
+
+func (m *Memory) UpdateUserRole(ctx context.Context, id string, role types.Role) error {
+	return m.updateUser(id, func(user *types.User) {
+		user.Role = role
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (m *Memory) SetUserDisabled(ctx context.Context, id string, disabled bool) error {
+	return m.updateUser(id, func(user *types.User) {
+		user.Disabled = disabled
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (m *Memory) updateUser(id string, update func(user *types.User)) error {
+	objectId, err := parseID(id, "user")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[objectId]
+	if !ok {
+		return storage.NotFound("no user found with the given id")
+	}
+	update(&user)
+	m.users[objectId] = clone(user)
+	return nil
+}
+
+func (m *Memory) DeleteUserById(ctx context.Context, id string) error {
+	objectId, err := parseID(id, "user")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[objectId]; !ok {
+		return storage.NotFound("no user found with the given id")
+	}
+	delete(m.users, objectId)
+	return nil
+}
+
+func (m *Memory) GetPublicProfile(ctx context.Context, id string) (*types.PublicProfile, error) {
+	user, err := m.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type attempt struct {
+		count    int
+		best     int
+		accepted bool
+	}
+	byContest := make(map[primitive.ObjectID]map[primitive.ObjectID]*attempt)
+	solved := make(map[primitive.ObjectID]bool)
+
+	for _, submission := range m.submissions {
+		if submission.UserID != user.ID {
+			continue
+		}
+		questions, ok := byContest[submission.ContestID]
+		if !ok {
+			questions = make(map[primitive.ObjectID]*attempt)
+			byContest[submission.ContestID] = questions
+		}
+		a, ok := questions[submission.QuestionID]
+		if !ok {
+			a = &attempt{best: submission.Score}
+			questions[submission.QuestionID] = a
+		}
+		a.count++
+		if submission.Score > a.best {
+			a.best = submission.Score
+		}
+		if submission.Status == types.StatusAccepted {
+			a.accepted = true
+			solved[submission.QuestionID] = true
+		}
+	}
+
+	contests := []types.ContestParticipation{}
+	for contestId, questions := range byContest {
+		contest, ok := m.liveContest(contestId)
+		if !ok {
+			continue
+		}
+		participation := types.ContestParticipation{
+			ContestID: contestId,
+			Title:     contest.Title,
+			StartTime: contest.StartTime,
+		}
+		for _, a := range questions {
+			participation.Submissions += a.count
+			participation.Score += a.best
+			if a.accepted {
+				participation.Solved++
+			}
+		}
+		contests = append(contests, participation)
+	}
+	sort.Slice(contests, func(i, j int) bool {
+		return contests[i].StartTime.After(contests[j].StartTime)
+	})
+
+	return &types.PublicProfile{
+		ID:          user.ID,
+		Name:        user.Name,
+		Avatar:      user.Avatar,
+		Bio:         user.Bio,
+		CreatedAt:   user.CreatedAt,
+		SolvedCount: len(solved),
+		Contests:    contests,
+	}, nil
+}
+
+// Contest operations
+func (m *Memory) CreateContest(ctx context.Context, contest types.Contest) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if contest.ID.IsZero() {
+		contest.ID = primitive.NewObjectID()
+	}
+	if _, exists := m.contests[contest.ID]; exists {
+		return "", storage.Conflict("contest with id %s already exists", contest.ID.Hex())
+	}
+	if contest.Problems == nil {
+		contest.Problems = []types.ContestProblem{}
+	}
+	m.contests[contest.ID] = clone(contest)
+
+	return contest.ID.Hex(), nil
+}
+
+func (m *Memory) EditContestById(ctx context.Context, id string, updateData types.Contest) error {
+	objectId, err := parseID(id, "contest")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	contest, ok := m.liveContest(objectId)
+	if !ok {
+		return storage.NotFound("no contest found with the given id")
+	}
+
+	if updateData.Title != "" {
+		contest.Title = updateData.Title
+	}
+	if !updateData.StartTime.IsZero() {
+		contest.StartTime = updateData.StartTime
+	}
+	if !updateData.EndTime.IsZero() {
+		contest.EndTime = updateData.EndTime
+	}
+	if updateData.Description != "" {
+		contest.Description = updateData.Description
+	}
+	if !updateData.CreatedBy.IsZero() {
+		contest.CreatedBy = updateData.CreatedBy
+	}
+	if updateData.MaxAttempts != nil {
+		maxAttempts := *updateData.MaxAttempts
+		contest.MaxAttempts = &maxAttempts
+	}
+
+	if !contest.EndTime.After(contest.StartTime) {
+		return storage.Validation("end_time must be after start_time")
+	}
+
+	m.contests[objectId] = clone(contest)
+	return nil
+}
+
+func (m *Memory) DeleteContestById(ctx context.Context, id string, cascade bool) error {
+	objectId, err := parseID(id, "contest")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	contest, ok := m.liveContest(objectId)
+	if !ok {
+		return storage.NotFound("no contest found with the given id")
+	}
+
+	d := newDeletion()
+	contest.DeletedAt, contest.DeletionID = &d.at, d.id
+	m.contests[objectId] = clone(contest)
+
+	if cascade {
+		// Questions other contests still use stay in the bank
+		for _, problem := range contest.Problems {
+			if m.questionUsage(problem.QuestionID) == 0 {
+				m.trashQuestion(problem.QuestionID, d, true)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetAllContests(ctx context.Context) ([]types.ContestBasicInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	contests := []types.ContestBasicInfo{}
+	for _, contest := range m.contests {
+		if contest.DeletedAt != nil {
+			continue
+		}
+		contests = append(contests, types.ContestBasicInfo{
+			ID:          contest.ID,
+			Title:       contest.Title,
+			StartTime:   contest.StartTime,
+			EndTime:     contest.EndTime,
+			Description: contest.Description,
+		})
+	}
+	// Mongo returns documents in insertion order, which ObjectIDs follow.
+	sort.Slice(contests, func(i, j int) bool {
+		return contests[i].ID.Hex() < contests[j].ID.Hex()
+	})
+
+	return contests, nil
+}
+
+func (m *Memory) GetContestById(ctx context.Context, id string) (*types.ContestDetail, error) {
+	objectId, err := parseID(id, "contest")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	contest, ok := m.liveContest(objectId)
+	if !ok {
+		return nil, storage.NotFound("no contest found with the given id")
+	}
+
+	detail := types.ContestDetail{
+		ID:          contest.ID,
+		Title:       contest.Title,
+		StartTime:   contest.StartTime,
+		EndTime:     contest.EndTime,
+		Description: contest.Description,
+		Questions:   storage.ProblemSummaries(contest.Problems, m.liveQuestion),
+	}
+	if contest.MaxAttempts != nil {
+		detail.MaxAttempts = *contest.MaxAttempts
+	}
+
+	detail = clone(detail)
+	return &detail, nil
+}
+
+// lookup resolves ids the way the $lookup stage does: each matching
+// document once, in insertion order rather than list order.
+func lookup(ids []primitive.ObjectID) []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]bool)
+	objectIds := []primitive.ObjectID{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		objectIds = append(objectIds, id)
+	}
+	sort.Slice(objectIds, func(i, j int) bool {
+		return objectIds[i].Hex() < objectIds[j].Hex()
+	})
+	return objectIds
+}
+
+// Question operations
+func (m *Memory) CreateQuestion(ctx context.Context, question types.Question) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if question.TestCaseIDs == nil {
+		question.TestCaseIDs = []primitive.ObjectID{}
+	}
+	return m.insertQuestion(question).Hex(), nil
+}
+
+func (m *Memory) ImportQuestion(ctx context.Context, question types.Question, testCases []types.TestCase) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question.TestCaseIDs = []primitive.ObjectID{}
+	for _, testCase := range testCases {
+		question.TestCaseIDs = append(question.TestCaseIDs, m.insertTestCase(testCase))
+	}
+	return m.insertQuestion(question).Hex(), nil
+}
+
+func (m *Memory) insertQuestion(question types.Question) primitive.ObjectID {
+	question.ID = primitive.NewObjectID()
+	question.StatementHTML = statement.Cached(question.Statement, nil)
+	m.questions[question.ID] = clone(question)
+	return question.ID
+}
+
+func (m *Memory) GetQuestionById(ctx context.Context, id string) (*types.QuestionDetail, error) {
+	objectId, err := parseID(id, "question")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+
+	detail := types.QuestionDetail{
+		ID:             objectId,
+		Title:          question.Title,
+		Description:    question.Description,
+		Statement:      question.Statement,
+		StatementHTML:  statement.Cached(question.Statement, question.StatementHTML),
+		Difficulty:     question.Difficulty,
+		Tags:           question.Tags,
+		Points:         question.Points,
+		Cpu_time_limit: question.Cpu_time_limit,
+		Memory_limit:   question.Memory_limit,
+		Checker:        question.Checker,
+		TestCases:      []types.TestCaseDetail{},
+	}
+	if question.MaxAttempts != nil {
+		detail.MaxAttempts = *question.MaxAttempts
+	}
+	for _, tid := range lookup(question.TestCaseIDs) {
+		testCase, ok := m.liveTestCase(tid)
+		if !ok {
+			continue
+		}
+		detail.TestCases = append(detail.TestCases, types.TestCaseDetail{
+			ID:                 tid,
+			Input:              testCase.Input,
+			ExpectedOutput:     testCase.ExpectedOutput,
+			InputBlob:          testCase.InputBlob,
+			ExpectedOutputBlob: testCase.ExpectedOutputBlob,
+			Visibility:         testCase.Visibility,
+			Subtask:            testCase.Subtask,
+			Generated:          testCase.Generated,
+		})
+	}
+
+	detail = clone(detail)
+	return &detail, nil
+}
+
+func (m *Memory) EditQuestionById(ctx context.Context, id string, updateData types.Question, author string) error {
+	objectId, err := parseID(id, "question")
+	if err != nil {
+		return err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return storage.NotFound("no question found with the given id")
+	}
+	before := storage.QuestionSnapshot(question)
+
+	if updateData.Title != "" {
+		question.Title = updateData.Title
+	}
+	if updateData.Description != "" {
+		question.Description = updateData.Description
+	}
+	if updateData.Statement != nil {
+		question.Statement = updateData.Statement
+		question.StatementHTML = statement.Cached(updateData.Statement, nil)
+	}
+	if updateData.Difficulty != "" {
+		question.Difficulty = updateData.Difficulty
+	}
+	if updateData.Tags != nil {
+		question.Tags = append([]string{}, updateData.Tags...)
+	}
+	if updateData.Points != 0 {
+		question.Points = updateData.Points
+	}
+	if updateData.Cpu_time_limit != 0 {
+		question.Cpu_time_limit = updateData.Cpu_time_limit
+	}
+	if updateData.Memory_limit != 0 {
+		question.Memory_limit = updateData.Memory_limit
+	}
+	if updateData.MaxAttempts != nil {
+		maxAttempts := *updateData.MaxAttempts
+		question.MaxAttempts = &maxAttempts
+	}
+
+	rev := types.Revision{QuestionID: objectId, Action: types.RevisionEdit, Author: authorID}
+	if m.revise(rev, before, storage.QuestionSnapshot(question), storage.QuestionOriginal(question)) {
+		m.questions[objectId] = clone(question)
+	}
+	return nil
+}
+
+func (m *Memory) AddQuestionToContest(ctx context.Context, contestId string, question types.Question) (string, error) {
+	contestObjID, err := parseID(contestId, "contest")
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	contest, ok := m.liveContest(contestObjID)
+	if !ok {
+		return "", storage.NotFound("no contest found with the given id")
+	}
+
+	// The new question takes the next letter, which a custom label may hold
+	if err := storage.CheckProblemLabels(append(contest.Problems, types.ContestProblem{})); err != nil {
+		return "", err
+	}
+
+	question.TestCaseIDs = []primitive.ObjectID{}
+	questionId := m.insertQuestion(question)
+
+	contest.Problems = append(contest.Problems, types.ContestProblem{QuestionID: questionId})
+	m.contests[contestObjID] = clone(contest)
+
+	return questionId.Hex(), nil
+}
+
+func (m *Memory) DeleteQuestionFromContestById(ctx context.Context, contestId string, questionId string) error {
+	contestObjID, err := parseID(contestId, "contest")
+	if err != nil {
+		return err
+	}
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	contest, ok := m.liveContest(contestObjID)
+	if !ok {
+		return storage.NotFound("no contest found with the given id")
+	}
+
+	index := storage.ProblemIndex(contest.Problems, questionObjID)
+	if index < 0 {
+		return storage.NotFound("no question found with the given id in the contest")
+	}
+	contest.Problems = append(contest.Problems[:index], contest.Problems[index+1:]...)
+	m.contests[contestObjID] = clone(contest)
+
+	return nil
+}
+
+// without returns ids minus every occurrence of id, like Mongo's $pull.
+func without(ids []primitive.ObjectID, id primitive.ObjectID) ([]primitive.ObjectID, bool) {
+	remaining := []primitive.ObjectID{}
+	found := false
+	for _, existing := range ids {
+		if existing == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, existing)
+	}
+	return remaining, found
+}
+
+// Test case operations
+func (m *Memory) CreateTestCase(ctx context.Context, testCase types.TestCase) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertTestCase(testCase).Hex(), nil
+}
+
+func (m *Memory) GetTestCaseById(ctx context.Context, id string) (*types.TestCase, error) {
+	objectId, err := parseID(id, "test case")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	testCase, ok := m.liveTestCase(objectId)
+	if !ok {
+		return nil, storage.NotFound("no test case found with the given id")
+	}
+	testCase = clone(testCase)
+	return &testCase, nil
+}
+
+func (m *Memory) GetTestCaseQuestionId(ctx context.Context, testCaseId string) (string, error) {
+	objectId, err := parseID(testCaseId, "test case")
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, question := range m.questions {
+		if question.DeletedAt == nil && slices.Contains(question.TestCaseIDs, objectId) {
+			return question.ID.Hex(), nil
+		}
+	}
+	return "", nil
+}
+
+func (m *Memory) insertTestCase(testCase types.TestCase) primitive.ObjectID {
+	testCase.ID = primitive.NewObjectID()
+	m.testCases[testCase.ID] = clone(testCase)
+	return testCase.ID
+}
+
+func (m *Memory) AddTestCaseToQuestion(ctx context.Context, questionId string, testCase types.TestCase, author string) (string, error) {
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return "", err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(questionObjID)
+	if !ok {
+		return "", storage.NotFound("no question found with the given id")
+	}
+
+	testCaseId := m.insertTestCase(testCase)
+	m.reviseTestCase(questionObjID, testCaseId, types.RevisionAdd, authorID, bson.M{}, storage.TestCaseSnapshot(testCase))
+
+	question.TestCaseIDs = append(question.TestCaseIDs, testCaseId)
+	m.questions[questionObjID] = clone(question)
+
+	return testCaseId.Hex(), nil
+}
+
+func (m *Memory) AddTestCasesToQuestion(ctx context.Context, questionId string, testCases []types.TestCase, replace bool, author string) ([]string, error) {
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return nil, err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(questionObjID)
+	if !ok {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+
+	if replace {
+		d := newDeletion()
+		for _, id := range question.TestCaseIDs {
+			if testCase, ok := m.liveTestCase(id); ok && m.trashTestCase(id, d) {
+				m.reviseTestCase(questionObjID, id, types.RevisionRemove, authorID, storage.TestCaseSnapshot(testCase), bson.M{})
+			}
+		}
+	}
+	ids := []string{}
+	for _, testCase := range testCases {
+		id := m.insertTestCase(testCase)
+		m.reviseTestCase(questionObjID, id, types.RevisionAdd, authorID, bson.M{}, storage.TestCaseSnapshot(testCase))
+		question.TestCaseIDs = append(question.TestCaseIDs, id)
+		ids = append(ids, id.Hex())
+	}
+	m.questions[questionObjID] = clone(question)
+
+	return ids, nil
+}
+
+func (m *Memory) EditTestCaseById(ctx context.Context, id string, updateData types.TestCase, author string) error {
+	objectId, err := parseID(id, "test case")
+	if err != nil {
+		return err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	testCase, ok := m.liveTestCase(objectId)
+	if !ok {
+		return storage.NotFound("no test case found with the given id")
+	}
+	before := storage.TestCaseSnapshot(testCase)
+
+	// New data replaces the old whether it was inline or a blob
+	if updateData.Input != nil || updateData.InputBlob != nil {
+		testCase.Input, testCase.InputBlob = updateData.Input, updateData.InputBlob
+		testCase.Generated = nil
+	}
+	if updateData.ExpectedOutput != nil || updateData.ExpectedOutputBlob != nil {
+		testCase.ExpectedOutput, testCase.ExpectedOutputBlob = updateData.ExpectedOutput, updateData.ExpectedOutputBlob
+		testCase.Generated = nil
+	}
+	if updateData.Visibility != "" {
+		testCase.Visibility = updateData.Visibility
+	}
+	if updateData.Subtask != 0 {
+		testCase.Subtask = updateData.Subtask
+	}
+
+	// Revisions belong to the question listing the test case; one no
+	// question lists isn't tracked
+	if question, ok := m.owningQuestion(objectId); ok {
+		rev := types.Revision{QuestionID: question.ID, TestCaseID: &objectId, Action: types.RevisionEdit, Author: authorID}
+		m.revise(rev, before, storage.TestCaseSnapshot(testCase), types.Revision{CreatedAt: testCase.CreatedAt})
+	}
+	m.testCases[objectId] = clone(testCase)
+	return nil
+}
+
+func (m *Memory) DeleteTestCaseFromQuestionById(ctx context.Context, questionId string, testCaseId string, author string) error {
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+	testCaseObjID, err := parseID(testCaseId, "test case")
+	if err != nil {
+		return err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(questionObjID)
+	if !ok {
+		return storage.NotFound("no question found with the given id")
+	}
+
+	_, listed := without(question.TestCaseIDs, testCaseObjID)
+	testCase, ok := m.liveTestCase(testCaseObjID)
+	if !listed || !ok || !m.trashTestCase(testCaseObjID, newDeletion()) {
+		return storage.NotFound("no test case found with the given id in the question")
+	}
+	m.reviseTestCase(questionObjID, testCaseObjID, types.RevisionRemove, authorID, storage.TestCaseSnapshot(testCase), bson.M{})
+
+	return nil
+}
+
+// Submission operations
+func (m *Memory) CreateSubmission(ctx context.Context, submission types.Submission) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if submission.ID.IsZero() {
+		submission.ID = primitive.NewObjectID()
+	}
+	if _, exists := m.submissions[submission.ID]; exists {
+		return "", storage.Conflict("submission with id %s already exists", submission.ID.Hex())
+	}
+	m.submissions[submission.ID] = clone(submission)
+
+	return submission.ID.Hex(), nil
+}
+
+func (m *Memory) GetSubmissionById(ctx context.Context, id string) (*types.Submission, error) {
+	objectId, err := parseID(id, "submission")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	submission, ok := m.submissions[objectId]
+	if !ok {
+		return nil, storage.NotFound("no submission found with the given id")
+	}
+	submission = clone(submission)
+	return &submission, nil
+}
+
+func (m *Memory) CountSubmissions(ctx context.Context, userId, contestId, questionId string) (int, error) {
+	userObjID, err := parseID(userId, "user")
+	if err != nil {
+		return 0, err
+	}
+	var contestObjID primitive.ObjectID
+	if contestId != "" {
+		contestObjID, err = parseID(contestId, "contest")
+		if err != nil {
+			return 0, err
+		}
+	}
+	questionObjID, err := parseID(questionId, "question")
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, submission := range m.submissions {
+		if contestId != "" && submission.ContestID != contestObjID {
+			continue
+		}
+		if submission.UserID == userObjID && submission.QuestionID == questionObjID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) UpdateSubmissionStatus(ctx context.Context, id string, status string, score int) error {
+	objectId, err := parseID(id, "submission")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	submission, ok := m.submissions[objectId]
+	if !ok {
+		return storage.NotFound("no submission found with the given id")
+	}
+	submission.Status = status
+	submission.Score = score
+	m.submissions[objectId] = submission
+
+	return nil
+}
+
+func (m *Memory) CreateAuditEntry(ctx context.Context, entry types.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	m.audit = append(m.audit, clone(entry))
+	return nil
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/statement"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) ListQuestionRevisions(ctx context.Context, questionId string) ([]types.Revision, error) {
+	objectId, err := parseID(questionId, "question")
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.liveQuestion(objectId); !ok {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+
+	revisions := []types.Revision{}
+	for _, rev := range m.revisions {
+		if rev.QuestionID == objectId {
+			revisions = append(revisions, clone(rev))
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number < revisions[j].Number })
+	return revisions, nil
+}
+
+func (m *Memory) RollbackQuestion(ctx context.Context, questionId string, number int, author string) error {
+	objectId, err := parseID(questionId, "question")
+	if err != nil {
+		return err
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.liveQuestion(objectId)
+	if !ok {
+		return storage.NotFound("no question found with the given id")
+	}
+	i := slices.IndexFunc(m.revisions, func(rev types.Revision) bool {
+		return rev.QuestionID == objectId && rev.Number == number
+	})
+	if i < 0 {
+		return storage.NotFound("question has no revision %d", number)
+	}
+	target := m.revisions[i]
+
+	rev := types.Revision{QuestionID: objectId, TestCaseID: target.TestCaseID, Action: types.RevisionRollback, RollbackOf: number, Author: authorID}
+	if target.TestCaseID == nil {
+		restored := restore(question, target.Snapshot)
+		restored.StatementHTML = statement.Cached(restored.Statement, nil)
+		if m.revise(rev, storage.QuestionSnapshot(question), storage.QuestionSnapshot(restored), storage.QuestionOriginal(question)) {
+			m.questions[objectId] = clone(restored)
+		}
+		return nil
+	}
+
+	if target.Action == types.RevisionRemove {
+		return storage.Validation("revision %d removed a test case; restore it from the trash instead", number)
+	}
+	testCase, ok := m.liveTestCase(*target.TestCaseID)
+	if !ok || !slices.Contains(question.TestCaseIDs, testCase.ID) {
+		return storage.NotFound("the test case revision %d changed is no longer part of the question", number)
+	}
+	restored := restore(testCase, target.Snapshot)
+	if m.revise(rev, storage.TestCaseSnapshot(testCase), storage.TestCaseSnapshot(restored), types.Revision{CreatedAt: testCase.CreatedAt}) {
+		m.testCases[testCase.ID] = clone(restored)
+	}
+	return nil
+}
+
+// revise records rev, the change from before to after of a question or
+// one of its test cases, and reports whether anything changed. The first
+// change to a question or test case also records, as original, how it was
+// before, unless it didn't exist. Callers must hold m.mu.
+func (m *Memory) revise(rev types.Revision, before, after bson.M, original types.Revision) bool {
+	rev.Changes = storage.DiffSnapshots(before, after)
+	if len(rev.Changes) == 0 {
+		return false
+	}
+
+	number, tracked := 0, false
+	for _, existing := range m.revisions {
+		if existing.QuestionID != rev.QuestionID {
+			continue
+		}
+		number = max(number, existing.Number)
+		if sameID(existing.TestCaseID, rev.TestCaseID) {
+			tracked = true
+		}
+	}
+
+	if !tracked && len(before) > 0 {
+		number++
+		original = storage.OriginalRevision(rev, original, number, before)
+		original.ID = primitive.NewObjectID()
+		m.revisions = append(m.revisions, clone(original))
+	}
+
+	number++
+	rev.ID = primitive.NewObjectID()
+	rev.Number = number
+	rev.CreatedAt = time.Now()
+	rev.Snapshot = after
+	m.revisions = append(m.revisions, clone(rev))
+	return true
+}
+
+// reviseTestCase records a test case being added to or removed from a
+// question. Callers must hold m.mu.
+func (m *Memory) reviseTestCase(questionId, testCaseId primitive.ObjectID, action types.RevisionAction, author *primitive.ObjectID, before, after bson.M) {
+	rev := types.Revision{QuestionID: questionId, TestCaseID: &testCaseId, Action: action, Author: author}
+	m.revise(rev, before, after, types.Revision{})
+}
+
+// owningQuestion returns the question listing a test case, trashed or not.
+// Callers must hold m.mu.
+func (m *Memory) owningQuestion(testCaseId primitive.ObjectID) (types.Question, bool) {
+	for _, question := range m.questions {
+		if slices.Contains(question.TestCaseIDs, testCaseId) {
+			return question, true
+		}
+	}
+	return types.Question{}, false
+}
+
+// restore returns a copy of doc with the fields in snapshot set, like
+// $set.
+func restore[T any](doc T, snapshot map[string]interface{}) T {
+	restored := clone(doc)
+	data, err := bson.Marshal(snapshot)
+	if err != nil {
+		panic(fmt.Sprintf("memory: cannot encode snapshot: %v", err))
+	}
+	if err := bson.Unmarshal(data, &restored); err != nil {
+		panic(fmt.Sprintf("memory: cannot apply snapshot to %T: %v", doc, err))
+	}
+	return restored
+}
+
+// parseAuthor parses the id of the user making a change, which is nil if
+// the change is anonymous.
+func parseAuthor(author string) (*primitive.ObjectID, error) {
+	if author == "" {
+		return nil, nil
+	}
+	id, err := parseID(author, "author")
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func sameID(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Deleted content stays in the maps with DeletedAt set until it is purged.
+// Everything trashed by one delete shares a DeletionID, so it can be
+// restored together. The live* lookups skip trashed content; callers must
+// hold m.mu.
+
+// deletion stamps content trashed by one delete.
+type deletion struct {
+	at time.Time
+	id primitive.ObjectID
+}
+
+func newDeletion() deletion {
+	return deletion{at: time.Now(), id: primitive.NewObjectID()}
+}
+
+func (m *Memory) liveContest(id primitive.ObjectID) (types.Contest, bool) {
+	contest, ok := m.contests[id]
+	return contest, ok && contest.DeletedAt == nil
+}
+
+func (m *Memory) liveQuestion(id primitive.ObjectID) (types.Question, bool) {
+	question, ok := m.questions[id]
+	return question, ok && question.DeletedAt == nil
+}
+
+func (m *Memory) liveTestCase(id primitive.ObjectID) (types.TestCase, bool) {
+	testCase, ok := m.testCases[id]
+	return testCase, ok && testCase.DeletedAt == nil
+}
+
+// trashQuestion moves a live question to the trash, with its test cases
+// if cascade, and reports whether there was one.
+func (m *Memory) trashQuestion(id primitive.ObjectID, d deletion, cascade bool) bool {
+	question, ok := m.liveQuestion(id)
+	if !ok {
+		return false
+	}
+	question.DeletedAt, question.DeletionID = &d.at, d.id
+	m.questions[id] = clone(question)
+
+	if cascade {
+		for _, testCaseId := range question.TestCaseIDs {
+			m.trashTestCase(testCaseId, d)
+		}
+	}
+	return true
+}
+
+func (m *Memory) trashTestCase(id primitive.ObjectID, d deletion) bool {
+	testCase, ok := m.liveTestCase(id)
+	if !ok {
+		return false
+	}
+	testCase.DeletedAt, testCase.DeletionID = &d.at, d.id
+	m.testCases[id] = clone(testCase)
+	return true
+}
+
+func (m *Memory) ListTrash(ctx context.Context) ([]types.TrashItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := []types.TrashItem{}
+	for _, contest := range m.contests {
+		if contest.DeletedAt != nil {
+			items = append(items, types.TrashItem{Kind: types.KindContest, ID: contest.ID, Title: contest.Title, DeletedAt: *contest.DeletedAt})
+		}
+	}
+	for _, question := range m.questions {
+		if question.DeletedAt != nil {
+			items = append(items, types.TrashItem{Kind: types.KindQuestion, ID: question.ID, Title: question.Title, DeletedAt: *question.DeletedAt})
+		}
+	}
+	for _, testCase := range m.testCases {
+		if testCase.DeletedAt != nil {
+			items = append(items, types.TrashItem{Kind: types.KindTestCase, ID: testCase.ID, DeletedAt: *testCase.DeletedAt})
+		}
+	}
+	storage.SortTrash(items)
+
+	return items, nil
+}
+
+func (m *Memory) RestoreDeleted(ctx context.Context, kind types.ContentKind, id string) error {
+	switch kind {
+	case types.KindContest:
+		objectId, err := parseID(id, "contest")
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		contest, ok := m.contests[objectId]
+		if !ok || contest.DeletedAt == nil {
+			return storage.NotFound("no deleted contest found with the given id")
+		}
+		deletionId := contest.DeletionID
+		contest.DeletedAt, contest.DeletionID = nil, primitive.NilObjectID
+		m.contests[objectId] = clone(contest)
+
+		for _, problem := range contest.Problems {
+			m.restoreQuestion(problem.QuestionID, deletionId)
+		}
+		return nil
+
+	case types.KindQuestion:
+		objectId, err := parseID(id, "question")
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		question, ok := m.questions[objectId]
+		if !ok || question.DeletedAt == nil {
+			return storage.NotFound("no deleted question found with the given id")
+		}
+		m.restoreQuestion(objectId, question.DeletionID)
+		return nil
+
+	case types.KindTestCase:
+		objectId, err := parseID(id, "test case")
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		testCase, ok := m.testCases[objectId]
+		if !ok || testCase.DeletedAt == nil {
+			return storage.NotFound("no deleted test case found with the given id")
+		}
+		m.restoreTestCase(objectId, testCase.DeletionID)
+		return nil
+
+	default:
+		return storage.Validation("unknown content kind %q", kind)
+	}
+}
+
+// restoreQuestion restores a question, and its test cases, if they were
+// trashed by the deletion being undone.
+func (m *Memory) restoreQuestion(id primitive.ObjectID, deletionId primitive.ObjectID) {
+	question, ok := m.questions[id]
+	if !ok || question.DeletedAt == nil || question.DeletionID != deletionId {
+		return
+	}
+	question.DeletedAt, question.DeletionID = nil, primitive.NilObjectID
+	m.questions[id] = clone(question)
+
+	for _, testCaseId := range question.TestCaseIDs {
+		m.restoreTestCase(testCaseId, deletionId)
+	}
+}
+
+func (m *Memory) restoreTestCase(id primitive.ObjectID, deletionId primitive.ObjectID) {
+	testCase, ok := m.testCases[id]
+	if !ok || testCase.DeletedAt == nil || testCase.DeletionID != deletionId {
+		return
+	}
+	testCase.DeletedAt, testCase.DeletionID = nil, primitive.NilObjectID
+	m.testCases[id] = clone(testCase)
+}
+
+func (m *Memory) PurgeDeleted(ctx context.Context, before time.Time, cascade bool) (*types.PurgeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := func(deletedAt *time.Time) bool {
+		return deletedAt != nil && deletedAt.Before(before)
+	}
+
+	contests := map[primitive.ObjectID]bool{}
+	questions := map[primitive.ObjectID]bool{}
+	testCases := map[primitive.ObjectID]bool{}
+	for id, contest := range m.contests {
+		if expired(contest.DeletedAt) {
+			contests[id] = true
+		}
+	}
+	for id, question := range m.questions {
+		if expired(question.DeletedAt) {
+			questions[id] = true
+		}
+	}
+	for id, testCase := range m.testCases {
+		if expired(testCase.DeletedAt) {
+			testCases[id] = true
+		}
+	}
+	if cascade {
+		for id := range questions {
+			for _, testCaseId := range m.questions[id].TestCaseIDs {
+				testCases[testCaseId] = true
+			}
+		}
+	}
+
+	result := &types.PurgeResult{}
+	if cascade {
+		for id, submission := range m.submissions {
+			if contests[submission.ContestID] || questions[submission.QuestionID] {
+				delete(m.submissions, id)
+				result.Submissions++
+			}
+		}
+	}
+	revisions := []types.Revision{}
+	for _, rev := range m.revisions {
+		if questions[rev.QuestionID] || (rev.TestCaseID != nil && testCases[*rev.TestCaseID]) {
+			result.Revisions++
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	m.revisions = revisions
+	for id := range testCases {
+		if _, ok := m.testCases[id]; ok {
+			delete(m.testCases, id)
+			result.TestCases++
+		}
+	}
+	for id := range questions {
+		if _, ok := m.questions[id]; ok {
+			delete(m.questions, id)
+			result.Questions++
+		}
+	}
+	for id := range contests {
+		delete(m.contests, id)
+		result.Contests++
+	}
+
+	// Drop references to what was purged, like $pull
+	for id, contest := range m.contests {
+		remaining := []types.ContestProblem{}
+		for _, problem := range contest.Problems {
+			if !questions[problem.QuestionID] {
+				remaining = append(remaining, problem)
+			}
+		}
+		if len(remaining) != len(contest.Problems) {
+			contest.Problems = remaining
+			m.contests[id] = clone(contest)
+		}
+	}
+	for id, question := range m.questions {
+		remaining := []primitive.ObjectID{}
+		for _, testCaseId := range question.TestCaseIDs {
+			if !testCases[testCaseId] {
+				remaining = append(remaining, testCaseId)
+			}
+		}
+		if len(remaining) != len(question.TestCaseIDs) {
+			question.TestCaseIDs = remaining
+			m.questions[id] = clone(question)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,367 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Every question is in the bank, whether it was created on its own or
+// through a contest. Contests refer to bank questions through their
+// problems list, so one question can appear in several contests.
+
+func (m *MongoDB) ListQuestions(ctx context.Context, filter types.QuestionFilter) (*types.QuestionList, error) {
+	query := bson.M{"deleted_at": nil}
+	if len(filter.Tags) > 0 {
+		query["tags"] = bson.M{"$all": filter.Tags}
+	}
+	if filter.Difficulty != "" {
+		query["difficulty"] = filter.Difficulty
+	}
+	if filter.Author != "" {
+		author, err := primitive.ObjectIDFromHex(filter.Author)
+		if err != nil {
+			return nil, storage.InvalidID("author")
+		}
+		query["created_by"] = author
+	}
+
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	collection := m.db.Collection("questions")
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit)).
+		SetProjection(bson.M{"solutions": 0, "validator": 0, "generators": 0})
+
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	questions := []types.QuestionBankEntry{}
+	if err := cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(questions))
+	for _, question := range questions {
+		ids = append(ids, question.ID)
+	}
+	usage, err := m.questionUsage(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range questions {
+		questions[i].Contests = usage[questions[i].ID]
+	}
+
+	return &types.QuestionList{
+		Questions: questions,
+		Total:     total,
+		Page:      filter.Page,
+		Limit:     filter.Limit,
+	}, nil
+}
+
+// questionUsage counts the live contests using each of the questions.
+func (m *MongoDB) questionUsage(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	usage := make(map[primitive.ObjectID]int, len(ids))
+	if len(ids) == 0 {
+		return usage, nil
+	}
+
+	listed := bson.D{{Key: "problems.question_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: append(bson.D{{Key: "deleted_at", Value: nil}}, listed...)}},
+		{{Key: "$unwind", Value: "$problems"}},
+		{{Key: "$match", Value: listed}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$problems.question_id"},
+			{Key: "contests", Value: bson.D{{Key: "$addToSet", Value: "$_id"}}},
+		}}},
+		{{Key: "$project", Value: bson.D{{Key: "contests", Value: bson.D{{Key: "$size", Value: "$contests"}}}}}},
+	}
+
+	cursor, err := m.db.Collection("contests").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error counting question usage: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Contests int                `bson:"contests"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("error counting question usage: %v", err)
+	}
+	for _, count := range counts {
+		usage[count.ID] = count.Contests
+	}
+	return usage, nil
+}
+
+// LinkQuestionToContest adds an existing question to a contest.
+func (m *MongoDB) LinkQuestionToContest(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error {
+	contestObjID, err := primitive.ObjectIDFromHex(contestId)
+	if err != nil {
+		return storage.InvalidID("contest")
+	}
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.updateProblems(ctx, contestObjID, func(ctx context.Context, problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		err := m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Err()
+		if err == mongo.ErrNoDocuments {
+			return nil, storage.NotFound("no question found with the given id")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error checking question existence: %v", err)
+		}
+
+		if storage.ProblemIndex(problems, questionObjID) >= 0 {
+			return nil, storage.Conflict("question is already in the contest")
+		}
+		return storage.PlaceProblem(problems, storage.NewProblem(questionObjID, placement), placement.Position), nil
+	})
+}
+
+// UpdateContestProblem moves a question within a contest and replaces how
+// it is labelled and scored there.
+func (m *MongoDB) UpdateContestProblem(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error {
+	contestObjID, err := primitive.ObjectIDFromHex(contestId)
+	if err != nil {
+		return storage.InvalidID("contest")
+	}
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.updateProblems(ctx, contestObjID, func(ctx context.Context, problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		index := storage.ProblemIndex(problems, questionObjID)
+		if index < 0 {
+			return nil, storage.NotFound("no question found with the given id in the contest")
+		}
+
+		position := placement.Position
+		if position == 0 {
+			position = index + 1
+		}
+		remaining := append(append([]types.ContestProblem{}, problems[:index]...), problems[index+1:]...)
+		return storage.PlaceProblem(remaining, storage.NewProblem(questionObjID, placement), position), nil
+	})
+}
+
+// ReorderContestProblems puts a contest's questions in the given order.
+func (m *MongoDB) ReorderContestProblems(ctx context.Context, contestId string, questionIds []string) error {
+	contestObjID, err := primitive.ObjectIDFromHex(contestId)
+	if err != nil {
+		return storage.InvalidID("contest")
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.updateProblems(ctx, contestObjID, func(ctx context.Context, problems []types.ContestProblem) ([]types.ContestProblem, error) {
+		return storage.ReorderProblems(problems, questionIds)
+	})
+}
+
+// updateProblems replaces a live contest's problems list with what change
+// makes of it, provided the labels stay unique.
+func (m *MongoDB) updateProblems(ctx context.Context, contestId primitive.ObjectID, change func(ctx context.Context, problems []types.ContestProblem) ([]types.ContestProblem, error)) error {
+	contests := m.db.Collection("contests")
+	return m.withTransaction(ctx, func(ctx context.Context) error {
+		var contest types.Contest
+		err := contests.FindOne(ctx, bson.M{"_id": contestId, "deleted_at": nil}).Decode(&contest)
+		if err == mongo.ErrNoDocuments {
+			return storage.NotFound("no contest found with the given id")
+		}
+		if err != nil {
+			return fmt.Errorf("error checking contest existence: %v", err)
+		}
+
+		if contest.Problems == nil {
+			contest.Problems = []types.ContestProblem{}
+		}
+		problems, err := change(ctx, contest.Problems)
+		if err != nil {
+			return err
+		}
+		if err := storage.CheckProblemLabels(problems); err != nil {
+			return err
+		}
+
+		// Matching on the list that was read also catches a concurrent
+		// change when running without transactions
+		result, err := contests.UpdateOne(ctx,
+			bson.M{"_id": contestId, "deleted_at": nil, "problems": contest.Problems},
+			bson.M{"$set": bson.M{"problems": problems}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update contest: %v", err)
+		}
+		if result.MatchedCount == 0 {
+			return storage.Conflict("contest was modified concurrently, try again")
+		}
+		return nil
+	})
+}
+
+// DeleteQuestionById moves a question out of the bank into the trash, with
+// its test cases if cascade. A question live contests still use is only
+// trashed if force; it then disappears from those contests until
+// restored.
+func (m *MongoDB) DeleteQuestionById(ctx context.Context, id string, cascade bool, force bool) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	d := newDeletion()
+	return m.withTransaction(ctx, func(ctx context.Context) error {
+		err := m.db.Collection("questions").FindOne(ctx, bson.M{"_id": objectId, "deleted_at": nil}).Err()
+		if err == mongo.ErrNoDocuments {
+			return storage.NotFound("no question found with the given id")
+		}
+		if err != nil {
+			return fmt.Errorf("error checking question existence: %v", err)
+		}
+
+		if !force {
+			usage, err := m.questionUsage(ctx, []primitive.ObjectID{objectId})
+			if err != nil {
+				return err
+			}
+			if n := usage[objectId]; n > 0 {
+				return storage.Conflict("question is used by %d contests; remove it from them first or force the delete", n)
+			}
+		}
+
+		return m.trashQuestions(ctx, []primitive.ObjectID{objectId}, d, cascade)
+	})
+}
+
+func (m *MongoDB) GetQuestionSolutions(ctx context.Context, questionId string) ([]types.Solution, error) {
+	objectId, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var question struct {
+		Solutions []types.Solution `bson:"solutions"`
+	}
+	err = m.db.Collection("questions").FindOne(ctx,
+		bson.M{"_id": objectId, "deleted_at": nil},
+		options.FindOne().SetProjection(bson.M{"solutions": 1}),
+	).Decode(&question)
+	if err == mongo.ErrNoDocuments {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if question.Solutions == nil {
+		return []types.Solution{}, nil
+	}
+	return question.Solutions, nil
+}
+
+func (m *MongoDB) SetQuestionSolutions(ctx context.Context, questionId string, solutions []types.Solution) error {
+	if solutions == nil {
+		solutions = []types.Solution{}
+	}
+	return m.updateQuestionTools(ctx, questionId, bson.M{"$set": bson.M{"solutions": solutions}})
+}
+
+func (m *MongoDB) GetQuestionTools(ctx context.Context, questionId string) (*types.QuestionTools, error) {
+	objectId, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	var tools types.QuestionTools
+	err = m.db.Collection("questions").FindOne(ctx,
+		bson.M{"_id": objectId, "deleted_at": nil},
+		options.FindOne().SetProjection(bson.M{"solutions": 1, "validator": 1, "generators": 1}),
+	).Decode(&tools)
+	if err == mongo.ErrNoDocuments {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tools.Solutions == nil {
+		tools.Solutions = []types.Solution{}
+	}
+	if tools.Generators == nil {
+		tools.Generators = []types.Generator{}
+	}
+	return &tools, nil
+}
+
+func (m *MongoDB) SetQuestionValidator(ctx context.Context, questionId string, validator *types.Program) error {
+	if validator == nil {
+		return m.updateQuestionTools(ctx, questionId, bson.M{"$unset": bson.M{"validator": ""}})
+	}
+	return m.updateQuestionTools(ctx, questionId, bson.M{"$set": bson.M{"validator": validator}})
+}
+
+func (m *MongoDB) SetQuestionGenerators(ctx context.Context, questionId string, generators []types.Generator) error {
+	if generators == nil {
+		generators = []types.Generator{}
+	}
+	return m.updateQuestionTools(ctx, questionId, bson.M{"$set": bson.M{"generators": generators}})
+}
+
+func (m *MongoDB) updateQuestionTools(ctx context.Context, questionId string, update bson.M) error {
+	objectId, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	result, err := m.db.Collection("questions").UpdateOne(ctx, bson.M{"_id": objectId, "deleted_at": nil}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update question: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return storage.NotFound("no question found with the given id")
+	}
+	return nil
+}
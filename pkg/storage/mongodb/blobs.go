@@ -0,0 +1,97 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const blobBucket = "blobs"
+
+// BlobStore keeps blobs in the "blobs" GridFS bucket, one file per blob
+// named by its hash. Uploads are stored under a pending name and renamed
+// once their hash is known; a crash in between leaves a pending file no
+// blob refers to.
+type BlobStore struct {
+	db *mongo.Database
+}
+
+func (m *MongoDB) NewBlobStore() *BlobStore {
+	return &BlobStore{db: m.db}
+}
+
+// bucket returns a bucket for one operation, since deadlines are set on
+// the bucket rather than per call.
+func (s *BlobStore) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(s.db, options.GridFSBucket().SetName(blobBucket))
+}
+
+func (s *BlobStore) Put(ctx context.Context, r io.Reader) (types.BlobRef, error) {
+	bucket, err := s.bucket()
+	if err != nil {
+		return types.BlobRef{}, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetWriteDeadline(deadline)
+	}
+
+	id := primitive.NewObjectID()
+	hr := blob.NewHashingReader(r)
+	if err := bucket.UploadFromStreamWithID(id, "pending-"+id.Hex(), hr); err != nil {
+		return types.BlobRef{}, err
+	}
+
+	ref := hr.Ref()
+	if _, err := s.Stat(ctx, ref.Hash); err == nil {
+		return ref, bucket.DeleteContext(ctx, id)
+	}
+	// Concurrent uploads of the same content may both get here; either
+	// copy serves the blob
+	if err := bucket.RenameContext(ctx, id, ref.Hash); err != nil {
+		return types.BlobRef{}, err
+	}
+	return ref, nil
+}
+
+func (s *BlobStore) Open(ctx context.Context, hash string) (io.ReadCloser, error) {
+	if !blob.ValidHash(hash) {
+		return nil, blob.NotFound(hash)
+	}
+	bucket, err := s.bucket()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStreamByName(hash)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, blob.NotFound(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *BlobStore) Stat(ctx context.Context, hash string) (types.BlobRef, error) {
+	if !blob.ValidHash(hash) {
+		return types.BlobRef{}, blob.NotFound(hash)
+	}
+	var file struct {
+		Length int64 `bson:"length"`
+	}
+	err := s.db.Collection(blobBucket+".files").FindOne(ctx, bson.M{"filename": hash}).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		return types.BlobRef{}, blob.NotFound(hash)
+	}
+	if err != nil {
+		return types.BlobRef{}, err
+	}
+	return types.BlobRef{Hash: hash, Size: file.Length}, nil
+}
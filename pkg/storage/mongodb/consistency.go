@@ -0,0 +1,192 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DanglingReference is a parent listing a child document that doesn't
+// exist.
+type DanglingReference struct {
+	Parent primitive.ObjectID `json:"parent"`
+	Child  primitive.ObjectID `json:"child"`
+}
+
+// ConsistencyReport lists the problems CheckConsistency found and, when
+// asked to repair, fixed.
+type ConsistencyReport struct {
+	// Contests listing questions that don't exist
+	DanglingQuestions []DanglingReference `json:"dangling_questions"`
+	// Questions listing test cases that don't exist
+	DanglingTestCases []DanglingReference `json:"dangling_test_cases"`
+	// Test cases no question lists
+	OrphanedTestCases []primitive.ObjectID `json:"orphaned_test_cases"`
+	Repaired          bool                 `json:"repaired"`
+}
+
+// Problems is the total number of problems found.
+func (r *ConsistencyReport) Problems() int {
+	return len(r.DanglingQuestions) + len(r.DanglingTestCases) + len(r.OrphanedTestCases)
+}
+
+// CheckConsistency finds references to missing documents and test cases
+// nothing refers to. Questions need no contest, since they live in the
+// bank. Test cases created within grace of now are not reported as
+// orphans, since they may belong to a write still in progress. With
+// repair, dangling references are removed and orphans deleted.
+func (m *MongoDB) CheckConsistency(ctx context.Context, repair bool, grace time.Duration) (*ConsistencyReport, error) {
+	ctx, cancel := m.aggregateContext(ctx)
+	defer cancel()
+
+	var contests []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Problems []struct {
+			QuestionID primitive.ObjectID `bson:"question_id"`
+		} `bson:"problems"`
+	}
+	if err := m.findAll(ctx, "contests", bson.M{}, bson.M{"problems.question_id": 1}, &contests); err != nil {
+		return nil, err
+	}
+	var questions []struct {
+		ID          primitive.ObjectID   `bson:"_id"`
+		TestCaseIDs []primitive.ObjectID `bson:"test_case_ids"`
+	}
+	if err := m.findAll(ctx, "questions", bson.M{}, bson.M{"test_case_ids": 1}, &questions); err != nil {
+		return nil, err
+	}
+	var testCases []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := m.findAll(ctx, "test_cases", bson.M{}, bson.M{"_id": 1}, &testCases); err != nil {
+		return nil, err
+	}
+
+	report := &ConsistencyReport{
+		DanglingQuestions: []DanglingReference{},
+		DanglingTestCases: []DanglingReference{},
+		OrphanedTestCases: []primitive.ObjectID{},
+	}
+	cutoff := time.Now().Add(-grace)
+
+	questionExists := make(map[primitive.ObjectID]bool, len(questions))
+	for _, question := range questions {
+		questionExists[question.ID] = true
+	}
+	testCaseExists := make(map[primitive.ObjectID]bool, len(testCases))
+	for _, testCase := range testCases {
+		testCaseExists[testCase.ID] = true
+	}
+
+	for _, contest := range contests {
+		for _, problem := range contest.Problems {
+			if !questionExists[problem.QuestionID] {
+				report.DanglingQuestions = append(report.DanglingQuestions, DanglingReference{Parent: contest.ID, Child: problem.QuestionID})
+			}
+		}
+	}
+
+	listedTestCases := map[primitive.ObjectID]bool{}
+	for _, question := range questions {
+		for _, id := range question.TestCaseIDs {
+			if !testCaseExists[id] {
+				report.DanglingTestCases = append(report.DanglingTestCases, DanglingReference{Parent: question.ID, Child: id})
+				continue
+			}
+			listedTestCases[id] = true
+		}
+	}
+
+	for _, testCase := range testCases {
+		if !listedTestCases[testCase.ID] && testCase.ID.Timestamp().Before(cutoff) {
+			report.OrphanedTestCases = append(report.OrphanedTestCases, testCase.ID)
+		}
+	}
+
+	if !repair || report.Problems() == 0 {
+		return report, nil
+	}
+
+	for _, ref := range report.DanglingQuestions {
+		if _, err := m.db.Collection("contests").UpdateOne(ctx, bson.M{"_id": ref.Parent}, bson.M{"$pull": bson.M{"problems": bson.M{"question_id": ref.Child}}}); err != nil {
+			return report, fmt.Errorf("error removing question %s from contest %s: %v", ref.Child.Hex(), ref.Parent.Hex(), err)
+		}
+	}
+	for _, ref := range report.DanglingTestCases {
+		if _, err := m.db.Collection("questions").UpdateOne(ctx, bson.M{"_id": ref.Parent}, bson.M{"$pull": bson.M{"test_case_ids": ref.Child}}); err != nil {
+			return report, fmt.Errorf("error removing test case %s from question %s: %v", ref.Child.Hex(), ref.Parent.Hex(), err)
+		}
+	}
+	if len(report.OrphanedTestCases) > 0 {
+		// Re-check the reference so a test case added to a question since
+		// the scan survives
+		filter := bson.M{"_id": bson.M{"$in": report.OrphanedTestCases}}
+		if err := m.deleteUnreferenced(ctx, "test_cases", filter, "questions", "test_case_ids"); err != nil {
+			return report, err
+		}
+	}
+
+	report.Repaired = true
+	return report, nil
+}
+
+// findAll decodes every document in collection matching filter into
+// results, keeping only the fields in projection unless it is nil.
+func (m *MongoDB) findAll(ctx context.Context, collection string, filter, projection bson.M, results interface{}) error {
+	opts := options.Find()
+	if projection != nil {
+		opts.SetProjection(projection)
+	}
+	cursor, err := m.db.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("error reading %s: %v", collection, err)
+	}
+	return nil
+}
+
+// deleteUnreferenced deletes the documents matching filter that no
+// document in parents lists under field.
+func (m *MongoDB) deleteUnreferenced(ctx context.Context, collection string, filter bson.M, parents, field string) error {
+	return m.withTransaction(ctx, func(ctx context.Context) error {
+		cursor, err := m.db.Collection(collection).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return err
+		}
+
+		var ids []primitive.ObjectID
+		for _, doc := range docs {
+			err := m.db.Collection(parents).FindOne(ctx, bson.M{field: doc.ID}).Err()
+			if err == mongo.ErrNoDocuments {
+				ids = append(ids, doc.ID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if _, err := m.db.Collection(collection).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return fmt.Errorf("error deleting orphaned %s: %v", collection, err)
+		}
+		return nil
+	})
+}
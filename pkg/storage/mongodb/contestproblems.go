@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// contestProblemsUp turns each contest's question_ids into a problems list
+// of {question_id} entries in the same order, leaving room for per-contest
+// overrides.
+func contestProblemsUp(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("contests").UpdateMany(ctx,
+		bson.M{"question_ids": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"problems": bson.M{"$map": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$question_ids", bson.A{}}},
+				"in":    bson.M{"question_id": "$$this"},
+			}}}}},
+			{{Key: "$unset", Value: "question_ids"}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error converting contests: %v", err)
+	}
+	return nil
+}
+
+// contestProblemsDown turns problems back into question_ids. Per-contest
+// overrides are lost.
+func contestProblemsDown(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("contests").UpdateMany(ctx,
+		bson.M{"problems": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"question_ids": bson.M{"$map": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$problems", bson.A{}}},
+				"in":    "$$this.question_id",
+			}}}}},
+			{{Key: "$unset", Value: "problems"}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error converting contests: %v", err)
+	}
+	return nil
+}
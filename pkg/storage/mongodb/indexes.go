@@ -0,0 +1,153 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexes declares every index the application relies on, by collection.
+// EnsureIndexes creates any that are missing; name each one so a changed
+// definition fails loudly instead of silently creating a second index.
+var indexes = map[string][]mongo.IndexModel{
+	"users": {
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}},
+			Options: options.Index().SetName("studentId_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "inviteTokenHash", Value: 1}},
+			Options: options.Index().SetName("inviteTokenHash").
+				SetPartialFilterExpression(bson.M{"inviteTokenHash": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("createdAt"),
+		},
+	},
+	"contests": {
+		{
+			Keys:    bson.D{{Key: "start_time", Value: -1}},
+			Options: options.Index().SetName("start_time"),
+		},
+		{
+			// Finds the contests using a bank question
+			Keys:    bson.D{{Key: "problems.question_id", Value: 1}},
+			Options: options.Index().SetName("problems_question_id"),
+		},
+		trashIndex,
+	},
+	"questions": {
+		trashIndex,
+	},
+	"test_cases": {
+		trashIndex,
+	},
+	"submissions": {
+		{
+			Keys: bson.D{
+				{Key: "contest_id", Value: 1},
+				{Key: "user_id", Value: 1},
+				{Key: "question_id", Value: 1},
+			},
+			Options: options.Index().SetName("contest_user_question"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "question_id", Value: 1}},
+			Options: options.Index().SetName("user_question"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("user_status"),
+		},
+		{
+			Keys:    bson.D{{Key: "submitted_at", Value: -1}},
+			Options: options.Index().SetName("submitted_at"),
+		},
+	},
+	"revisions": {
+		{
+			// Numbers each question's revisions once, so concurrent edits
+			// can't both take the next number
+			Keys:    bson.D{{Key: "question_id", Value: 1}, {Key: "number", Value: 1}},
+			Options: options.Index().SetName("question_number_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "test_case_id", Value: 1}},
+			Options: options.Index().SetName("test_case_id").
+				SetPartialFilterExpression(bson.M{"test_case_id": bson.M{"$exists": true}}),
+		},
+	},
+	"audit_log": {
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("created_at"),
+		},
+	},
+}
+
+// trashIndex serves the trash listing and purge job. Partial, so live
+// content, nearly all of it, isn't indexed at all.
+var trashIndex = mongo.IndexModel{
+	Keys: bson.D{{Key: "deleted_at", Value: 1}},
+	Options: options.Index().SetName("deleted_at").
+		SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$exists": true}}),
+}
+
+// EnsureIndexes creates the declared indexes. Existing indexes with the
+// same definition are left alone, so it is cheap to call on every start.
+func (m *MongoDB) EnsureIndexes(ctx context.Context) error {
+	for collection, models := range indexes {
+		if _, err := m.db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return fmt.Errorf("cannot create unique index on %s, remove the duplicate documents first: %v", collection, err)
+			}
+			return fmt.Errorf("error creating indexes on %s: %v", collection, err)
+		}
+	}
+	return nil
+}
+
+var duplicateIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+
+// duplicateIndex reports the name of the unique index err violated.
+func duplicateIndex(err error) (string, bool) {
+	if !mongo.IsDuplicateKeyError(err) {
+		return "", false
+	}
+
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) {
+		for _, we := range writeErr.WriteErrors {
+			if match := duplicateIndexPattern.FindStringSubmatch(we.Message); match != nil {
+				return match[1], true
+			}
+		}
+	}
+	if match := duplicateIndexPattern.FindStringSubmatch(err.Error()); match != nil {
+		return match[1], true
+	}
+	return "", true
+}
+
+// conflictOrErr turns a duplicate key error into a storage conflict and
+// returns any other error unchanged.
+func conflictOrErr(err error, entity string) error {
+	if index, ok := duplicateIndex(err); ok {
+		if index == "" {
+			return storage.Conflict("%s already exists", entity)
+		}
+		return storage.Conflict("%s conflicts with an existing one on %s", entity, index)
+	}
+	return err
+}
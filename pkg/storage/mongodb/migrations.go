@@ -0,0 +1,291 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration evolves documents from one schema version to the next. Up and
+// Down should be safe to re-run, since a migration that fails part way is
+// not recorded and will run again from the start.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrations is the schema history, oldest first. Append new migrations
+// with the next version number; never renumber or edit applied ones.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "store question and test case references as ObjectIDs",
+		Up:          objectIDsUp,
+		Down:        objectIDsDown,
+	},
+	{
+		Version:     2,
+		Description: "list contest questions as problems with per-contest overrides",
+		Up:          contestProblemsUp,
+		Down:        contestProblemsDown,
+	},
+}
+
+const lockID = "lock"
+
+// MigrationState reports whether a known migration has been applied.
+type MigrationState struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Applied     bool      `json:"applied"`
+	AppliedAt   time.Time `json:"applied_at,omitempty"`
+}
+
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// ErrSchemaOutdated is returned by CheckSchema when the database is not at
+// the version this build expects.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// ErrMigrationLocked is returned when another process holds the migration
+// lock.
+var ErrMigrationLocked = errors.New("migrations are locked by another process")
+
+// LatestVersion is the schema version this build expects.
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func (m *MongoDB) schemaMigrations() *mongo.Collection {
+	return m.db.Collection("schema_migrations")
+}
+
+func (m *MongoDB) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	cursor, err := m.schemaMigrations().Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+
+	applied := make(map[int]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// MigrationStatus lists every known migration and whether it is applied.
+func (m *MongoDB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		record, ok := applied[migration.Version]
+		states = append(states, MigrationState{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     ok,
+			AppliedAt:   record.AppliedAt,
+		})
+	}
+	return states, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated unless every known migration has
+// been applied and none the database has seen are unknown to this build.
+func (m *MongoDB) CheckSchema(ctx context.Context) error {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migrations pending, run portal-cli migrate up", ErrSchemaOutdated, pending)
+	}
+
+	for version := range applied {
+		if version > LatestVersion() {
+			return fmt.Errorf("%w: database is at version %d but this build only knows up to %d", ErrSchemaOutdated, version, LatestVersion())
+		}
+	}
+
+	return nil
+}
+
+// BaselineFresh records every migration as applied on a database with no
+// migration history and no data, so a new deployment starts at the latest
+// schema instead of waiting for migrations with nothing to migrate. It
+// reports whether it did.
+func (m *MongoDB) BaselineFresh(ctx context.Context) (bool, error) {
+	baselined := false
+	err := m.withMigrationLock(ctx, func() error {
+		applied, err := m.appliedMigrations(ctx)
+		if err != nil || len(applied) > 0 {
+			return err
+		}
+		names, err := m.db.ListCollectionNames(ctx, bson.M{"name": bson.M{"$not": primitive.Regex{Pattern: `^system\.`}}})
+		if err != nil {
+			return fmt.Errorf("error listing collections: %v", err)
+		}
+		for _, name := range names {
+			if name != m.schemaMigrations().Name() {
+				return nil
+			}
+		}
+		if len(migrations) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		records := make([]interface{}, 0, len(migrations))
+		for _, migration := range migrations {
+			records = append(records, appliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: now})
+		}
+		if _, err := m.schemaMigrations().InsertMany(ctx, records); err != nil {
+			return fmt.Errorf("error recording migrations: %v", err)
+		}
+		baselined = true
+		return nil
+	})
+	return baselined, err
+}
+
+// MigrateUp applies pending migrations in order up to and including target,
+// or all of them if target is 0, and returns those it applied.
+func (m *MongoDB) MigrateUp(ctx context.Context, target int) ([]Migration, error) {
+	if target == 0 {
+		target = LatestVersion()
+	}
+
+	var done []Migration
+	err := m.withMigrationLock(ctx, func() error {
+		applied, err := m.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := migration.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %v", migration.Version, migration.Description, err)
+			}
+			_, err := m.schemaMigrations().InsertOne(ctx, appliedMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d applied but not recorded: %v", migration.Version, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// MigrateDown reverts applied migrations newer than target, newest first,
+// and returns those it reverted.
+func (m *MongoDB) MigrateDown(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	err := m.withMigrationLock(ctx, func() error {
+		applied, err := m.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if migration.Version <= target {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if err := migration.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("reverting migration %d (%s) failed: %v", migration.Version, migration.Description, err)
+			}
+			if _, err := m.schemaMigrations().DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+				return fmt.Errorf("migration %d reverted but still recorded: %v", migration.Version, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// withMigrationLock runs fn while holding a lock document, so that two
+// instances starting together don't both migrate.
+func (m *MongoDB) withMigrationLock(ctx context.Context, fn func() error) error {
+	host, _ := os.Hostname()
+	_, err := m.schemaMigrations().InsertOne(ctx, bson.M{
+		"_id":       lockID,
+		"host":      host,
+		"pid":       os.Getpid(),
+		"locked_at": time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w; if no migration is running, clear it with portal-cli migrate unlock", ErrMigrationLocked)
+	}
+	if err != nil {
+		return fmt.Errorf("error taking migration lock: %v", err)
+	}
+
+	defer func() {
+		// Release even if ctx was cancelled part way through
+		releaseCtx, cancel := context.WithTimeout(context.Background(), m.timeouts.Write)
+		defer cancel()
+		m.schemaMigrations().DeleteOne(releaseCtx, bson.M{"_id": lockID})
+	}()
+
+	return fn()
+}
+
+// UnlockMigrations removes a migration lock left behind by a process that
+// died, reporting whether there was one.
+func (m *MongoDB) UnlockMigrations(ctx context.Context) (bool, error) {
+	result, err := m.schemaMigrations().DeleteOne(ctx, bson.M{"_id": lockID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
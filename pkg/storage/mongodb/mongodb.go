@@ -3,10 +3,12 @@ package mongodb
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/statement"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,13 +19,19 @@ import (
 type MongoDB struct {
 	client *mongo.Client
 	db *mongo.Database
+	timeouts config.DatabaseTimeouts
+	transactions bool
 }
 
 func New(cfg *config.Config) (*MongoDB, error) {
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.DatabaseTimeouts.Connect)
     defer cancel()
 
-    clientOptions := options.Client().ApplyURI(cfg.DatabaseURL)
+    // Decode free-form values such as test case input as bson.M, which
+    // encodes to JSON as an object, rather than the default bson.D
+    clientOptions := options.Client().
+        ApplyURI(cfg.DatabaseURL).
+        SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
     client, err := mongo.Connect(ctx, clientOptions)
     if err != nil {
         return nil, err
@@ -34,46 +42,74 @@ func New(cfg *config.Config) (*MongoDB, error) {
         return nil, err
     }
 
+    transactions, err := supportsTransactions(ctx, client)
+    if err != nil {
+        return nil, err
+    }
+
     db := client.Database(cfg.DatabaseName)
     return &MongoDB{
-        client: client,
-        db:     db,
+        client:       client,
+        db:           db,
+        timeouts:     cfg.DatabaseTimeouts,
+        transactions: transactions,
     }, nil
 }
 
-// User operations
-func (m *MongoDB) CreateUser(name, email, password, studentId string, role types.Role) (string, error) {
-    collection := m.db.Collection("users")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
-    
-    emailCount, err := collection.CountDocuments(ctx, bson.M{"email": email})
-    if err != nil {
-        return "", err
-    }
-    if emailCount > 0 {
-        return "", fmt.Errorf("user with email %s already exists", email)
-    }
+func (m *MongoDB) Close(ctx context.Context) error {
+    return m.client.Disconnect(ctx)
+}
 
-    studentIdCount, err := collection.CountDocuments(ctx, bson.M{"studentId": studentId})
-    if err != nil {
-        return "", err
-    }
-    if studentIdCount > 0 {
-        return "", fmt.Errorf("user with student ID %s already exists", studentId)
-    }
+// Every operation runs under the caller's context, so a client hanging up
+// or the server shutting down cancels it, bounded further by the timeout
+// configured for its kind of work.
+
+func (m *MongoDB) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(ctx, m.timeouts.Read)
+}
+
+func (m *MongoDB) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(ctx, m.timeouts.Write)
+}
 
-    user := types.User{
-        ID:        primitive.NewObjectID(),
+func (m *MongoDB) aggregateContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(ctx, m.timeouts.Aggregate)
+}
+
+// User operations
+func (m *MongoDB) CreateUser(ctx context.Context, name, email, password, studentId string, role types.Role) (string, error) {
+    return m.InsertUser(ctx, types.User{
         Name:      name,
         Email:     email,
         StudentId: studentId,
         Password:  password,
-        CreatedAt: time.Now(),
         Role:      role,
+    })
+}
+
+func (m *MongoDB) InsertUser(ctx context.Context, user types.User) (string, error) {
+    collection := m.db.Collection("users")
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+    
+    user.ID = primitive.NewObjectID()
+    user.CreatedAt = time.Now()
+    if user.Role == "" {
+        user.Role = types.RoleUser
     }
 
+    // Uniqueness is enforced by the email_unique and studentId_unique
+    // indexes, so concurrent signups can't both get through
     result, err := collection.InsertOne(ctx, user)
+    if index, ok := duplicateIndex(err); ok {
+        switch index {
+        case "email_unique":
+            return "", storage.Conflict("user with email %s already exists", user.Email)
+        case "studentId_unique":
+            return "", storage.Conflict("user with student ID %s already exists", user.StudentId)
+        }
+        return "", conflictOrErr(err, "user")
+    }
     if err != nil {
         return "", err
     }
@@ -83,148 +119,538 @@ func (m *MongoDB) CreateUser(name, email, password, studentId string, role types
 
 
 
-func (m *MongoDB) GetUserByEmail(email string) (*types.User, error) {
+func (m *MongoDB) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
     collection := m.db.Collection("users")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.readContext(ctx)
     defer cancel()
 
     var user types.User
     err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
     if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, storage.NotFound("no user found with the given email")
+        }
         return nil, err
     }
 
     return &user, nil
 }
 
-func (m *MongoDB) CreateContest(contest types.Contest) (string, error) {
-    collection := m.db.Collection("contests")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *MongoDB) GetUserByStudentId(ctx context.Context, studentId string) (*types.User, error) {
+    collection := m.db.Collection("users")
+    ctx, cancel := m.readContext(ctx)
     defer cancel()
 
-    if err := validator.New().Struct(contest); err != nil {
-        validateErrs := err.(validator.ValidationErrors)
-        return "", fmt.Errorf("validation failed: %v", validateErrs)
+    var user types.User
+    err := collection.FindOne(ctx, bson.M{"studentId": studentId}).Decode(&user)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, storage.NotFound("no user found with the given student ID")
+        }
+        return nil, err
     }
 
-    if contest.QuestionIDs == nil {
-        contest.QuestionIDs = []string{}
+    return &user, nil
+}
+
+func (m *MongoDB) AcceptInvitation(ctx context.Context, tokenHash string, password string) error {
+    collection := m.db.Collection("users")
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    filter := bson.M{
+        "inviteTokenHash": tokenHash,
+        "inviteExpiresAt": bson.M{"$gt": time.Now()},
+    }
+    update := bson.M{
+        "$set":   bson.M{"password": password, "updatedAt": time.Now()},
+        "$unset": bson.M{"inviteTokenHash": "", "inviteExpiresAt": ""},
     }
 
-    result, err := collection.InsertOne(ctx, contest)
+    result, err := collection.UpdateOne(ctx, filter, update)
     if err != nil {
-        return "", err
+        return fmt.Errorf("failed to accept invitation: %v", err)
     }
 
-    return result.InsertedID.(primitive.ObjectID).Hex(), nil
+    if result.MatchedCount == 0 {
+        return storage.Validation("invitation is invalid or has expired")
+    }
+
+    return nil
 }
 
-func (m *MongoDB) EditContestById(id string, updateData types.Contest) error {
-    contestObjID, err := primitive.ObjectIDFromHex(id)
+func (m *MongoDB) GetUserById(ctx context.Context, id string) (*types.User, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return fmt.Errorf("invalid contest id format")
+        return nil, storage.InvalidID("user")
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    collection := m.db.Collection("users")
+    ctx, cancel := m.readContext(ctx)
     defer cancel()
 
-    var contest types.Contest
-    err = m.db.Collection("contests").FindOne(ctx, bson.M{"_id": contestObjID}).Decode(&contest)
+    var user types.User
+    err = collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&user)
     if err != nil {
         if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("no contest found with the given id")
+            return nil, storage.NotFound("no user found with the given id")
         }
-        return fmt.Errorf("error checking contest existence: %v", err)
+        return nil, err
     }
 
-    // Prepare the update
+    return &user, nil
+}
+
+func (m *MongoDB) UpdateUserProfile(ctx context.Context, id string, profile types.ProfileUpdate) error {
     update := bson.M{}
-    if updateData.Title != "" {
-        update["title"] = updateData.Title
+    if profile.Name != "" {
+        update["name"] = profile.Name
     }
-    if !updateData.StartTime.IsZero() {
-        update["start_time"] = updateData.StartTime
+    if profile.Avatar != "" {
+        update["avatar"] = profile.Avatar
     }
-    if !updateData.EndTime.IsZero() {
-        update["end_time"] = updateData.EndTime
+    if profile.Bio != "" {
+        update["bio"] = profile.Bio
     }
-    if updateData.Description != "" {
-        update["description"] = updateData.Description
+    if profile.PreferredLanguage != "" {
+        update["preferredLanguage"] = profile.PreferredLanguage
     }
-    if updateData.CreatedBy != "" {
-        update["created_by"] = updateData.CreatedBy
+    if len(update) == 0 {
+        return nil
     }
+    update["updatedAt"] = time.Now()
 
-    if len(update) > 0 {
-        _, err = m.db.Collection("contests").UpdateOne(ctx, bson.M{"_id": contestObjID}, bson.M{"$set": update})
-        if err != nil {
-            return fmt.Errorf("failed to update contest: %v", err)
+    return m.updateUser(ctx, id, update)
+}
+
+func (m *MongoDB) ListUsers(ctx context.Context, filter types.UserFilter) (*types.UserList, error) {
+    collection := m.db.Collection("users")
+    ctx, cancel := m.readContext(ctx)
+    defer cancel()
+
+    query := bson.M{}
+    if filter.Query != "" {
+        pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+        query["$or"] = bson.A{
+            bson.M{"name": pattern},
+            bson.M{"email": pattern},
+            bson.M{"studentId": pattern},
         }
     }
+    if filter.Role != "" {
+        query["role"] = filter.Role
+    }
+    if filter.Disabled != nil {
+        query["disabled"] = *filter.Disabled
+    }
+
+    total, err := collection.CountDocuments(ctx, query)
+    if err != nil {
+        return nil, err
+    }
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+        SetSkip(int64((filter.Page - 1) * filter.Limit)).
+        SetLimit(int64(filter.Limit))
+
+    cursor, err := collection.Find(ctx, query, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    users := []types.User{}
+    if err := cursor.All(ctx, &users); err != nil {
+        return nil, err
+    }
+
+    return &types.UserList{
+        Users: users,
+        Total: total,
+        Page:  filter.Page,
+        Limit: filter.Limit,
+    }, nil
+}
+
+func (m *MongoDB) UpdateUserRole(ctx context.Context, id string, role types.Role) error {
+    return m.updateUser(ctx, id, bson.M{"role": role, "updatedAt": time.Now()})
+}
+
+func (m *MongoDB) SetUserDisabled(ctx context.Context, id string, disabled bool) error {
+    return m.updateUser(ctx, id, bson.M{"disabled": disabled, "updatedAt": time.Now()})
+}
+
+func (m *MongoDB) updateUser(ctx context.Context, id string, update bson.M) error {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return storage.InvalidID("user")
+    }
+
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    result, err := m.db.Collection("users").UpdateOne(ctx, bson.M{"_id": objectId}, bson.M{"$set": update})
+    if err != nil {
+        return fmt.Errorf("failed to update user: %v", err)
+    }
+
+    if result.MatchedCount == 0 {
+        return storage.NotFound("no user found with the given id")
+    }
 
     return nil
 }
 
-func (m *MongoDB) DeleteContestById(id string) error {
+func (m *MongoDB) DeleteUserById(ctx context.Context, id string) error {
     objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return fmt.Errorf("invalid contest id format")
+        return storage.InvalidID("user")
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    result, err := m.db.Collection("contests").DeleteOne(ctx, bson.M{"_id": objectId})
+    result, err := m.db.Collection("users").DeleteOne(ctx, bson.M{"_id": objectId})
     if err != nil {
         return err
     }
 
     if result.DeletedCount == 0 {
-        return fmt.Errorf("no contest found with the given id")
+        return storage.NotFound("no user found with the given id")
     }
 
     return nil
 }
 
-func (m *MongoDB) CreateQuestion(question types.Question) (string, error) {
+func (m *MongoDB) GetPublicProfile(ctx context.Context, id string) (*types.PublicProfile, error) {
+    user, err := m.GetUserById(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+
+    ctx, cancel := m.aggregateContext(ctx)
+    defer cancel()
+
+    // Collapse submissions to one row per question first so that repeated
+    // attempts count once towards solved and score.
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: bson.D{{Key: "user_id", Value: user.ID}}}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: bson.D{
+                {Key: "contest_id", Value: "$contest_id"},
+                {Key: "question_id", Value: "$question_id"},
+            }},
+            {Key: "attempts", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "best_score", Value: bson.D{{Key: "$max", Value: "$score"}}},
+            {Key: "accepted", Value: bson.D{{Key: "$max", Value: bson.D{
+                {Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$status", types.StatusAccepted}}}, 1, 0}},
+            }}}},
+        }}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$_id.contest_id"},
+            {Key: "submissions", Value: bson.D{{Key: "$sum", Value: "$attempts"}}},
+            {Key: "solved", Value: bson.D{{Key: "$sum", Value: "$accepted"}}},
+            {Key: "score", Value: bson.D{{Key: "$sum", Value: "$best_score"}}},
+        }}},
+        {{Key: "$lookup", Value: bson.D{
+            {Key: "from", Value: "contests"},
+            {Key: "localField", Value: "_id"},
+            {Key: "foreignField", Value: "_id"},
+            {Key: "as", Value: "contest"},
+        }}},
+        {{Key: "$unwind", Value: "$contest"}},
+        {{Key: "$match", Value: bson.D{{Key: "contest.deleted_at", Value: nil}}}},
+        {{Key: "$project", Value: bson.D{
+            {Key: "_id", Value: 1},
+            {Key: "title", Value: "$contest.title"},
+            {Key: "start_time", Value: "$contest.start_time"},
+            {Key: "submissions", Value: 1},
+            {Key: "solved", Value: 1},
+            {Key: "score", Value: 1},
+        }}},
+        {{Key: "$sort", Value: bson.D{{Key: "start_time", Value: -1}}}},
+    }
+
+    cursor, err := m.db.Collection("submissions").Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, fmt.Errorf("error executing aggregation: %v", err)
+    }
+    defer cursor.Close(ctx)
+
+    contests := []types.ContestParticipation{}
+    if err := cursor.All(ctx, &contests); err != nil {
+        return nil, fmt.Errorf("error decoding result: %v", err)
+    }
+
+    solved, err := m.db.Collection("submissions").Distinct(ctx, "question_id", bson.M{
+        "user_id": user.ID,
+        "status":  types.StatusAccepted,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &types.PublicProfile{
+        ID:          user.ID,
+        Name:        user.Name,
+        Avatar:      user.Avatar,
+        Bio:         user.Bio,
+        CreatedAt:   user.CreatedAt,
+        SolvedCount: len(solved),
+        Contests:    contests,
+    }, nil
+}
+
+func (m *MongoDB) CreateContest(ctx context.Context, contest types.Contest) (string, error) {
+    collection := m.db.Collection("contests")
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    // Store an empty list rather than null so questions can be $pushed
+    if contest.Problems == nil {
+        contest.Problems = []types.ContestProblem{}
+    }
+
+    result, err := collection.InsertOne(ctx, contest)
+    if err != nil {
+        return "", conflictOrErr(err, "contest")
+    }
+
+    return result.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (m *MongoDB) EditContestById(ctx context.Context, id string, updateData types.Contest) error {
+    contestObjID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return storage.InvalidID("contest")
+    }
+
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    contests := m.db.Collection("contests")
+
+    // Read, validate and write as one unit so a concurrent edit to the
+    // other end of the window can't slip in between
+    return m.withTransaction(ctx, func(ctx context.Context) error {
+        var contest types.Contest
+        err := contests.FindOne(ctx, bson.M{"_id": contestObjID, "deleted_at": nil}).Decode(&contest)
+        if err != nil {
+            if err == mongo.ErrNoDocuments {
+                return storage.NotFound("no contest found with the given id")
+            }
+            return fmt.Errorf("error checking contest existence: %v", err)
+        }
+
+        // Prepare the update
+        update := bson.M{}
+        if updateData.Title != "" {
+            update["title"] = updateData.Title
+        }
+        if !updateData.StartTime.IsZero() {
+            update["start_time"] = updateData.StartTime
+        }
+        if !updateData.EndTime.IsZero() {
+            update["end_time"] = updateData.EndTime
+        }
+        if updateData.Description != "" {
+            update["description"] = updateData.Description
+        }
+        if !updateData.CreatedBy.IsZero() {
+            update["created_by"] = updateData.CreatedBy
+        }
+        if updateData.MaxAttempts != nil {
+            update["max_attempts"] = *updateData.MaxAttempts
+        }
+
+        // Only one end of the window may be changing, so check the result
+        // against what is already stored.
+        startTime, endTime := contest.StartTime, contest.EndTime
+        if !updateData.StartTime.IsZero() {
+            startTime = updateData.StartTime
+        }
+        if !updateData.EndTime.IsZero() {
+            endTime = updateData.EndTime
+        }
+        if !endTime.After(startTime) {
+            return storage.Validation("end_time must be after start_time")
+        }
+
+        if len(update) > 0 {
+            // Matching on the window that was validated also catches a
+            // concurrent edit when running without transactions
+            filter := bson.M{"_id": contestObjID, "deleted_at": nil, "start_time": contest.StartTime, "end_time": contest.EndTime}
+            result, err := contests.UpdateOne(ctx, filter, bson.M{"$set": update})
+            if err != nil {
+                return fmt.Errorf("failed to update contest: %v", err)
+            }
+            if result.MatchedCount == 0 {
+                return storage.Conflict("contest was modified concurrently, try again")
+            }
+        }
+
+        return nil
+    })
+}
+
+func (m *MongoDB) DeleteContestById(ctx context.Context, id string, cascade bool) error {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return storage.InvalidID("contest")
+    }
+
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    d := newDeletion()
+    return m.withTransaction(ctx, func(ctx context.Context) error {
+        var contest types.Contest
+        err := m.db.Collection("contests").FindOneAndUpdate(ctx,
+            bson.M{"_id": objectId, "deleted_at": nil},
+            d.update(),
+        ).Decode(&contest)
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no contest found with the given id")
+        }
+        if err != nil {
+            return err
+        }
+
+        if !cascade {
+            return nil
+        }
+
+        // Questions other contests still use stay in the bank
+        ids := make([]primitive.ObjectID, 0, len(contest.Problems))
+        for _, problem := range contest.Problems {
+            ids = append(ids, problem.QuestionID)
+        }
+        usage, err := m.questionUsage(ctx, ids)
+        if err != nil {
+            return err
+        }
+        unused := []primitive.ObjectID{}
+        for _, id := range ids {
+            if usage[id] == 0 {
+                unused = append(unused, id)
+            }
+        }
+        return m.trashQuestions(ctx, unused, d, true)
+    })
+}
+
+func (m *MongoDB) CreateQuestion(ctx context.Context, question types.Question) (string, error) {
     collection := m.db.Collection("questions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    if err := validator.New().Struct(question); err != nil {
-        validateErrs := err.(validator.ValidationErrors)
-        return "", fmt.Errorf("validation failed: %v", validateErrs)
+    // Store an empty list rather than null so AddTestCaseToQuestion can $push
+    if question.TestCaseIDs == nil {
+        question.TestCaseIDs = []primitive.ObjectID{}
     }
+    question.StatementHTML = statement.Cached(question.Statement, nil)
 
     result, err := collection.InsertOne(ctx, question)
     if err != nil {
-        return "", err
+        return "", conflictOrErr(err, "question")
     }
 
     return result.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func (m *MongoDB) CreateTestCase(testCase types.TestCase) (string, error) {
-    collection := m.db.Collection("test_cases")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (m *MongoDB) ImportQuestion(ctx context.Context, question types.Question, testCases []types.TestCase) (string, error) {
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    if err := validator.New().Struct(testCase); err != nil {
-        validateErrs := err.(validator.ValidationErrors)
-        return "", fmt.Errorf("validation failed: %v", validateErrs)
+    question.ID = primitive.NewObjectID()
+    question.TestCaseIDs = []primitive.ObjectID{}
+    question.StatementHTML = statement.Cached(question.Statement, nil)
+    docs := []interface{}{}
+    for _, testCase := range testCases {
+        testCase.ID = primitive.NewObjectID()
+        question.TestCaseIDs = append(question.TestCaseIDs, testCase.ID)
+        docs = append(docs, testCase)
+    }
+
+    err := m.withTransaction(ctx, func(ctx context.Context) error {
+        if len(docs) > 0 {
+            if _, err := m.db.Collection("test_cases").InsertMany(ctx, docs); err != nil {
+                return conflictOrErr(err, "test case")
+            }
+        }
+        if _, err := m.db.Collection("questions").InsertOne(ctx, question); err != nil {
+            if !m.transactions && len(docs) > 0 {
+                m.db.Collection("test_cases").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": question.TestCaseIDs}})
+            }
+            return conflictOrErr(err, "question")
+        }
+        return nil
+    })
+    if err != nil {
+        return "", err
     }
 
+    return question.ID.Hex(), nil
+}
+
+func (m *MongoDB) CreateTestCase(ctx context.Context, testCase types.TestCase) (string, error) {
+    collection := m.db.Collection("test_cases")
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
     result, err := collection.InsertOne(ctx, testCase)
     if err != nil {
-        return "", err
+        return "", conflictOrErr(err, "test case")
     }
 
     return result.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func (m *MongoDB) GetAllContests() ([]types.ContestBasicInfo, error) {
+func (m *MongoDB) GetTestCaseById(ctx context.Context, id string) (*types.TestCase, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return nil, storage.InvalidID("test case")
+    }
+
+    ctx, cancel := m.readContext(ctx)
+    defer cancel()
+
+    var testCase types.TestCase
+    err = m.db.Collection("test_cases").FindOne(ctx, bson.M{"_id": objectId, "deleted_at": nil}).Decode(&testCase)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, storage.NotFound("no test case found with the given id")
+        }
+        return nil, err
+    }
+    return &testCase, nil
+}
+
+func (m *MongoDB) GetTestCaseQuestionId(ctx context.Context, testCaseId string) (string, error) {
+    objectId, err := primitive.ObjectIDFromHex(testCaseId)
+    if err != nil {
+        return "", storage.InvalidID("test case")
+    }
+
+    ctx, cancel := m.readContext(ctx)
+    defer cancel()
+
+    var question types.Question
+    opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+    err = m.db.Collection("questions").FindOne(ctx, bson.M{"test_case_ids": objectId, "deleted_at": nil}, opts).Decode(&question)
+    if err == mongo.ErrNoDocuments {
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("error finding the test case's question: %v", err)
+    }
+    return question.ID.Hex(), nil
+}
+
+func (m *MongoDB) GetAllContests(ctx context.Context) ([]types.ContestBasicInfo, error) {
     collection := m.db.Collection("contests")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.readContext(ctx)
     defer cancel()
 
     projection := bson.D{
@@ -235,8 +661,8 @@ func (m *MongoDB) GetAllContests() ([]types.ContestBasicInfo, error) {
         {Key: "description", Value: 1},
     }
 
-    var contests []types.ContestBasicInfo
-    cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+    contests := []types.ContestBasicInfo{}
+    cursor, err := collection.Find(ctx, bson.M{"deleted_at": nil}, options.Find().SetProjection(projection))
     if err != nil {
         return nil, err
     }
@@ -249,30 +675,21 @@ func (m *MongoDB) GetAllContests() ([]types.ContestBasicInfo, error) {
     return contests, nil
 }
 
-func (m *MongoDB) GetContestById(id string) ([]bson.M, error) {
+func (m *MongoDB) GetContestById(ctx context.Context, id string) (*types.ContestDetail, error) {
     objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return nil, fmt.Errorf("invalid contest id format")
+        return nil, storage.InvalidID("contest")
     }
 
+    // $lookup returns questions in collection order, so the contest's own
+    // order is restored from its problems list below
     pipeline := mongo.Pipeline{
-        {{Key: "$match", Value: bson.D{{Key: "_id", Value: objectId}}}},
-        {{Key: "$addFields", Value: bson.D{
-            {Key: "question_ids", Value: bson.D{
-                {Key: "$map", Value: bson.D{
-                    {Key: "input", Value: "$question_ids"},
-                    {Key: "as", Value: "qid"},
-                    {Key: "in", Value: bson.D{
-                        {Key: "$toObjectId", Value: "$$qid"},
-                    }},
-                }},
-            }},
-        }}},
+        {{Key: "$match", Value: bson.D{{Key: "_id", Value: objectId}, {Key: "deleted_at", Value: nil}}}},
         {{Key: "$lookup", Value: bson.D{
             {Key: "from", Value: "questions"},
-            {Key: "localField", Value: "question_ids"},
+            {Key: "localField", Value: "problems.question_id"},
             {Key: "foreignField", Value: "_id"},
-            {Key: "as", Value: "questions"},
+            {Key: "as", Value: "found"},
         }}},
         {{Key: "$project", Value: bson.D{
             {Key: "_id", Value: "$_id"},
@@ -280,62 +697,72 @@ func (m *MongoDB) GetContestById(id string) ([]bson.M, error) {
             {Key: "start_time", Value: "$start_time"},
             {Key: "end_time", Value: "$end_time"},
             {Key: "description", Value: "$description"},
-            {Key: "questions", Value: bson.D{
+            {Key: "max_attempts", Value: "$max_attempts"},
+            {Key: "problems", Value: "$problems"},
+            {Key: "found", Value: bson.D{
                 {Key: "$map", Value: bson.D{
-                    {Key: "input", Value: "$questions"},
+                    {Key: "input", Value: notDeleted("$found")},
                     {Key: "as", Value: "q"},
                     {Key: "in", Value: bson.D{
                         {Key: "_id", Value: "$$q._id"},
                         {Key: "title", Value: "$$q.title"},
                         {Key: "description", Value: "$$q.description"},
                         {Key: "difficulty", Value: "$$q.difficulty"},
+                        {Key: "points", Value: "$$q.points"},
                     }},
                 }},
             }},
         }}},
     }
 
-    var results []bson.M
-    cursor, err := m.db.Collection("contests").Aggregate(context.Background(), pipeline)
+    var results []struct {
+        types.ContestDetail `bson:",inline"`
+        Problems            []types.ContestProblem `bson:"problems"`
+        Found               []types.Question       `bson:"found"`
+    }
+    ctx, cancel := m.aggregateContext(ctx)
+    defer cancel()
+
+    cursor, err := m.db.Collection("contests").Aggregate(ctx, pipeline)
     if err != nil {
         return nil, fmt.Errorf("error executing aggregation: %v", err)
     }
-    defer cursor.Close(context.Background())
+    defer cursor.Close(ctx)
 
-    if err := cursor.All(context.Background(), &results); err != nil {
+    if err := cursor.All(ctx, &results); err != nil {
         return nil, fmt.Errorf("error decoding result: %v", err)
     }
 
     if len(results) == 0 {
-        return nil, mongo.ErrNoDocuments
+        return nil, storage.NotFound("no contest found with the given id")
+    }
+
+    result := results[0]
+    found := make(map[primitive.ObjectID]types.Question, len(result.Found))
+    for _, question := range result.Found {
+        found[question.ID] = question
     }
+    detail := result.ContestDetail
+    detail.Questions = storage.ProblemSummaries(result.Problems, func(id primitive.ObjectID) (types.Question, bool) {
+        question, ok := found[id]
+        return question, ok
+    })
 
-    return results, nil
+    return &detail, nil
 }
 
-func (m *MongoDB) GetQuestionById(id string) ([]bson.M, error) {
+func (m *MongoDB) GetQuestionById(ctx context.Context, id string) (*types.QuestionDetail, error) {
     collection := m.db.Collection("questions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.aggregateContext(ctx)
     defer cancel()
 
     objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return nil, fmt.Errorf("invalid question id format")
+        return nil, storage.InvalidID("question")
     }
 
     pipeline := mongo.Pipeline{
-        {{Key: "$match", Value: bson.D{{Key: "_id", Value: objectId}}}},
-        {{Key: "$addFields", Value: bson.D{
-            {Key: "test_case_ids", Value: bson.D{
-                {Key: "$map", Value: bson.D{
-                    {Key: "input", Value: "$test_case_ids"},
-                    {Key: "as", Value: "tid"},
-                    {Key: "in", Value: bson.D{
-                        {Key: "$toObjectId", Value: "$$tid"},
-                    }},
-                }},
-            }},
-        }}},
+        {{Key: "$match", Value: bson.D{{Key: "_id", Value: objectId}, {Key: "deleted_at", Value: nil}}}},
         {{Key: "$lookup", Value: bson.D{
             {Key: "from", Value: "test_cases"},
             {Key: "localField", Value: "test_case_ids"},
@@ -346,25 +773,35 @@ func (m *MongoDB) GetQuestionById(id string) ([]bson.M, error) {
             {Key: "_id", Value: 1},
             {Key: "title", Value: 1},
             {Key: "description", Value: 1},
+            {Key: "statement", Value: 1},
+            {Key: "statement_html", Value: 1},
             {Key: "difficulty", Value: 1},
             {Key: "tags", Value: 1},
             {Key: "points", Value: 1},
+            {Key: "cpu_time_limit", Value: 1},
+            {Key: "memory_limit", Value: 1},
+            {Key: "checker", Value: 1},
+            {Key: "max_attempts", Value: 1},
             {Key: "test_cases", Value: bson.D{
                 {Key: "$map", Value: bson.D{
-                    {Key: "input", Value: "$test_cases"},
+                    {Key: "input", Value: notDeleted("$test_cases")},
                     {Key: "as", Value: "tc"},
                     {Key: "in", Value: bson.D{
                         {Key: "_id", Value: "$$tc._id"},
                         {Key: "input", Value: "$$tc.input"},
                         {Key: "expected_output", Value: "$$tc.expected_output"},
+                        {Key: "input_blob", Value: "$$tc.input_blob"},
+                        {Key: "expected_output_blob", Value: "$$tc.expected_output_blob"},
                         {Key: "visibility", Value: "$$tc.visibility"},
+                        {Key: "subtask", Value: "$$tc.subtask"},
+                        {Key: "generated", Value: "$$tc.generated"},
                     }},
                 }},
             }},
         }}},
     }
 
-    var results []bson.M
+    var results []types.QuestionDetail
     cursor, err := collection.Aggregate(ctx, pipeline)
     if err != nil {
         return nil, fmt.Errorf("error executing aggregation: %v", err)
@@ -376,28 +813,22 @@ func (m *MongoDB) GetQuestionById(id string) ([]bson.M, error) {
     }
 
     if len(results) == 0 {
-        return nil, mongo.ErrNoDocuments
+        return nil, storage.NotFound("no question found with the given id")
     }
 
-    return results, nil
+    question := &results[0]
+    question.StatementHTML = statement.Cached(question.Statement, question.StatementHTML)
+    return question, nil
 }
 
-func (m*MongoDB)  EditQuestionById(id string, updateData types.Question) error {
+func (m *MongoDB) EditQuestionById(ctx context.Context, id string, updateData types.Question, author string) error {
     questionObjID, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return fmt.Errorf("invalid question id format")
+        return storage.InvalidID("question")
     }
-
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
-
-    var question types.Question
-    err = m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID}).Decode(&question)
+    authorID, err := parseAuthor(author)
     if err != nil {
-        if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("no question found with the given id")
-        }
-        return fmt.Errorf("error checking question existence: %v", err)
+        return err
     }
 
     // Prepare the update
@@ -408,6 +839,10 @@ func (m*MongoDB)  EditQuestionById(id string, updateData types.Question) error {
     if updateData.Description != "" {
         update["description"] = updateData.Description
     }
+    if updateData.Statement != nil {
+        update["statement"] = updateData.Statement
+        update["statement_html"] = statement.Cached(updateData.Statement, nil)
+    }
     if updateData.Difficulty != "" {
         update["difficulty"] = updateData.Difficulty
     }
@@ -423,255 +858,484 @@ func (m*MongoDB)  EditQuestionById(id string, updateData types.Question) error {
     if updateData.Memory_limit != 0 {
         update["memory_limit"] = updateData.Memory_limit
     }
-    if len(update) > 0 {
-        _, err = m.db.Collection("questions").UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$set": update})
-        if err != nil {
-            return fmt.Errorf("failed to update question: %v", err)
-        }
+    if updateData.MaxAttempts != nil {
+        update["max_attempts"] = *updateData.MaxAttempts
     }
 
-    return nil
-}
-
-func (m *MongoDB) AddQuestionToContest(contestId string, question types.Question) (string, error) {
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    fmt.Printf("Received contest ID: %s\n", contestId)
+    return m.withTransaction(ctx, func(ctx context.Context) error {
+        var question types.Question
+        err := m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Decode(&question)
+        if err != nil {
+            if err == mongo.ErrNoDocuments {
+                return storage.NotFound("no question found with the given id")
+            }
+            return fmt.Errorf("error checking question existence: %v", err)
+        }
 
-    question.TestCaseIDs = []string{}
+        before := storage.QuestionSnapshot(question)
+        rev := types.Revision{QuestionID: questionObjID, Action: types.RevisionEdit, Author: authorID}
+        return m.revise(ctx, rev, before, storage.Overlay(before, update), storage.QuestionOriginal(question), func(ctx context.Context) error {
+            _, err := m.db.Collection("questions").UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$set": update})
+            if err != nil {
+                return fmt.Errorf("failed to update question: %v", err)
+            }
+            return nil
+        })
+    })
+}
 
-    //Create question
-    questionResult, err := m.db.Collection("questions").InsertOne(ctx, question)
+func (m *MongoDB) AddQuestionToContest(ctx context.Context, contestId string, question types.Question) (string, error) {
+    contestObjID, err := primitive.ObjectIDFromHex(contestId)
     if err != nil {
-        return "", fmt.Errorf("failed to create question: %v", err)
+        return "", storage.InvalidID("contest")
     }
-    questionId := questionResult.InsertedID.(primitive.ObjectID).Hex()
 
-    fmt.Printf("Created question with ID: %s\n", questionId)
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
 
-    contestObjID, err := primitive.ObjectIDFromHex(contestId)
-    if err != nil {
-        return "", fmt.Errorf("invalid contest id format: %v", err)
-    }
+    question.ID = primitive.NewObjectID()
+    question.TestCaseIDs = []primitive.ObjectID{}
+    question.StatementHTML = statement.Cached(question.Statement, nil)
 
-    filter := bson.M{"_id": contestObjID}
-    update := bson.M{"$push": bson.M{"question_ids": questionId}}
-    
-    result, err := m.db.Collection("contests").UpdateOne(ctx, filter, update)
+    questions := m.db.Collection("questions")
+    contests := m.db.Collection("contests")
+
+    err = m.withTransaction(ctx, func(ctx context.Context) error {
+        // Check the contest before inserting so a bad id leaves nothing behind
+        var contest types.Contest
+        err := contests.FindOne(ctx, bson.M{"_id": contestObjID, "deleted_at": nil}).Decode(&contest)
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no contest found with the given id")
+        }
+        if err != nil {
+            return fmt.Errorf("error checking contest existence: %v", err)
+        }
+        // The new question takes the next letter, which a custom label may hold
+        if err := storage.CheckProblemLabels(append(contest.Problems, types.ContestProblem{QuestionID: question.ID})); err != nil {
+            return err
+        }
+
+        if _, err := questions.InsertOne(ctx, question); err != nil {
+            return conflictOrErr(err, "question")
+        }
+
+        result, err := contests.UpdateOne(ctx, bson.M{"_id": contestObjID}, bson.M{"$push": bson.M{"problems": types.ContestProblem{QuestionID: question.ID}}})
+        if err == nil && result.MatchedCount == 0 {
+            err = storage.NotFound("no contest found with the given id")
+        }
+        if err != nil {
+            if !m.transactions {
+                questions.DeleteOne(ctx, bson.M{"_id": question.ID})
+            }
+            return err
+        }
+        return nil
+    })
     if err != nil {
-        return "", fmt.Errorf("failed to update contest: %v", err)
+        return "", err
     }
 
-    fmt.Printf("Updated contest. Modified count: %d\n", result.ModifiedCount)
-
-    return questionId, nil
+    return question.ID.Hex(), nil
 }
 
-func (m *MongoDB) DeleteQuestionFromContestById(contestId string, questionId string) error {
+// DeleteQuestionFromContestById removes a question from a contest. The
+// question itself stays in the bank.
+func (m *MongoDB) DeleteQuestionFromContestById(ctx context.Context, contestId string, questionId string) error {
     contestObjID, err := primitive.ObjectIDFromHex(contestId)
     if err != nil {
-        return fmt.Errorf("invalid contest id format")
+        return storage.InvalidID("contest")
+    }
+    questionObjID, err := primitive.ObjectIDFromHex(questionId)
+    if err != nil {
+        return storage.InvalidID("question")
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    // Check if contest exists
-    var contest types.Contest
-    err = m.db.Collection("contests").FindOne(ctx, bson.M{"_id": contestObjID}).Decode(&contest)
+    contests := m.db.Collection("contests")
+    result, err := contests.UpdateOne(ctx,
+        bson.M{"_id": contestObjID, "deleted_at": nil, "problems.question_id": questionObjID},
+        bson.M{"$pull": bson.M{"problems": bson.M{"question_id": questionObjID}}},
+    )
     if err != nil {
-        if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("no contest found with the given id")
-        }
-        return fmt.Errorf("error checking contest existence: %v", err)
+        return fmt.Errorf("failed to remove question from contest: %v", err)
+    }
+    if result.MatchedCount > 0 {
+        return nil
     }
 
-    found := false
-    for _, qID := range contest.QuestionIDs {
-        if qID == questionId {
-            found = true
-            break
-        }
+    err = contests.FindOne(ctx, bson.M{"_id": contestObjID, "deleted_at": nil}).Err()
+    if err == mongo.ErrNoDocuments {
+        return storage.NotFound("no contest found with the given id")
     }
-    if !found {
-        return fmt.Errorf("no question found with the given id in the contest")
+    if err != nil {
+        return fmt.Errorf("error checking contest existence: %v", err)
     }
+    return storage.NotFound("no question found with the given id in the contest")
+}
 
-    filter := bson.M{"_id": contestObjID}
-    update := bson.M{"$pull": bson.M{"question_ids": questionId}}
-    
-    result, err := m.db.Collection("contests").UpdateOne(ctx, filter, update)
+func (m *MongoDB) AddTestCaseToQuestion(ctx context.Context, questionId string, testCase types.TestCase, author string) (string, error) {
+    questionObjID, err := primitive.ObjectIDFromHex(questionId)
     if err != nil {
-        return fmt.Errorf("failed to update contest: %v", err)
+        return "", storage.InvalidID("question")
     }
-
-    if result.ModifiedCount == 0 {
-        return fmt.Errorf("no question found with the given id in the contest")
+    authorID, err := parseAuthor(author)
+    if err != nil {
+        return "", err
     }
 
-    return nil
-}
-
-func (m *MongoDB) AddTestCaseToQuestion(questionId string, testCase types.TestCase) (string, error) {
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    fmt.Printf("Received question ID: %s\n", questionId)
+    testCase.ID = primitive.NewObjectID()
 
-    // Create test case
-    testCaseResult, err := m.db.Collection("test_cases").InsertOne(ctx, testCase)
+    testCases := m.db.Collection("test_cases")
+    questions := m.db.Collection("questions")
+
+    err = m.withTransaction(ctx, func(ctx context.Context) error {
+        // Check the question before inserting so a bad id leaves nothing behind
+        err := questions.FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Err()
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no question found with the given id")
+        }
+        if err != nil {
+            return fmt.Errorf("error checking question existence: %v", err)
+        }
+
+        return m.reviseTestCase(ctx, questionObjID, testCase.ID, types.RevisionAdd, authorID, bson.M{}, storage.TestCaseSnapshot(testCase), func(ctx context.Context) error {
+            if _, err := testCases.InsertOne(ctx, testCase); err != nil {
+                return conflictOrErr(err, "test case")
+            }
+
+            result, err := questions.UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$push": bson.M{"test_case_ids": testCase.ID}})
+            if err == nil && result.MatchedCount == 0 {
+                err = storage.NotFound("no question found with the given id")
+            }
+            if err != nil {
+                if !m.transactions {
+                    testCases.DeleteOne(ctx, bson.M{"_id": testCase.ID})
+                }
+                return err
+            }
+            return nil
+        })
+    })
     if err != nil {
-        return "", fmt.Errorf("failed to create test case: %v", err)
+        return "", err
     }
-    testCaseId := testCaseResult.InsertedID.(primitive.ObjectID).Hex()
 
-    fmt.Printf("Created test case with ID: %s\n", testCaseId)
+    return testCase.ID.Hex(), nil
+}
 
-    // Convert question ID to ObjectID
+func (m *MongoDB) AddTestCasesToQuestion(ctx context.Context, questionId string, testCases []types.TestCase, replace bool, author string) ([]string, error) {
     questionObjID, err := primitive.ObjectIDFromHex(questionId)
     if err != nil {
-        return "", fmt.Errorf("invalid question id format: %v", err)
+        return nil, storage.InvalidID("question")
     }
-
-    // Update question with test case ID
-    filter := bson.M{"_id": questionObjID}
-    update := bson.M{"$push": bson.M{"test_case_ids": testCaseId}}
-    
-    result, err := m.db.Collection("questions").UpdateOne(ctx, filter, update)
+    authorID, err := parseAuthor(author)
     if err != nil {
-        return "", fmt.Errorf("failed to update question: %v", err)
+        return nil, err
     }
 
-    fmt.Printf("Updated question. Modified count: %d\n", result.ModifiedCount)
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
 
-    return testCaseId, nil
-}
+    ids := []primitive.ObjectID{}
+    docs := []interface{}{}
+    for _, testCase := range testCases {
+        testCase.ID = primitive.NewObjectID()
+        ids = append(ids, testCase.ID)
+        docs = append(docs, testCase)
+    }
 
-func (m *MongoDB) EditTestCaseById(id string, updateData types.TestCase) error {
-    testCaseObjID, err := primitive.ObjectIDFromHex(id)
+    testCasesColl := m.db.Collection("test_cases")
+    questions := m.db.Collection("questions")
+
+    err = m.withTransaction(ctx, func(ctx context.Context) error {
+        var question types.Question
+        err := questions.FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Decode(&question)
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no question found with the given id")
+        }
+        if err != nil {
+            return fmt.Errorf("error checking question existence: %v", err)
+        }
+        if len(docs) == 0 && !replace {
+            return nil
+        }
+
+        if len(docs) > 0 {
+            if _, err := testCasesColl.InsertMany(ctx, docs); err != nil {
+                return conflictOrErr(err, "test case")
+            }
+        }
+        // Without transactions, undo the inserts and the listing if a later
+        // step fails
+        undo := func() {
+            if !m.transactions && len(docs) > 0 {
+                questions.UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$pullAll": bson.M{"test_case_ids": ids}})
+                testCasesColl.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+            }
+        }
+
+        result, err := questions.UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$push": bson.M{"test_case_ids": bson.M{"$each": ids}}})
+        if err == nil && result.MatchedCount == 0 {
+            err = storage.NotFound("no question found with the given id")
+        }
+        if err != nil {
+            undo()
+            return err
+        }
+
+        // The old test cases stay listed, so any of them restored from the
+        // trash goes back in the question
+        var removed []types.TestCase
+        if replace {
+            err := m.findAll(ctx, "test_cases", bson.M{"_id": bson.M{"$in": question.TestCaseIDs}, "deleted_at": nil}, nil, &removed)
+            if err == nil {
+                err = m.trashTestCases(ctx, question.TestCaseIDs, newDeletion())
+            }
+            if err != nil {
+                undo()
+                return err
+            }
+        }
+
+        recorded := func(ctx context.Context) error { return nil }
+        for _, testCase := range removed {
+            if err := m.reviseTestCase(ctx, questionObjID, testCase.ID, types.RevisionRemove, authorID, storage.TestCaseSnapshot(testCase), bson.M{}, recorded); err != nil {
+                undo()
+                return err
+            }
+        }
+        for i, testCase := range testCases {
+            if err := m.reviseTestCase(ctx, questionObjID, ids[i], types.RevisionAdd, authorID, bson.M{}, storage.TestCaseSnapshot(testCase), recorded); err != nil {
+                undo()
+                return err
+            }
+        }
+        return nil
+    })
     if err != nil {
-        return fmt.Errorf("invalid test case id format")
+        return nil, err
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
+    hexIds := make([]string, 0, len(ids))
+    for _, id := range ids {
+        hexIds = append(hexIds, id.Hex())
+    }
+    return hexIds, nil
+}
 
-    var testCase types.TestCase
-    err = m.db.Collection("test_cases").FindOne(ctx, bson.M{"_id": testCaseObjID}).Decode(&testCase)
+func (m *MongoDB) EditTestCaseById(ctx context.Context, id string, updateData types.TestCase, author string) error {
+    testCaseObjID, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("no test case found with the given id")
-        }
-        return fmt.Errorf("error checking test case existence: %v", err)
+        return storage.InvalidID("test case")
+    }
+    authorID, err := parseAuthor(author)
+    if err != nil {
+        return err
     }
 
     // Prepare the update
     update := bson.M{}
-    if updateData.Input != nil {
+    // New data replaces the old whether it was inline or a blob
+    if updateData.Input != nil || updateData.InputBlob != nil {
         update["input"] = updateData.Input
+        update["input_blob"] = updateData.InputBlob
+        update["generated"] = nil
     }
-    if updateData.ExpectedOutput != nil {
+    if updateData.ExpectedOutput != nil || updateData.ExpectedOutputBlob != nil {
         update["expected_output"] = updateData.ExpectedOutput
+        update["expected_output_blob"] = updateData.ExpectedOutputBlob
+        update["generated"] = nil
     }
     if updateData.Visibility != "" {
         update["visibility"] = updateData.Visibility
     }
-    if len(update) > 0 {
-        _, err = m.db.Collection("test_cases").UpdateOne(ctx, bson.M{"_id": testCaseObjID}, bson.M{"$set": update})
+    if updateData.Subtask != 0 {
+        update["subtask"] = updateData.Subtask
+    }
+
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    return m.withTransaction(ctx, func(ctx context.Context) error {
+        var testCase types.TestCase
+        err := m.db.Collection("test_cases").FindOne(ctx, bson.M{"_id": testCaseObjID, "deleted_at": nil}).Decode(&testCase)
         if err != nil {
-            return fmt.Errorf("failed to update test case: %v", err)
+            if err == mongo.ErrNoDocuments {
+                return storage.NotFound("no test case found with the given id")
+            }
+            return fmt.Errorf("error checking test case existence: %v", err)
         }
-    }
 
-    return nil
+        apply := func(ctx context.Context) error {
+            if len(update) == 0 {
+                return nil
+            }
+            _, err := m.db.Collection("test_cases").UpdateOne(ctx, bson.M{"_id": testCaseObjID}, bson.M{"$set": update})
+            if err != nil {
+                return fmt.Errorf("failed to update test case: %v", err)
+            }
+            return nil
+        }
+
+        // Revisions belong to the question listing the test case; one no
+        // question lists isn't tracked
+        var question types.Question
+        err = m.db.Collection("questions").FindOne(ctx, bson.M{"test_case_ids": testCaseObjID}).Decode(&question)
+        if err == mongo.ErrNoDocuments {
+            return apply(ctx)
+        }
+        if err != nil {
+            return fmt.Errorf("error finding the test case's question: %v", err)
+        }
+
+        before := storage.TestCaseSnapshot(testCase)
+        rev := types.Revision{QuestionID: question.ID, TestCaseID: &testCaseObjID, Action: types.RevisionEdit, Author: authorID}
+        return m.revise(ctx, rev, before, storage.Overlay(before, update), types.Revision{CreatedAt: testCase.CreatedAt}, apply)
+    })
 }
 
-func (m *MongoDB) DeleteTestCaseFromQuestionById(questionId string, testCaseId string) error {
+func (m *MongoDB) DeleteTestCaseFromQuestionById(ctx context.Context, questionId string, testCaseId string, author string) error {
     questionObjID, err := primitive.ObjectIDFromHex(questionId)
     if err != nil {
-        return fmt.Errorf("invalid question id format")
+        return storage.InvalidID("question")
+    }
+    testCaseObjID, err := primitive.ObjectIDFromHex(testCaseId)
+    if err != nil {
+        return storage.InvalidID("test case")
+    }
+    authorID, err := parseAuthor(author)
+    if err != nil {
+        return err
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
-    // Check if question exists
-    var question types.Question
-    err = m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID}).Decode(&question)
-    if err != nil {
+    return m.withTransaction(ctx, func(ctx context.Context) error {
+        // Check if question exists and lists the test case
+        err := m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Err()
         if err == mongo.ErrNoDocuments {
-            return fmt.Errorf("no question found with the given id")
+            return storage.NotFound("no question found with the given id")
         }
-        return fmt.Errorf("error checking question existence: %v", err)
-    }
-
-    found := false
-    for _, tcID := range question.TestCaseIDs {
-        if tcID == testCaseId {
-            found = true
-            break
+        if err != nil {
+            return fmt.Errorf("error checking question existence: %v", err)
+        }
+        err = m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "test_case_ids": testCaseObjID}).Err()
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no test case found with the given id in the question")
+        }
+        if err != nil {
+            return fmt.Errorf("error checking question existence: %v", err)
         }
-    }
-    if !found {
-        return fmt.Errorf("no test case found with the given id in the question")
-    }
-
-    filter := bson.M{"_id": questionObjID}
-    update := bson.M{"$pull": bson.M{"test_case_ids": testCaseId}}
-    
-    result, err := m.db.Collection("questions").UpdateOne(ctx, filter, update)
-    if err != nil {
-        return fmt.Errorf("failed to update question: %v", err)
-    }
 
-    if result.ModifiedCount == 0 {
-        return fmt.Errorf("no test case found with the given id in the question")
-    }
+        var testCase types.TestCase
+        err = m.db.Collection("test_cases").FindOne(ctx, bson.M{"_id": testCaseObjID, "deleted_at": nil}).Decode(&testCase)
+        if err == mongo.ErrNoDocuments {
+            return storage.NotFound("no test case found with the given id in the question")
+        }
+        if err != nil {
+            return fmt.Errorf("error checking test case existence: %v", err)
+        }
 
-    return nil
+        // The test case stays listed so restoring it puts it back in the question
+        return m.reviseTestCase(ctx, questionObjID, testCaseObjID, types.RevisionRemove, authorID, storage.TestCaseSnapshot(testCase), bson.M{}, func(ctx context.Context) error {
+            result, err := m.db.Collection("test_cases").UpdateOne(ctx,
+                bson.M{"_id": testCaseObjID, "deleted_at": nil},
+                newDeletion().update(),
+            )
+            if err != nil {
+                return fmt.Errorf("failed to delete test case: %v", err)
+            }
+            if result.MatchedCount == 0 {
+                return storage.NotFound("no test case found with the given id in the question")
+            }
+            return nil
+        })
+    })
 }
 
-func (m *MongoDB) CreateSubmission(submission types.Submission) (string, error) {
+func (m *MongoDB) CreateSubmission(ctx context.Context, submission types.Submission) (string, error) {
     collection := m.db.Collection("submissions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
     result, err := collection.InsertOne(ctx, submission)
     if err != nil {
-        return "", err
+        return "", conflictOrErr(err, "submission")
     }
 
     return result.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func (m *MongoDB) GetSubmissionById(id string) (*types.Submission, error) {
+func (m *MongoDB) GetSubmissionById(ctx context.Context, id string) (*types.Submission, error) {
     objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return nil, fmt.Errorf("invalid submission id format")
+        return nil, storage.InvalidID("submission")
     }
 
     collection := m.db.Collection("submissions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.readContext(ctx)
     defer cancel()
 
     var submission types.Submission
     err = collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&submission)
     if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, storage.NotFound("no submission found with the given id")
+        }
         return nil, err
     }
 
     return &submission, nil
 }
 
-func (m *MongoDB) UpdateSubmissionStatus(id string, status string, score int) error {
+func (m *MongoDB) CountSubmissions(ctx context.Context, userId, contestId, questionId string) (int, error) {
+    userObjID, err := primitive.ObjectIDFromHex(userId)
+    if err != nil {
+        return 0, storage.InvalidID("user")
+    }
+    questionObjID, err := primitive.ObjectIDFromHex(questionId)
+    if err != nil {
+        return 0, storage.InvalidID("question")
+    }
+
+    // Served by the contest_user_question index, or by user_question
+    // across contests
+    filter := bson.M{"user_id": userObjID, "question_id": questionObjID}
+    if contestId != "" {
+        contestObjID, err := primitive.ObjectIDFromHex(contestId)
+        if err != nil {
+            return 0, storage.InvalidID("contest")
+        }
+        filter["contest_id"] = contestObjID
+    }
+
+    ctx, cancel := m.readContext(ctx)
+    defer cancel()
+
+    count, err := m.db.Collection("submissions").CountDocuments(ctx, filter)
+    if err != nil {
+        return 0, fmt.Errorf("error counting submissions: %v", err)
+    }
+    return int(count), nil
+}
+
+func (m *MongoDB) UpdateSubmissionStatus(ctx context.Context, id string, status string, score int) error {
     objectId, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return fmt.Errorf("invalid submission id format")
+        return storage.InvalidID("submission")
     }
 
     collection := m.db.Collection("submissions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := m.writeContext(ctx)
     defer cancel()
 
     update := bson.M{
@@ -681,6 +1345,27 @@ func (m *MongoDB) UpdateSubmissionStatus(id string, status string, score int) er
         },
     }
 
-    _, err = collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+    if err != nil {
+        return err
+    }
+
+    if result.MatchedCount == 0 {
+        return storage.NotFound("no submission found with the given id")
+    }
+
+    return nil
+}
+
+func (m *MongoDB) CreateAuditEntry(ctx context.Context, entry types.AuditEntry) error {
+    collection := m.db.Collection("audit_log")
+    ctx, cancel := m.writeContext(ctx)
+    defer cancel()
+
+    if entry.CreatedAt.IsZero() {
+        entry.CreatedAt = time.Now()
+    }
+
+    _, err := collection.InsertOne(ctx, entry)
     return err
-}
\ No newline at end of file
+}
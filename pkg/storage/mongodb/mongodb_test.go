@@ -0,0 +1,340 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/blob/blobtest"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/config"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage/storagetest"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestConformance needs a running MongoDB, e.g.
+//
+//	MONGODB_TEST_URL=mongodb://localhost:27017 go test ./pkg/storage/mongodb
+//
+// Each subtest gets its own database, dropped afterwards.
+func TestConformance(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newTestDB(t, url)
+	})
+}
+
+func TestObjectIDsMigration(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	m := newTestDB(t, url)
+	ctx := context.Background()
+
+	// Documents as they were stored when references were hex strings
+	questionId := primitive.NewObjectID()
+	testCaseId := primitive.NewObjectID()
+	contestId := primitive.NewObjectID()
+	start := time.Now().Add(time.Hour)
+	mustInsert := func(collection string, doc bson.M) {
+		t.Helper()
+		if _, err := m.db.Collection(collection).InsertOne(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustInsert("test_cases", bson.M{"_id": testCaseId, "input": "1", "expected_output": "1", "visibility": "public"})
+	mustInsert("questions", bson.M{"_id": questionId, "title": "Echo", "test_case_ids": bson.A{testCaseId.Hex()}})
+	mustInsert("contests", bson.M{
+		"_id":          contestId,
+		"title":        "Legacy",
+		"start_time":   start,
+		"end_time":     start.Add(time.Hour),
+		"created_by":   "someone",
+		"question_ids": bson.A{questionId.Hex(), "not-an-id"},
+	})
+
+	if err := m.CheckSchema(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("CheckSchema before migrating = %v, want ErrSchemaOutdated", err)
+	}
+	if _, err := m.MigrateUp(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	contest, err := m.GetContestById(ctx, contestId.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contest.Questions) != 1 || contest.Questions[0].ID != questionId {
+		t.Errorf("questions = %+v", contest.Questions)
+	}
+	question, err := m.GetQuestionById(ctx, questionId.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(question.TestCases) != 1 || question.TestCases[0].ID != testCaseId {
+		t.Errorf("test cases = %+v", question.TestCases)
+	}
+
+	done, err := m.MigrateUp(ctx, 1)
+	if err != nil || len(done) != 0 {
+		t.Errorf("second MigrateUp applied %d migrations, err %v", len(done), err)
+	}
+
+	if _, err := m.MigrateDown(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	var legacy struct {
+		QuestionIDs []string `bson:"question_ids"`
+	}
+	if err := m.db.Collection("contests").FindOne(ctx, bson.M{"_id": contestId}).Decode(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	if len(legacy.QuestionIDs) != 1 || legacy.QuestionIDs[0] != questionId.Hex() {
+		t.Errorf("question_ids after down = %v", legacy.QuestionIDs)
+	}
+}
+
+func TestMigrationLock(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	m := newTestDB(t, url)
+	ctx := context.Background()
+
+	err := m.withMigrationLock(ctx, func() error {
+		_, err := m.MigrateUp(ctx, 0)
+		return err
+	})
+	if !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("nested migration = %v, want ErrMigrationLocked", err)
+	}
+
+	if _, err := m.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("lock was not released: %v", err)
+	}
+	if err := m.CheckSchema(ctx); err != nil {
+		t.Errorf("CheckSchema after migrating: %v", err)
+	}
+}
+
+func TestBaselineFresh(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	ctx := context.Background()
+
+	fresh := newTestDB(t, url)
+	if err := fresh.CheckSchema(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("CheckSchema on an empty database = %v, want ErrSchemaOutdated", err)
+	}
+	if baselined, err := fresh.BaselineFresh(ctx); err != nil || !baselined {
+		t.Fatalf("BaselineFresh on an empty database = %v, %v", baselined, err)
+	}
+	if err := fresh.CheckSchema(ctx); err != nil {
+		t.Errorf("CheckSchema after baselining: %v", err)
+	}
+
+	// A database with data but no history still needs migrating
+	existing := newTestDB(t, url)
+	if _, err := existing.db.Collection("questions").InsertOne(ctx, bson.M{"title": "Two Sum"}); err != nil {
+		t.Fatal(err)
+	}
+	if baselined, err := existing.BaselineFresh(ctx); err != nil || baselined {
+		t.Fatalf("BaselineFresh with data = %v, %v", baselined, err)
+	}
+	if err := existing.CheckSchema(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckSchema with data = %v, want ErrSchemaOutdated", err)
+	}
+}
+
+func TestConcurrentSignups(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	m := newTestDB(t, url)
+
+	const attempts = 8
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := m.CreateUser(context.Background(), "Racer", "race@example.com", "hash", fmt.Sprintf("23%05d", i), types.RoleUser)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, storage.ErrConflict):
+		default:
+			t.Errorf("CreateUser: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d users with the same email, want 1", created)
+	}
+
+	_, err := m.CreateUser(context.Background(), "Other", "other@example.com", "hash", "2300000", types.RoleUser)
+	if !errors.Is(err, storage.ErrConflict) || !strings.Contains(err.Error(), "student ID") {
+		t.Errorf("duplicate student ID = %v, want student ID conflict", err)
+	}
+}
+
+func TestEnsureIndexesIdempotent(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	m := newTestDB(t, url)
+
+	if err := m.EnsureIndexes(context.Background()); err != nil {
+		t.Errorf("second EnsureIndexes: %v", err)
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+	m := newTestDB(t, url)
+	ctx := context.Background()
+
+	contestId, err := m.CreateContest(ctx, types.Contest{Title: "Weekly", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := m.AddQuestionToContest(ctx, contestId, types.Question{Title: "Kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddTestCaseToQuestion(ctx, kept, types.TestCase{Input: "1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed add must not leave the new document behind
+	if _, err := m.AddQuestionToContest(ctx, primitive.NewObjectID().Hex(), types.Question{Title: "Lost"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("AddQuestionToContest with missing contest = %v", err)
+	}
+	if n, _ := m.db.Collection("questions").CountDocuments(ctx, bson.M{"title": "Lost"}); n != 0 {
+		t.Errorf("failed add left %d questions behind", n)
+	}
+
+	// A question in no contest is just in the bank
+	banked, err := m.CreateQuestion(ctx, types.Question{Title: "Banked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage left behind by writes from before transactions
+	orphanCase, err := m.CreateTestCase(ctx, types.TestCase{Input: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contestObjID, _ := primitive.ObjectIDFromHex(contestId)
+	missing := primitive.NewObjectID()
+	if _, err := m.db.Collection("contests").UpdateOne(ctx, bson.M{"_id": contestObjID}, bson.M{"$push": bson.M{"problems": types.ContestProblem{QuestionID: missing}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.CheckConsistency(ctx, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.DanglingQuestions) != 1 || report.DanglingQuestions[0].Child != missing {
+		t.Errorf("dangling questions = %+v", report.DanglingQuestions)
+	}
+	if len(report.OrphanedTestCases) != 1 || report.OrphanedTestCases[0].Hex() != orphanCase {
+		t.Errorf("orphaned test cases = %+v", report.OrphanedTestCases)
+	}
+
+	if report, err := m.CheckConsistency(ctx, false, time.Hour); err != nil || len(report.OrphanedTestCases) != 0 {
+		t.Errorf("orphans within the grace period = %+v, %v", report, err)
+	}
+
+	if _, err := m.CheckConsistency(ctx, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	report, err = m.CheckConsistency(ctx, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Problems() != 0 {
+		t.Errorf("problems after repair = %+v", report)
+	}
+	if _, err := m.GetQuestionById(ctx, kept); err != nil {
+		t.Errorf("repair removed a listed question: %v", err)
+	}
+	if _, err := m.GetQuestionById(ctx, banked); err != nil {
+		t.Errorf("repair removed a bank question: %v", err)
+	}
+}
+
+// newTestDB connects to a fresh database that is dropped when t finishes.
+func newTestDB(t *testing.T, url string) *MongoDB {
+	t.Helper()
+	cfg := &config.Config{
+		DatabaseURL:  url,
+		DatabaseName: "portal_test_" + primitive.NewObjectID().Hex(),
+		DatabaseTimeouts: config.DatabaseTimeouts{
+			Connect:   10 * time.Second,
+			Read:      5 * time.Second,
+			Write:     5 * time.Second,
+			Aggregate: 10 * time.Second,
+		},
+	}
+
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", url, err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.db.Drop(ctx); err != nil {
+			t.Errorf("dropping %s: %v", cfg.DatabaseName, err)
+		}
+		m.Close(ctx)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestBlobStore(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL not set")
+	}
+
+	blobtest.Run(t, func(t *testing.T) blob.Store {
+		return newTestDB(t, url).NewBlobStore()
+	})
+}
@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// objectIDsUp rewrites documents stored before question and test case
+// references were ObjectIDs: hex strings in contests.question_ids,
+// questions.test_case_ids and contests.created_by become ObjectIDs.
+// References that are not valid hex could never have resolved to a
+// document and are dropped.
+func objectIDsUp(ctx context.Context, db *mongo.Database) error {
+	if err := convertReferences(ctx, db.Collection("contests"), "question_ids", "created_by"); err != nil {
+		return err
+	}
+	return convertReferences(ctx, db.Collection("questions"), "test_case_ids", "")
+}
+
+// objectIDsDown turns the references back into hex strings.
+func objectIDsDown(ctx context.Context, db *mongo.Database) error {
+	toStrings := func(field string) bson.M {
+		return bson.M{"$map": bson.M{"input": "$" + field, "in": bson.M{"$toString": "$$this"}}}
+	}
+
+	_, err := db.Collection("contests").UpdateMany(ctx,
+		bson.M{"question_ids": bson.M{"$type": "objectId"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"question_ids": toStrings("question_ids")}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error converting contests: %v", err)
+	}
+
+	_, err = db.Collection("contests").UpdateMany(ctx,
+		bson.M{"created_by": bson.M{"$type": "objectId"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"created_by": bson.M{"$toString": "$created_by"}}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error converting contests: %v", err)
+	}
+
+	_, err = db.Collection("questions").UpdateMany(ctx,
+		bson.M{"test_case_ids": bson.M{"$type": "objectId"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"test_case_ids": toStrings("test_case_ids")}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error converting questions: %v", err)
+	}
+
+	return nil
+}
+
+// convertReferences converts the string ids in listField, and in idField
+// if set, of every document in coll that still has any.
+func convertReferences(ctx context.Context, coll *mongo.Collection, listField, idField string) error {
+	filter := bson.A{bson.M{listField: bson.M{"$type": "string"}}}
+	if idField != "" {
+		filter = append(filter, bson.M{idField: bson.M{"$type": "string"}})
+	}
+
+	cursor, err := coll.Find(ctx, bson.M{"$or": filter})
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", coll.Name(), err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		raw := cursor.Current
+		docID, _ := raw.Lookup("_id").ObjectIDOK()
+
+		var list []interface{}
+		if value := raw.Lookup(listField); value.Type == bson.TypeArray {
+			if err := value.Unmarshal(&list); err != nil {
+				return fmt.Errorf("error decoding %s %s: %v", coll.Name(), docID.Hex(), err)
+			}
+		}
+
+		ids := []primitive.ObjectID{}
+		for _, value := range list {
+			switch value := value.(type) {
+			case primitive.ObjectID:
+				ids = append(ids, value)
+			case string:
+				if id, err := primitive.ObjectIDFromHex(value); err == nil {
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		set := bson.M{listField: ids}
+		update := bson.M{"$set": set}
+		if idField != "" {
+			if ref, ok := raw.Lookup(idField).StringValueOK(); ok {
+				if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+					set[idField] = id
+				} else {
+					update["$unset"] = bson.M{idField: ""}
+				}
+			}
+		}
+
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": docID}, update); err != nil {
+			return fmt.Errorf("error updating %s %s: %v", coll.Name(), docID.Hex(), err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %v", coll.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/ratelimit"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const rateLimitUpdateAttempts = 10
+
+type rateLimitDocument struct {
+	Key             string `bson:"_id"`
+	Version         int64  `bson:"version"`
+	ratelimit.State `bson:",inline"`
+}
+
+// RateLimitStore shares limiter state between API instances through the
+// rate_limits collection. Updates use optimistic concurrency on a version
+// field so concurrent requests never lose tokens or failures.
+type RateLimitStore struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+func (m *MongoDB) NewRateLimitStore(ctx context.Context) (*RateLimitStore, error) {
+	collection := m.db.Collection("rate_limits")
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limit index: %v", err)
+	}
+
+	return &RateLimitStore{collection: collection, timeout: m.timeouts.Write}, nil
+}
+
+func (s *RateLimitStore) Get(ctx context.Context, key string) (ratelimit.State, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var doc rateLimitDocument
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return ratelimit.State{}, false, nil
+	}
+	if err != nil {
+		return ratelimit.State{}, false, err
+	}
+
+	// The TTL monitor only runs once a minute, so expired documents may
+	// still be around.
+	if doc.ExpiresAt.Before(time.Now()) {
+		return ratelimit.State{}, false, nil
+	}
+
+	return doc.State, true, nil
+}
+
+func (s *RateLimitStore) Update(ctx context.Context, key string, fn func(state *ratelimit.State, exists bool)) (ratelimit.State, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	for attempt := 0; attempt < rateLimitUpdateAttempts; attempt++ {
+		var doc rateLimitDocument
+		found := true
+		err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			found = false
+			doc = rateLimitDocument{Key: key}
+		} else if err != nil {
+			return ratelimit.State{}, err
+		}
+
+		exists := found
+		state := doc.State
+		if found && doc.ExpiresAt.Before(time.Now()) {
+			exists = false
+			state = ratelimit.State{}
+		}
+
+		fn(&state, exists)
+
+		next := rateLimitDocument{Key: key, Version: doc.Version + 1, State: state}
+		if found {
+			result, err := s.collection.ReplaceOne(ctx, bson.M{"_id": key, "version": doc.Version}, next)
+			if err != nil {
+				return ratelimit.State{}, err
+			}
+			if result.MatchedCount == 1 {
+				return state, nil
+			}
+			continue
+		}
+
+		_, err = s.collection.InsertOne(ctx, next)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return ratelimit.State{}, err
+		}
+		return state, nil
+	}
+
+	return ratelimit.State{}, fmt.Errorf("rate limit state for %s is too contended", key)
+}
+
+func (s *RateLimitStore) Delete(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,206 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/statement"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *MongoDB) ListQuestionRevisions(ctx context.Context, questionId string) ([]types.Revision, error) {
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return nil, storage.InvalidID("question")
+	}
+
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	err = m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Err()
+	if err == mongo.ErrNoDocuments {
+		return nil, storage.NotFound("no question found with the given id")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error checking question existence: %v", err)
+	}
+
+	revisions := []types.Revision{}
+	if err := m.findAll(ctx, "revisions", bson.M{"question_id": questionObjID}, nil, &revisions); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(revisions, func(a, b types.Revision) int { return a.Number - b.Number })
+	return revisions, nil
+}
+
+func (m *MongoDB) RollbackQuestion(ctx context.Context, questionId string, number int, author string) error {
+	questionObjID, err := primitive.ObjectIDFromHex(questionId)
+	if err != nil {
+		return storage.InvalidID("question")
+	}
+	authorID, err := parseAuthor(author)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.withTransaction(ctx, func(ctx context.Context) error {
+		var question types.Question
+		err := m.db.Collection("questions").FindOne(ctx, bson.M{"_id": questionObjID, "deleted_at": nil}).Decode(&question)
+		if err == mongo.ErrNoDocuments {
+			return storage.NotFound("no question found with the given id")
+		}
+		if err != nil {
+			return fmt.Errorf("error checking question existence: %v", err)
+		}
+
+		var target types.Revision
+		err = m.db.Collection("revisions").FindOne(ctx, bson.M{"question_id": questionObjID, "number": number}).Decode(&target)
+		if err == mongo.ErrNoDocuments {
+			return storage.NotFound("question has no revision %d", number)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading revision: %v", err)
+		}
+
+		rev := types.Revision{QuestionID: questionObjID, TestCaseID: target.TestCaseID, Action: types.RevisionRollback, RollbackOf: number, Author: authorID}
+		if target.TestCaseID == nil {
+			update, err := restoreQuestion(target.Snapshot)
+			if err != nil {
+				return err
+			}
+			before := storage.QuestionSnapshot(question)
+			return m.revise(ctx, rev, before, storage.Overlay(before, target.Snapshot), storage.QuestionOriginal(question), func(ctx context.Context) error {
+				if _, err := m.db.Collection("questions").UpdateOne(ctx, bson.M{"_id": questionObjID}, bson.M{"$set": update}); err != nil {
+					return fmt.Errorf("failed to update question: %v", err)
+				}
+				return nil
+			})
+		}
+
+		if target.Action == types.RevisionRemove {
+			return storage.Validation("revision %d removed a test case; restore it from the trash instead", number)
+		}
+		var testCase types.TestCase
+		err = m.db.Collection("test_cases").FindOne(ctx, bson.M{"_id": *target.TestCaseID, "deleted_at": nil}).Decode(&testCase)
+		if err == mongo.ErrNoDocuments || (err == nil && !slices.Contains(question.TestCaseIDs, testCase.ID)) {
+			return storage.NotFound("the test case revision %d changed is no longer part of the question", number)
+		}
+		if err != nil {
+			return fmt.Errorf("error checking test case existence: %v", err)
+		}
+
+		before := storage.TestCaseSnapshot(testCase)
+		return m.revise(ctx, rev, before, storage.Overlay(before, target.Snapshot), types.Revision{CreatedAt: testCase.CreatedAt}, func(ctx context.Context) error {
+			if _, err := m.db.Collection("test_cases").UpdateOne(ctx, bson.M{"_id": testCase.ID}, bson.M{"$set": target.Snapshot}); err != nil {
+				return fmt.Errorf("failed to update test case: %v", err)
+			}
+			return nil
+		})
+	})
+}
+
+// revise records rev, the change from before to after of a question or
+// one of its test cases, then applies it. Nothing is recorded or applied
+// if nothing changed. The first change to a question or test case also
+// records, as original, how it was before, unless it didn't exist; that may
+// predate revisions.
+func (m *MongoDB) revise(ctx context.Context, rev types.Revision, before, after bson.M, original types.Revision, apply func(ctx context.Context) error) error {
+	rev.Changes = storage.DiffSnapshots(before, after)
+	if len(rev.Changes) == 0 {
+		return nil
+	}
+
+	revisions := m.db.Collection("revisions")
+	var last types.Revision
+	err := revisions.FindOne(ctx, bson.M{"question_id": rev.QuestionID}, options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("error reading revisions: %v", err)
+	}
+	number := last.Number
+
+	var recorded []interface{}
+	if len(before) > 0 {
+		err = revisions.FindOne(ctx, bson.M{"question_id": rev.QuestionID, "test_case_id": rev.TestCaseID}).Err()
+		if err == mongo.ErrNoDocuments {
+			number++
+			recorded = append(recorded, storage.OriginalRevision(rev, original, number, before))
+		} else if err != nil {
+			return fmt.Errorf("error reading revisions: %v", err)
+		}
+	}
+
+	number++
+	rev.Number = number
+	rev.CreatedAt = time.Now()
+	rev.Snapshot = after
+	recorded = append(recorded, rev)
+
+	result, err := revisions.InsertMany(ctx, recorded)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return storage.Conflict("question was modified concurrently, try again")
+		}
+		return fmt.Errorf("error recording revision: %v", err)
+	}
+
+	if err := apply(ctx); err != nil {
+		if !m.transactions {
+			revisions.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": result.InsertedIDs}})
+		}
+		return err
+	}
+	return nil
+}
+
+// reviseTestCase records a test case being added to or removed from a
+// question, then applies it.
+func (m *MongoDB) reviseTestCase(ctx context.Context, questionId, testCaseId primitive.ObjectID, action types.RevisionAction, author *primitive.ObjectID, before, after bson.M, apply func(ctx context.Context) error) error {
+	rev := types.Revision{QuestionID: questionId, TestCaseID: &testCaseId, Action: action, Author: author}
+	return m.revise(ctx, rev, before, after, types.Revision{}, apply)
+}
+
+// restoreQuestion is the update putting a question back to snapshot, with
+// its statement rendered again.
+func restoreQuestion(snapshot map[string]interface{}) (bson.M, error) {
+	update := bson.M{}
+	for field, value := range snapshot {
+		update[field] = value
+	}
+	if _, ok := snapshot["statement"]; !ok {
+		return update, nil
+	}
+
+	var restored types.Question
+	data, err := bson.Marshal(snapshot)
+	if err == nil {
+		err = bson.Unmarshal(data, &restored)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading revision: %v", err)
+	}
+	update["statement_html"] = statement.Cached(restored.Statement, nil)
+	return update, nil
+}
+
+// parseAuthor parses the id of the user making a change, which is nil if
+// the change is anonymous.
+func parseAuthor(author string) (*primitive.ObjectID, error) {
+	if author == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(author)
+	if err != nil {
+		return nil, storage.InvalidID("author")
+	}
+	return &id, nil
+}
@@ -0,0 +1,54 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// supportsTransactions reports whether the server is a replica set member
+// or a mongos router. Standalone servers reject multi-document
+// transactions.
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// SupportsTransactions reports whether composite writes run inside
+// transactions or fall back to undoing partial writes by hand.
+func (m *MongoDB) SupportsTransactions() bool {
+	return m.transactions
+}
+
+// withTransaction runs fn in a transaction when the deployment supports
+// them, passing it the session context to use for every operation. fn
+// may be retried on transient errors, so it must not keep state between
+// calls.
+//
+// Without transaction support fn runs directly. It should then check
+// everything it can before its first write and undo earlier writes itself
+// if a later one fails; any it can't undo are found by CheckConsistency.
+func (m *MongoDB) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.transactions {
+		return fn(ctx)
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
@@ -0,0 +1,339 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Deleted contests, questions and test cases keep their documents, and
+// their place in their parent's list, with deleted_at set until they are
+// purged. Reads filter them out.
+
+// deletion stamps everything trashed by one delete. RestoreDeleted brings
+// back whatever shares its deletion_id.
+type deletion struct {
+	at time.Time
+	id primitive.ObjectID
+}
+
+func newDeletion() deletion {
+	return deletion{at: time.Now(), id: primitive.NewObjectID()}
+}
+
+func (d deletion) update() bson.M {
+	return bson.M{"$set": bson.M{"deleted_at": d.at, "deletion_id": d.id}}
+}
+
+// notDeleted filters an array of looked up documents down to those not in
+// the trash.
+func notDeleted(array string) bson.D {
+	return bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: array},
+		{Key: "as", Value: "doc"},
+		{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$$doc.deleted_at", nil}}},
+			nil,
+		}}}},
+	}}}
+}
+
+// trashQuestions moves the live questions among ids to the trash, with
+// their test cases if cascade.
+func (m *MongoDB) trashQuestions(ctx context.Context, ids []primitive.ObjectID, d deletion, cascade bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	filter := bson.M{"_id": bson.M{"$in": ids}, "deleted_at": nil}
+
+	var testCaseIds []primitive.ObjectID
+	if cascade {
+		var questions []types.Question
+		if err := m.findAll(ctx, "questions", filter, nil, &questions); err != nil {
+			return err
+		}
+		for _, question := range questions {
+			testCaseIds = append(testCaseIds, question.TestCaseIDs...)
+		}
+	}
+
+	if _, err := m.db.Collection("questions").UpdateMany(ctx, filter, d.update()); err != nil {
+		return fmt.Errorf("failed to delete questions: %v", err)
+	}
+	return m.trashTestCases(ctx, testCaseIds, d)
+}
+
+func (m *MongoDB) trashTestCases(ctx context.Context, ids []primitive.ObjectID, d deletion) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	filter := bson.M{"_id": bson.M{"$in": ids}, "deleted_at": nil}
+	if _, err := m.db.Collection("test_cases").UpdateMany(ctx, filter, d.update()); err != nil {
+		return fmt.Errorf("failed to delete test cases: %v", err)
+	}
+	return nil
+}
+
+func (m *MongoDB) ListTrash(ctx context.Context) ([]types.TrashItem, error) {
+	ctx, cancel := m.readContext(ctx)
+	defer cancel()
+
+	type trashed struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Title     string             `bson:"title"`
+		DeletedAt time.Time          `bson:"deleted_at"`
+	}
+
+	items := []types.TrashItem{}
+	for _, source := range []struct {
+		kind       types.ContentKind
+		collection string
+	}{
+		{types.KindContest, "contests"},
+		{types.KindQuestion, "questions"},
+		{types.KindTestCase, "test_cases"},
+	} {
+		cursor, err := m.db.Collection(source.collection).Find(ctx,
+			bson.M{"deleted_at": bson.M{"$ne": nil}},
+			options.Find().SetProjection(bson.M{"title": 1, "deleted_at": 1}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", source.collection, err)
+		}
+		var docs []trashed
+		err = cursor.All(ctx, &docs)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", source.collection, err)
+		}
+
+		for _, doc := range docs {
+			items = append(items, types.TrashItem{Kind: source.kind, ID: doc.ID, Title: doc.Title, DeletedAt: doc.DeletedAt})
+		}
+	}
+	storage.SortTrash(items)
+
+	return items, nil
+}
+
+// RestoreDeleted takes an item out of the trash together with whatever
+// was trashed with it.
+func (m *MongoDB) RestoreDeleted(ctx context.Context, kind types.ContentKind, id string) error {
+	var collection, entity string
+	switch kind {
+	case types.KindContest:
+		collection, entity = "contests", "contest"
+	case types.KindQuestion:
+		collection, entity = "questions", "question"
+	case types.KindTestCase:
+		collection, entity = "test_cases", "test case"
+	default:
+		return storage.Validation("unknown content kind %q", kind)
+	}
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return storage.InvalidID(entity)
+	}
+
+	ctx, cancel := m.writeContext(ctx)
+	defer cancel()
+
+	return m.withTransaction(ctx, func(ctx context.Context) error {
+		var doc struct {
+			DeletionID primitive.ObjectID     `bson:"deletion_id"`
+			Problems   []types.ContestProblem `bson:"problems"`
+		}
+		err := m.db.Collection(collection).FindOne(ctx, bson.M{"_id": objectId, "deleted_at": bson.M{"$ne": nil}}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			return storage.NotFound("no deleted %s found with the given id", entity)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case types.KindContest:
+			if err := m.restore(ctx, "contests", []primitive.ObjectID{objectId}, doc.DeletionID); err != nil {
+				return err
+			}
+			ids := make([]primitive.ObjectID, 0, len(doc.Problems))
+			for _, problem := range doc.Problems {
+				ids = append(ids, problem.QuestionID)
+			}
+			return m.restoreQuestions(ctx, ids, doc.DeletionID)
+		case types.KindQuestion:
+			return m.restoreQuestions(ctx, []primitive.ObjectID{objectId}, doc.DeletionID)
+		default:
+			return m.restore(ctx, "test_cases", []primitive.ObjectID{objectId}, doc.DeletionID)
+		}
+	})
+}
+
+func (m *MongoDB) restoreQuestions(ctx context.Context, ids []primitive.ObjectID, deletionId primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var questions []types.Question
+	if err := m.findAll(ctx, "questions", bson.M{"_id": bson.M{"$in": ids}, "deletion_id": deletionId}, nil, &questions); err != nil {
+		return err
+	}
+	var testCaseIds []primitive.ObjectID
+	for _, question := range questions {
+		testCaseIds = append(testCaseIds, question.TestCaseIDs...)
+	}
+
+	if err := m.restore(ctx, "questions", ids, deletionId); err != nil {
+		return err
+	}
+	return m.restore(ctx, "test_cases", testCaseIds, deletionId)
+}
+
+// restore takes the documents among ids trashed by one deletion out of
+// the trash.
+func (m *MongoDB) restore(ctx context.Context, collection string, ids []primitive.ObjectID, deletionId primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	filter := bson.M{"_id": bson.M{"$in": ids}, "deletion_id": deletionId}
+	if _, err := m.db.Collection(collection).UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"deleted_at": "", "deletion_id": ""}}); err != nil {
+		return fmt.Errorf("failed to restore %s: %v", collection, err)
+	}
+	return nil
+}
+
+// PurgeDeleted removes content trashed before the given time for good.
+// With cascade, purging a question also removes its test cases, and both
+// contests and questions remove the submissions and leaderboard rows
+// recorded against them. A contest's questions are in the bank and are
+// only purged once trashed themselves. The revision history of purged
+// questions and test cases goes with them.
+//
+// Children go before their parents, so a purge cut short leaves nothing
+// the next run can't find again.
+func (m *MongoDB) PurgeDeleted(ctx context.Context, before time.Time, cascade bool) (*types.PurgeResult, error) {
+	ctx, cancel := m.aggregateContext(ctx)
+	defer cancel()
+
+	expired := bson.M{"deleted_at": bson.M{"$lt": before}}
+
+	var contests []types.Contest
+	if err := m.findAll(ctx, "contests", expired, nil, &contests); err != nil {
+		return nil, err
+	}
+	var contestIds, questionIds, testCaseIds []primitive.ObjectID
+	for _, contest := range contests {
+		contestIds = append(contestIds, contest.ID)
+	}
+
+	var questions []types.Question
+	if err := m.findAll(ctx, "questions", expired, nil, &questions); err != nil {
+		return nil, err
+	}
+	for _, question := range questions {
+		questionIds = append(questionIds, question.ID)
+		if cascade {
+			testCaseIds = append(testCaseIds, question.TestCaseIDs...)
+		}
+	}
+
+	var testCases []types.TestCase
+	filter := bson.M{"$or": bson.A{expired, bson.M{"_id": bson.M{"$in": nonNil(testCaseIds)}}}}
+	if err := m.findAll(ctx, "test_cases", filter, nil, &testCases); err != nil {
+		return nil, err
+	}
+	testCaseIds = nil
+	for _, testCase := range testCases {
+		testCaseIds = append(testCaseIds, testCase.ID)
+	}
+
+	result := &types.PurgeResult{}
+	if cascade && (len(contestIds) > 0 || len(questionIds) > 0) {
+		deleted, err := m.db.Collection("submissions").DeleteMany(ctx, bson.M{"$or": bson.A{
+			bson.M{"contest_id": bson.M{"$in": nonNil(contestIds)}},
+			bson.M{"question_id": bson.M{"$in": nonNil(questionIds)}},
+		}})
+		if err != nil {
+			return result, fmt.Errorf("error purging submissions: %v", err)
+		}
+		result.Submissions = int(deleted.DeletedCount)
+
+		deleted, err = m.db.Collection("leaderboard").DeleteMany(ctx, bson.M{"contest_id": bson.M{"$in": nonNil(contestIds)}})
+		if err != nil {
+			return result, fmt.Errorf("error purging leaderboard: %v", err)
+		}
+		result.LeaderboardRows = int(deleted.DeletedCount)
+	}
+
+	if len(questionIds) > 0 || len(testCaseIds) > 0 {
+		deleted, err := m.db.Collection("revisions").DeleteMany(ctx, bson.M{"$or": bson.A{
+			bson.M{"question_id": bson.M{"$in": nonNil(questionIds)}},
+			bson.M{"test_case_id": bson.M{"$in": nonNil(testCaseIds)}},
+		}})
+		if err != nil {
+			return result, fmt.Errorf("error purging revisions: %v", err)
+		}
+		result.Revisions = int(deleted.DeletedCount)
+	}
+
+	var err error
+	if result.TestCases, err = m.purge(ctx, "test_cases", testCaseIds, "questions", "test_case_ids", ""); err != nil {
+		return result, err
+	}
+	if result.Questions, err = m.purge(ctx, "questions", questionIds, "contests", "problems", "question_id"); err != nil {
+		return result, err
+	}
+	if result.Contests, err = m.purge(ctx, "contests", contestIds, "", "", ""); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// purge deletes the documents with the given ids after pulling them from
+// field in every parent listing them.
+func (m *MongoDB) purge(ctx context.Context, collection string, ids []primitive.ObjectID, parents, field, key string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if parents != "" {
+		if err := m.pullReferences(ctx, parents, field, key, bson.M{"$in": ids}); err != nil {
+			return 0, fmt.Errorf("error removing purged %s from %s: %v", collection, parents, err)
+		}
+	}
+
+	result, err := m.db.Collection(collection).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("error purging %s: %v", collection, err)
+	}
+	return int(result.DeletedCount), nil
+}
+
+// pullReferences removes the ids matching match from the list in field of
+// every document in parents. key names the id within each entry when the
+// list holds documents rather than bare ids.
+func (m *MongoDB) pullReferences(ctx context.Context, parents, field, key string, match interface{}) error {
+	path, pull := field, match
+	if key != "" {
+		path, pull = field+"."+key, bson.M{key: match}
+	}
+	_, err := m.db.Collection(parents).UpdateMany(ctx, bson.M{path: match}, bson.M{"$pull": bson.M{field: pull}})
+	return err
+}
+
+// nonNil keeps an empty id list from encoding as null, which $in rejects.
+func nonNil(ids []primitive.ObjectID) []primitive.ObjectID {
+	if ids == nil {
+		return []primitive.ObjectID{}
+	}
+	return ids
+}
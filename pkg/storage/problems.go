@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlaceProblem returns problems with problem inserted at the 1-based
+// position, or appended when position is 0 or past the end. problems is
+// not modified.
+func PlaceProblem(problems []types.ContestProblem, problem types.ContestProblem, position int) []types.ContestProblem {
+	placed := make([]types.ContestProblem, 0, len(problems)+1)
+	if position < 1 || position > len(problems) {
+		placed = append(placed, problems...)
+		return append(placed, problem)
+	}
+	placed = append(placed, problems[:position-1]...)
+	placed = append(placed, problem)
+	return append(placed, problems[position-1:]...)
+}
+
+// NewProblem is the entry placement gives a question in a contest.
+func NewProblem(questionId primitive.ObjectID, placement types.ProblemPlacement) types.ContestProblem {
+	return types.ContestProblem{
+		QuestionID: questionId,
+		Points:     placement.Points,
+		Label:      placement.Label,
+		Colour:     placement.Colour,
+	}
+}
+
+// ProblemSummaries lists a contest's questions in contest order with how
+// each is labelled and scored there. find returns a live question by id;
+// problems it can't find are left out. The rest keep their positions and
+// letters, so trashing a question mid-contest doesn't relabel the others.
+func ProblemSummaries(problems []types.ContestProblem, find func(primitive.ObjectID) (types.Question, bool)) []types.QuestionSummary {
+	summaries := []types.QuestionSummary{}
+	for i, problem := range problems {
+		question, ok := find(problem.QuestionID)
+		if !ok {
+			continue
+		}
+		points := question.Points
+		if problem.Points != nil {
+			points = *problem.Points
+		}
+		position := i + 1
+		label := problem.Label
+		if label == "" {
+			label = PositionLabel(position)
+		}
+		summaries = append(summaries, types.QuestionSummary{
+			ID:          question.ID,
+			Title:       question.Title,
+			Description: question.Description,
+			Difficulty:  question.Difficulty,
+			Position:    position,
+			Label:       label,
+			Colour:      problem.Colour,
+			Points:      points,
+		})
+	}
+	return summaries
+}
+
+// PositionLabel is the default label for the 1-based position: A to Z,
+// then AA, AB and so on.
+func PositionLabel(position int) string {
+	label := ""
+	for ; position > 0; position = (position - 1) / 26 {
+		label = string(rune('A'+(position-1)%26)) + label
+	}
+	return label
+}
+
+// CheckProblemLabels returns a validation error if two problems would be
+// shown with the same label.
+func CheckProblemLabels(problems []types.ContestProblem) error {
+	seen := make(map[string]bool, len(problems))
+	for i, problem := range problems {
+		label := problem.Label
+		if label == "" {
+			label = PositionLabel(i + 1)
+		}
+		if seen[label] {
+			return Validation("label %q is used by more than one question in the contest", label)
+		}
+		seen[label] = true
+	}
+	return nil
+}
+
+// ReorderProblems returns problems in the order of questionIds, which
+// must list every question in the contest exactly once.
+func ReorderProblems(problems []types.ContestProblem, questionIds []string) ([]types.ContestProblem, error) {
+	if len(questionIds) != len(problems) {
+		return nil, Validation("question_ids must list all %d questions in the contest", len(problems))
+	}
+
+	byId := make(map[primitive.ObjectID]types.ContestProblem, len(problems))
+	for _, problem := range problems {
+		byId[problem.QuestionID] = problem
+	}
+	reordered := make([]types.ContestProblem, 0, len(problems))
+	for _, id := range questionIds {
+		objectId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, InvalidID("question")
+		}
+		problem, ok := byId[objectId]
+		if !ok {
+			return nil, Validation("question %s is not in the contest or is listed twice", id)
+		}
+		delete(byId, objectId)
+		reordered = append(reordered, problem)
+	}
+	return reordered, nil
+}
+
+// ProblemIndex returns the index of the question in problems, or -1.
+func ProblemIndex(problems []types.ContestProblem, questionId primitive.ObjectID) int {
+	for i, problem := range problems {
+		if problem.QuestionID == questionId {
+			return i
+		}
+	}
+	return -1
+}
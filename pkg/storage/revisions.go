@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// QuestionSnapshot is the part of a question revisions track, keyed by
+// BSON field name.
+func QuestionSnapshot(question types.Question) bson.M {
+	tags := question.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	maxAttempts := 0
+	if question.MaxAttempts != nil {
+		maxAttempts = *question.MaxAttempts
+	}
+	return Snapshot(bson.M{
+		"title":          question.Title,
+		"description":    question.Description,
+		"statement":      question.Statement,
+		"difficulty":     question.Difficulty,
+		"tags":           tags,
+		"points":         question.Points,
+		"cpu_time_limit": question.Cpu_time_limit,
+		"memory_limit":   question.Memory_limit,
+		"max_attempts":   maxAttempts,
+	})
+}
+
+// TestCaseSnapshot is the part of a test case revisions track, keyed by
+// BSON field name.
+func TestCaseSnapshot(testCase types.TestCase) bson.M {
+	return Snapshot(bson.M{
+		"input":                testCase.Input,
+		"expected_output":      testCase.ExpectedOutput,
+		"input_blob":           testCase.InputBlob,
+		"expected_output_blob": testCase.ExpectedOutputBlob,
+		"visibility":           testCase.Visibility,
+		"subtask":              testCase.Subtask,
+	})
+}
+
+// Snapshot returns fields as they read back from MongoDB, so snapshots
+// built from a request and from a stored document compare equal when their
+// values do.
+func Snapshot(fields bson.M) bson.M {
+	data, err := bson.Marshal(fields)
+	if err != nil {
+		panic(fmt.Sprintf("storage: cannot encode snapshot: %v", err))
+	}
+	snapshot := bson.M{}
+	if err := bson.Unmarshal(data, &snapshot); err != nil {
+		panic(fmt.Sprintf("storage: cannot decode snapshot: %v", err))
+	}
+	return snapshot
+}
+
+// Overlay returns a snapshot of before with the fields in update replaced.
+// Fields before doesn't track are left out.
+func Overlay(before, update bson.M) bson.M {
+	after := bson.M{}
+	for field, value := range before {
+		after[field] = value
+		if value, ok := update[field]; ok {
+			after[field] = value
+		}
+	}
+	return Snapshot(after)
+}
+
+// DiffSnapshots lists the fields whose values differ between two
+// snapshots, by field name.
+func DiffSnapshots(before, after bson.M) []types.FieldChange {
+	fields := make([]string, 0, len(after))
+	for field := range after {
+		fields = append(fields, field)
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []types.FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, types.FieldChange{Field: field, Old: before[field], New: after[field]})
+		}
+	}
+	return changes
+}
+
+// OriginalRevision is the revision recording how the entity rev changes
+// was before its first change. original carries its author and creation
+// time, when known.
+func OriginalRevision(rev types.Revision, original types.Revision, number int, before bson.M) types.Revision {
+	if original.CreatedAt.IsZero() {
+		id := rev.QuestionID
+		if rev.TestCaseID != nil {
+			id = *rev.TestCaseID
+		}
+		original.CreatedAt = id.Timestamp()
+	}
+	original.QuestionID = rev.QuestionID
+	original.TestCaseID = rev.TestCaseID
+	original.Number = number
+	original.Action = types.RevisionOriginal
+	original.Changes = []types.FieldChange{}
+	original.Snapshot = before
+	return original
+}
+
+// QuestionOriginal carries who created the question and when, for its
+// original revision.
+func QuestionOriginal(question types.Question) types.Revision {
+	original := types.Revision{CreatedAt: question.CreatedAt}
+	if !question.CreatedBy.IsZero() {
+		original.Author = &question.CreatedBy
+	}
+	return original
+}
@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 
@@ -10,23 +12,82 @@ type Storage interface {
 	// CreateUser(name string, email string, age int)(int64, error)
 	// GetUserById(id int64)(types.User, error) 
 	// GetAllUsers()([]types.User, error)
-	CreateUser(name string, email string, password string, studentId string, role types.Role) (string, error)
-	GetUserByEmail(email string) (*types.User, error)
-	CreateContest(contest types.Contest) (string, error)
-	DeleteContestById(id string) error
-	CreateQuestion(question types.Question) (string, error)
-	EditQuestionById(id string, question types.Question) error
-	EditContestById(id string, contest types.Contest) error
-	DeleteQuestionFromContestById(contestId string, questionId string) error
-	CreateTestCase(testCase types.TestCase) (string, error)
-	GetAllContests() ([]types.ContestBasicInfo, error)
-	GetContestById(id string) ([]bson.M, error)
-	GetQuestionById(id string) ([]bson.M, error)
-	AddQuestionToContest(contestId string, question types.Question) (string, error)
-	AddTestCaseToQuestion(questionId string, testCase types.TestCase) (string, error)
-	DeleteTestCaseFromQuestionById(questionId string, testCaseId string) error
-	EditTestCaseById(testCaseId string, testCase types.TestCase) error
-	CreateSubmission(submission types.Submission) (string, error)
-	GetSubmissionById(id string) (*types.Submission, error)
-	UpdateSubmissionStatus(id string, status string, score int) error
+	CreateUser(ctx context.Context, name string, email string, password string, studentId string, role types.Role) (string, error)
+	GetUserByEmail(ctx context.Context, email string) (*types.User, error)
+	GetUserById(ctx context.Context, id string) (*types.User, error)
+	GetUserByStudentId(ctx context.Context, studentId string) (*types.User, error)
+	InsertUser(ctx context.Context, user types.User) (string, error)
+	AcceptInvitation(ctx context.Context, tokenHash string, password string) error
+	UpdateUserProfile(ctx context.Context, id string, profile types.ProfileUpdate) error
+	ListUsers(ctx context.Context, filter types.UserFilter) (*types.UserList, error)
+	UpdateUserRole(ctx context.Context, id string, role types.Role) error
+	SetUserDisabled(ctx context.Context, id string, disabled bool) error
+	DeleteUserById(ctx context.Context, id string) error
+	GetPublicProfile(ctx context.Context, id string) (*types.PublicProfile, error)
+	CreateContest(ctx context.Context, contest types.Contest) (string, error)
+	DeleteContestById(ctx context.Context, id string, cascade bool) error
+	CreateQuestion(ctx context.Context, question types.Question) (string, error)
+	EditQuestionById(ctx context.Context, id string, question types.Question, author string) error
+	EditContestById(ctx context.Context, id string, contest types.Contest) error
+	DeleteQuestionFromContestById(ctx context.Context, contestId string, questionId string) error
+	CreateTestCase(ctx context.Context, testCase types.TestCase) (string, error)
+	GetTestCaseById(ctx context.Context, id string) (*types.TestCase, error)
+	// GetTestCaseQuestionId returns the id of the question listing a test
+	// case, or "" if none does.
+	GetTestCaseQuestionId(ctx context.Context, testCaseId string) (string, error)
+	// ImportQuestion creates a question together with its test cases, in
+	// order, so an import either lands whole or not at all.
+	ImportQuestion(ctx context.Context, question types.Question, testCases []types.TestCase) (string, error)
+	GetAllContests(ctx context.Context) ([]types.ContestBasicInfo, error)
+	GetContestById(ctx context.Context, id string) (*types.ContestDetail, error)
+	GetQuestionById(ctx context.Context, id string) (*types.QuestionDetail, error)
+	AddQuestionToContest(ctx context.Context, contestId string, question types.Question) (string, error)
+	// Questions live in a bank and can be used by several contests.
+	// DeleteQuestionById refuses, with ErrConflict, to trash a question
+	// live contests still use unless forced.
+	ListQuestions(ctx context.Context, filter types.QuestionFilter) (*types.QuestionList, error)
+	LinkQuestionToContest(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error
+	UpdateContestProblem(ctx context.Context, contestId string, questionId string, placement types.ProblemPlacement) error
+	ReorderContestProblems(ctx context.Context, contestId string, questionIds []string) error
+	DeleteQuestionById(ctx context.Context, id string, cascade bool, force bool) error
+	AddTestCaseToQuestion(ctx context.Context, questionId string, testCase types.TestCase, author string) (string, error)
+	// A question's solutions are kept apart from what GetQuestionById
+	// returns and are replaced as a whole.
+	GetQuestionSolutions(ctx context.Context, questionId string) ([]types.Solution, error)
+	SetQuestionSolutions(ctx context.Context, questionId string, solutions []types.Solution) error
+	// GetQuestionTools returns a question's solutions, input validator and
+	// generators together.
+	GetQuestionTools(ctx context.Context, questionId string) (*types.QuestionTools, error)
+	// SetQuestionValidator sets a question's input validator. A nil
+	// validator removes it.
+	SetQuestionValidator(ctx context.Context, questionId string, validator *types.Program) error
+	SetQuestionGenerators(ctx context.Context, questionId string, generators []types.Generator) error
+	// AddTestCasesToQuestion appends testCases to a question in order, or
+	// with replace moves its current test cases to the trash and lists
+	// testCases instead. Either all of it happens or none of it does.
+	AddTestCasesToQuestion(ctx context.Context, questionId string, testCases []types.TestCase, replace bool, author string) ([]string, error)
+	DeleteTestCaseFromQuestionById(ctx context.Context, questionId string, testCaseId string, author string) error
+	EditTestCaseById(ctx context.Context, testCaseId string, testCase types.TestCase, author string) error
+	// Edits to a question or its test cases, and test cases being added to
+	// or removed from it, are recorded as revisions by author, which may be
+	// empty. Rolling back to a revision restores what it changed to how it
+	// was after that revision, as a new revision; a removal can't be rolled
+	// back to. Test cases no question lists are not tracked.
+	ListQuestionRevisions(ctx context.Context, questionId string) ([]types.Revision, error)
+	RollbackQuestion(ctx context.Context, questionId string, number int, author string) error
+	CreateSubmission(ctx context.Context, submission types.Submission) (string, error)
+	GetSubmissionById(ctx context.Context, id string) (*types.Submission, error)
+	UpdateSubmissionStatus(ctx context.Context, id string, status string, score int) error
+	// CountSubmissions counts a user's submissions to a question in a
+	// contest, or in any contest when contestId is empty.
+	CountSubmissions(ctx context.Context, userId, contestId, questionId string) (int, error)
+	CreateAuditEntry(ctx context.Context, entry types.AuditEntry) error
+	// Deleting a contest, question or test case moves it to the trash, and
+	// with cascade also its children; a contest's questions only go if no
+	// other live contest uses them. Restoring brings back whatever was
+	// trashed with it; purging removes trash older than before for good.
+	ListTrash(ctx context.Context) ([]types.TrashItem, error)
+	RestoreDeleted(ctx context.Context, kind types.ContentKind, id string) error
+	PurgeDeleted(ctx context.Context, before time.Time, cascade bool) (*types.PurgeResult, error)
+	Close(ctx context.Context) error
 }
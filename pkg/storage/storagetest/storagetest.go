@@ -0,0 +1,1379 @@
+// Package storagetest is a conformance suite for storage.Storage. Every
+// backend runs it from its own tests so they behave the same way.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/statement"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run runs the suite. newStorage must return an empty storage for each call;
+// any cleanup belongs in t.Cleanup.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"Users", testUsers},
+		{"UserUpdates", testUserUpdates},
+		{"ListUsers", testListUsers},
+		{"Invitations", testInvitations},
+		{"Contests", testContests},
+		{"ContestQuestions", testContestQuestions},
+		{"QuestionBank", testQuestionBank},
+		{"ContestProblems", testContestProblems},
+		{"QuestionTestCases", testQuestionTestCases},
+		{"Revisions", testRevisions},
+		{"Statements", testStatements},
+		{"ImportQuestion", testImportQuestion},
+		{"TestCaseBlobs", testTestCaseBlobs},
+		{"AddTestCases", testAddTestCases},
+		{"QuestionSolutions", testQuestionSolutions},
+		{"QuestionTools", testQuestionTools},
+		{"Submissions", testSubmissions},
+		{"PublicProfile", testPublicProfile},
+		{"Trash", testTrash},
+		{"Purge", testPurge},
+		{"InvalidIDs", testInvalidIDs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+var missingID = primitive.NewObjectID().Hex()
+
+func wantErr(t *testing.T, err error, kind error) {
+	t.Helper()
+	if !errors.Is(err, kind) {
+		t.Fatalf("got error %v, want %v", err, kind)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func createUser(t *testing.T, s storage.Storage, name, email, studentId string) string {
+	t.Helper()
+	id, err := s.CreateUser(context.Background(), name, email, "hashed", studentId, "")
+	must(t, err)
+	return id
+}
+
+func createContest(t *testing.T, s storage.Storage, title string, start time.Time) string {
+	t.Helper()
+	id, err := s.CreateContest(context.Background(), types.Contest{
+		Title:       title,
+		StartTime:   start,
+		EndTime:     start.Add(2 * time.Hour),
+		Description: title + " description",
+	})
+	must(t, err)
+	return id
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "Asha", "asha@example.com", "2100001")
+
+	user, err := s.GetUserById(ctx, id)
+	must(t, err)
+	if user.ID.Hex() != id || user.Name != "Asha" || user.Password != "hashed" {
+		t.Fatalf("GetUserById returned %+v", user)
+	}
+	if user.Role != types.RoleUser {
+		t.Errorf("role = %q, want default %q", user.Role, types.RoleUser)
+	}
+	if user.CreatedAt.IsZero() {
+		t.Error("CreatedAt was not set")
+	}
+
+	byEmail, err := s.GetUserByEmail(ctx, "asha@example.com")
+	must(t, err)
+	if byEmail.ID.Hex() != id {
+		t.Errorf("GetUserByEmail returned %s, want %s", byEmail.ID.Hex(), id)
+	}
+
+	byStudentId, err := s.GetUserByStudentId(ctx, "2100001")
+	must(t, err)
+	if byStudentId.ID.Hex() != id {
+		t.Errorf("GetUserByStudentId returned %s, want %s", byStudentId.ID.Hex(), id)
+	}
+
+	_, err = s.CreateUser(ctx, "Other", "asha@example.com", "hashed", "2100002", "")
+	wantErr(t, err, storage.ErrConflict)
+	_, err = s.CreateUser(ctx, "Other", "other@example.com", "hashed", "2100001", "")
+	wantErr(t, err, storage.ErrConflict)
+
+	admin, err := s.CreateUser(ctx, "Admin", "admin@example.com", "hashed", "2100003", types.RoleAdmin)
+	must(t, err)
+	user, err = s.GetUserById(ctx, admin)
+	must(t, err)
+	if user.Role != types.RoleAdmin {
+		t.Errorf("role = %q, want %q", user.Role, types.RoleAdmin)
+	}
+
+	_, err = s.GetUserByEmail(ctx, "nobody@example.com")
+	wantErr(t, err, storage.ErrNotFound)
+	_, err = s.GetUserByStudentId(ctx, "0000000")
+	wantErr(t, err, storage.ErrNotFound)
+	_, err = s.GetUserById(ctx, missingID)
+	wantErr(t, err, storage.ErrNotFound)
+}
+
+func testUserUpdates(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id := createUser(t, s, "Asha", "asha@example.com", "2100001")
+
+	must(t, s.UpdateUserProfile(ctx, id, types.ProfileUpdate{Bio: "hello", PreferredLanguage: "go"}))
+	must(t, s.UpdateUserProfile(ctx, id, types.ProfileUpdate{Name: "Asha K"}))
+	must(t, s.UpdateUserRole(ctx, id, types.RoleAdmin))
+	must(t, s.SetUserDisabled(ctx, id, true))
+
+	user, err := s.GetUserById(ctx, id)
+	must(t, err)
+	if user.Name != "Asha K" || user.Bio != "hello" || user.PreferredLanguage != "go" {
+		t.Errorf("profile not updated: %+v", user)
+	}
+	if user.Role != types.RoleAdmin || !user.Disabled {
+		t.Errorf("role/disabled not updated: role=%q disabled=%v", user.Role, user.Disabled)
+	}
+	if user.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt was not set")
+	}
+
+	wantErr(t, s.UpdateUserProfile(ctx, missingID, types.ProfileUpdate{Bio: "x"}), storage.ErrNotFound)
+	wantErr(t, s.UpdateUserRole(ctx, missingID, types.RoleUser), storage.ErrNotFound)
+	wantErr(t, s.SetUserDisabled(ctx, missingID, true), storage.ErrNotFound)
+
+	must(t, s.DeleteUserById(ctx, id))
+	_, err = s.GetUserById(ctx, id)
+	wantErr(t, err, storage.ErrNotFound)
+	wantErr(t, s.DeleteUserById(ctx, id), storage.ErrNotFound)
+}
+
+func testListUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	createUser(t, s, "Asha", "asha@example.com", "2100001")
+	time.Sleep(2 * time.Millisecond)
+	ravi := createUser(t, s, "Ravi", "ravi@example.com", "2100002")
+	time.Sleep(2 * time.Millisecond)
+	createUser(t, s, "Meera", "meera@example.com", "2200003")
+	must(t, s.UpdateUserRole(ctx, ravi, types.RoleAdmin))
+	must(t, s.SetUserDisabled(ctx, ravi, true))
+
+	list, err := s.ListUsers(ctx, types.UserFilter{Page: 1, Limit: 2})
+	must(t, err)
+	if list.Total != 3 || len(list.Users) != 2 {
+		t.Fatalf("got %d users of %d, want 2 of 3", len(list.Users), list.Total)
+	}
+	if list.Users[0].Name != "Meera" || list.Users[1].Name != "Ravi" {
+		t.Errorf("users not newest first: %s, %s", list.Users[0].Name, list.Users[1].Name)
+	}
+
+	list, err = s.ListUsers(ctx, types.UserFilter{Page: 2, Limit: 2})
+	must(t, err)
+	if len(list.Users) != 1 || list.Users[0].Name != "Asha" {
+		t.Errorf("second page = %+v", list.Users)
+	}
+
+	list, err = s.ListUsers(ctx, types.UserFilter{Query: "2100", Page: 1, Limit: 10})
+	must(t, err)
+	if list.Total != 2 {
+		t.Errorf("query by student ID matched %d users, want 2", list.Total)
+	}
+
+	list, err = s.ListUsers(ctx, types.UserFilter{Query: "MEERA", Page: 1, Limit: 10})
+	must(t, err)
+	if list.Total != 1 {
+		t.Errorf("case-insensitive query matched %d users, want 1", list.Total)
+	}
+
+	disabled := true
+	list, err = s.ListUsers(ctx, types.UserFilter{Role: types.RoleAdmin, Disabled: &disabled, Page: 1, Limit: 10})
+	must(t, err)
+	if list.Total != 1 || list.Users[0].ID.Hex() != ravi {
+		t.Errorf("role/disabled filter = %+v", list.Users)
+	}
+}
+
+func testInvitations(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	id, err := s.InsertUser(ctx, types.User{
+		Name:            "Asha",
+		Email:           "asha@example.com",
+		StudentId:       "2100001",
+		InviteTokenHash: "valid",
+		InviteExpiresAt: time.Now().Add(time.Hour),
+	})
+	must(t, err)
+	_, err = s.InsertUser(ctx, types.User{
+		Name:            "Ravi",
+		Email:           "ravi@example.com",
+		StudentId:       "2100002",
+		InviteTokenHash: "expired",
+		InviteExpiresAt: time.Now().Add(-time.Hour),
+	})
+	must(t, err)
+
+	wantErr(t, s.AcceptInvitation(ctx, "expired", "secret"), storage.ErrValidation)
+	wantErr(t, s.AcceptInvitation(ctx, "unknown", "secret"), storage.ErrValidation)
+
+	must(t, s.AcceptInvitation(ctx, "valid", "secret"))
+	user, err := s.GetUserById(ctx, id)
+	must(t, err)
+	if user.Password != "secret" || user.InviteTokenHash != "" || !user.InviteExpiresAt.IsZero() {
+		t.Errorf("invitation not consumed: %+v", user)
+	}
+
+	wantErr(t, s.AcceptInvitation(ctx, "valid", "again"), storage.ErrValidation)
+}
+
+func testContests(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	start := time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	id := createContest(t, s, "Weekly 1", start)
+
+	contests, err := s.GetAllContests(ctx)
+	must(t, err)
+	if len(contests) != 1 || contests[0].ID.Hex() != id || contests[0].Title != "Weekly 1" {
+		t.Fatalf("GetAllContests = %+v", contests)
+	}
+	if !contests[0].StartTime.Equal(start) {
+		t.Errorf("start time = %v, want %v", contests[0].StartTime, start)
+	}
+
+	contest, err := s.GetContestById(ctx, id)
+	must(t, err)
+	if contest.ID.Hex() != id || contest.Title != "Weekly 1" || contest.Description != "Weekly 1 description" {
+		t.Errorf("contest = %+v", contest)
+	}
+	if !contest.StartTime.Equal(start) || !contest.EndTime.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("times = %v - %v", contest.StartTime, contest.EndTime)
+	}
+	if len(contest.Questions) != 0 {
+		t.Errorf("questions = %+v, want none", contest.Questions)
+	}
+
+	must(t, s.EditContestById(ctx, id, types.Contest{Title: "Weekly 1 (rated)"}))
+	wantErr(t, s.EditContestById(ctx, id, types.Contest{EndTime: start.Add(-time.Hour)}), storage.ErrValidation)
+	wantErr(t, s.EditContestById(ctx, missingID, types.Contest{Title: "x"}), storage.ErrNotFound)
+
+	contest, err = s.GetContestById(ctx, id)
+	must(t, err)
+	if contest.Title != "Weekly 1 (rated)" {
+		t.Errorf("title = %q after edit", contest.Title)
+	}
+	if !contest.EndTime.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("end_time = %v, want unchanged after rejected edit", contest.EndTime)
+	}
+
+	// An edit leaves max_attempts alone unless it sets it, even to 0
+	maxAttempts := 2
+	must(t, s.EditContestById(ctx, id, types.Contest{MaxAttempts: &maxAttempts}))
+	must(t, s.EditContestById(ctx, id, types.Contest{Title: "Weekly 1 (rated)"}))
+	contest, err = s.GetContestById(ctx, id)
+	must(t, err)
+	if contest.MaxAttempts != 2 {
+		t.Errorf("max_attempts = %d after an edit without it, want 2", contest.MaxAttempts)
+	}
+	maxAttempts = 0
+	must(t, s.EditContestById(ctx, id, types.Contest{MaxAttempts: &maxAttempts}))
+	contest, err = s.GetContestById(ctx, id)
+	must(t, err)
+	if contest.MaxAttempts != 0 {
+		t.Errorf("max_attempts = %d after clearing it", contest.MaxAttempts)
+	}
+
+	must(t, s.DeleteContestById(ctx, id, true))
+	_, err = s.GetContestById(ctx, id)
+	wantErr(t, err, storage.ErrNotFound)
+	wantErr(t, s.DeleteContestById(ctx, id, true), storage.ErrNotFound)
+}
+
+func testContestQuestions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	contestId := createContest(t, s, "Weekly 1", time.Now().Add(time.Hour))
+
+	first, err := s.AddQuestionToContest(ctx, contestId, types.Question{
+		Title:       "Two Sum",
+		Description: "Add two numbers",
+		Difficulty:  "easy",
+		Points:      100,
+	})
+	must(t, err)
+	second, err := s.AddQuestionToContest(ctx, contestId, types.Question{
+		Title:       "Three Sum",
+		Description: "Add three numbers",
+		Difficulty:  "medium",
+	})
+	must(t, err)
+
+	_, err = s.AddQuestionToContest(ctx, missingID, types.Question{Title: "x", Description: "x"})
+	wantErr(t, err, storage.ErrNotFound)
+
+	contest, err := s.GetContestById(ctx, contestId)
+	must(t, err)
+	if len(contest.Questions) != 2 {
+		t.Fatalf("questions = %+v", contest.Questions)
+	}
+	want := types.QuestionSummary{ID: mustObjectID(t, first), Title: "Two Sum", Description: "Add two numbers", Difficulty: "easy", Position: 1, Label: "A", Points: 100}
+	if contest.Questions[0] != want {
+		t.Errorf("first question = %+v, want %+v", contest.Questions[0], want)
+	}
+
+	must(t, s.DeleteQuestionFromContestById(ctx, contestId, first))
+	wantErr(t, s.DeleteQuestionFromContestById(ctx, contestId, first), storage.ErrNotFound)
+	wantErr(t, s.DeleteQuestionFromContestById(ctx, missingID, second), storage.ErrNotFound)
+
+	contest, err = s.GetContestById(ctx, contestId)
+	must(t, err)
+	if len(contest.Questions) != 1 || contest.Questions[0].ID.Hex() != second {
+		t.Errorf("questions after delete = %+v", contest.Questions)
+	}
+
+	// The question leaves the contest but stays in the bank
+	_, err = s.GetQuestionById(ctx, first)
+	must(t, err)
+}
+
+func testQuestionBank(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	alice := mustObjectID(t, createUser(t, s, "Alice", "alice@example.com", "2300001"))
+	now := time.Now()
+	create := func(title, difficulty string, tags []string, author primitive.ObjectID, age time.Duration) string {
+		t.Helper()
+		id, err := s.CreateQuestion(ctx, types.Question{
+			Title:       title,
+			Description: title,
+			Difficulty:  difficulty,
+			Tags:        tags,
+			Points:      100,
+			CreatedBy:   author,
+			CreatedAt:   now.Add(-age),
+		})
+		must(t, err)
+		return id
+	}
+	graphs := create("Shortest Path", "hard", []string{"graphs", "dp"}, alice, 3*time.Hour)
+	sums := create("Two Sum", "easy", []string{"arrays"}, alice, 2*time.Hour)
+	trees := create("Tree Depth", "medium", []string{"graphs", "trees"}, primitive.NewObjectID(), time.Hour)
+
+	titles := func(filter types.QuestionFilter) []string {
+		t.Helper()
+		list, err := s.ListQuestions(ctx, filter)
+		must(t, err)
+		titles := []string{}
+		for _, question := range list.Questions {
+			titles = append(titles, question.Title)
+		}
+		return titles
+	}
+	for _, tt := range []struct {
+		filter types.QuestionFilter
+		want   []string
+	}{
+		{types.QuestionFilter{Page: 1, Limit: 10}, []string{"Tree Depth", "Two Sum", "Shortest Path"}},
+		{types.QuestionFilter{Tags: []string{"graphs"}, Page: 1, Limit: 10}, []string{"Tree Depth", "Shortest Path"}},
+		{types.QuestionFilter{Tags: []string{"graphs", "dp"}, Page: 1, Limit: 10}, []string{"Shortest Path"}},
+		{types.QuestionFilter{Difficulty: "easy", Page: 1, Limit: 10}, []string{"Two Sum"}},
+		{types.QuestionFilter{Author: alice.Hex(), Page: 1, Limit: 10}, []string{"Two Sum", "Shortest Path"}},
+		{types.QuestionFilter{Page: 2, Limit: 2}, []string{"Shortest Path"}},
+	} {
+		if got := titles(tt.filter); !slices.Equal(got, tt.want) {
+			t.Errorf("ListQuestions(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+	_, err := s.ListQuestions(ctx, types.QuestionFilter{Author: "not-an-id", Page: 1, Limit: 10})
+	wantErr(t, err, storage.ErrInvalidID)
+
+	// One question in two contests, with its own points and place in each
+	weekly := createContest(t, s, "Weekly", now.Add(time.Hour))
+	monthly := createContest(t, s, "Monthly", now.Add(2*time.Hour))
+	fifty := 50
+	must(t, s.LinkQuestionToContest(ctx, weekly, sums, types.ProblemPlacement{}))
+	must(t, s.LinkQuestionToContest(ctx, weekly, trees, types.ProblemPlacement{}))
+	must(t, s.LinkQuestionToContest(ctx, weekly, graphs, types.ProblemPlacement{Position: 1, Points: &fifty}))
+	must(t, s.LinkQuestionToContest(ctx, monthly, sums, types.ProblemPlacement{}))
+	wantErr(t, s.LinkQuestionToContest(ctx, weekly, sums, types.ProblemPlacement{}), storage.ErrConflict)
+	wantErr(t, s.LinkQuestionToContest(ctx, missingID, sums, types.ProblemPlacement{}), storage.ErrNotFound)
+	wantErr(t, s.LinkQuestionToContest(ctx, weekly, missingID, types.ProblemPlacement{}), storage.ErrNotFound)
+
+	problems := func(contestId string) []string {
+		t.Helper()
+		contest, err := s.GetContestById(ctx, contestId)
+		must(t, err)
+		problems := []string{}
+		for _, question := range contest.Questions {
+			problems = append(problems, fmt.Sprintf("%s:%d", question.Title, question.Points))
+		}
+		return problems
+	}
+	if got, want := problems(weekly), []string{"Shortest Path:50", "Two Sum:100", "Tree Depth:100"}; !slices.Equal(got, want) {
+		t.Errorf("weekly problems = %v, want %v", got, want)
+	}
+
+	must(t, s.UpdateContestProblem(ctx, weekly, graphs, types.ProblemPlacement{Position: 3}))
+	must(t, s.UpdateContestProblem(ctx, weekly, trees, types.ProblemPlacement{Points: &fifty}))
+	wantErr(t, s.UpdateContestProblem(ctx, monthly, graphs, types.ProblemPlacement{}), storage.ErrNotFound)
+	if got, want := problems(weekly), []string{"Two Sum:100", "Tree Depth:50", "Shortest Path:100"}; !slices.Equal(got, want) {
+		t.Errorf("weekly problems after update = %v, want %v", got, want)
+	}
+
+	list, err := s.ListQuestions(ctx, types.QuestionFilter{Difficulty: "easy", Page: 1, Limit: 10})
+	must(t, err)
+	if len(list.Questions) != 1 || list.Questions[0].Contests != 2 || list.Total != 1 {
+		t.Errorf("bank entry = %+v, want Two Sum used by 2 contests", list)
+	}
+
+	// A question in use isn't deleted unless forced
+	wantErr(t, s.DeleteQuestionById(ctx, sums, true, false), storage.ErrConflict)
+	must(t, s.DeleteQuestionFromContestById(ctx, monthly, sums))
+	wantErr(t, s.DeleteQuestionById(ctx, sums, true, false), storage.ErrConflict)
+	must(t, s.DeleteQuestionById(ctx, sums, true, true))
+	wantErr(t, s.DeleteQuestionById(ctx, sums, true, true), storage.ErrNotFound)
+	if got, want := problems(weekly), []string{"Tree Depth:50", "Shortest Path:100"}; !slices.Equal(got, want) {
+		t.Errorf("weekly problems after force delete = %v, want %v", got, want)
+	}
+
+	// Deleting a contest with cascade leaves questions other contests use
+	must(t, s.LinkQuestionToContest(ctx, monthly, trees, types.ProblemPlacement{}))
+	must(t, s.DeleteContestById(ctx, weekly, true))
+	_, err = s.GetQuestionById(ctx, trees)
+	must(t, err)
+	_, err = s.GetQuestionById(ctx, graphs)
+	wantErr(t, err, storage.ErrNotFound)
+	wantErr(t, s.DeleteQuestionById(ctx, missingID, true, true), storage.ErrNotFound)
+}
+
+func testContestProblems(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	contestId := createContest(t, s, "Weekly", time.Now().Add(time.Hour))
+	var ids []string
+	for _, title := range []string{"P1", "P2", "P3"} {
+		id, err := s.AddQuestionToContest(ctx, contestId, types.Question{Title: title, Description: title, Points: 100})
+		must(t, err)
+		ids = append(ids, id)
+	}
+
+	problems := func() []string {
+		t.Helper()
+		contest, err := s.GetContestById(ctx, contestId)
+		must(t, err)
+		problems := []string{}
+		for _, question := range contest.Questions {
+			problems = append(problems, fmt.Sprintf("%d%s:%s%s", question.Position, question.Label, question.Title, question.Colour))
+		}
+		return problems
+	}
+	if got, want := problems(), []string{"1A:P1", "2B:P2", "3C:P3"}; !slices.Equal(got, want) {
+		t.Errorf("problems = %v, want %v", got, want)
+	}
+
+	must(t, s.UpdateContestProblem(ctx, contestId, ids[1], types.ProblemPlacement{Label: "X", Colour: "#ff0000"}))
+	wantErr(t, s.UpdateContestProblem(ctx, contestId, ids[2], types.ProblemPlacement{Label: "A"}), storage.ErrValidation)
+	if got, want := problems(), []string{"1A:P1", "2X:P2#ff0000", "3C:P3"}; !slices.Equal(got, want) {
+		t.Errorf("problems after relabel = %v, want %v", got, want)
+	}
+
+	// Default letters follow the new order; custom labels move with their
+	// question
+	must(t, s.ReorderContestProblems(ctx, contestId, []string{ids[2], ids[1], ids[0]}))
+	if got, want := problems(), []string{"1A:P3", "2X:P2#ff0000", "3C:P1"}; !slices.Equal(got, want) {
+		t.Errorf("problems after reorder = %v, want %v", got, want)
+	}
+	wantErr(t, s.ReorderContestProblems(ctx, contestId, []string{ids[0], ids[1]}), storage.ErrValidation)
+	wantErr(t, s.ReorderContestProblems(ctx, contestId, []string{ids[0], ids[0], ids[1]}), storage.ErrValidation)
+	wantErr(t, s.ReorderContestProblems(ctx, contestId, []string{ids[0], ids[1], missingID}), storage.ErrValidation)
+	wantErr(t, s.ReorderContestProblems(ctx, missingID, nil), storage.ErrNotFound)
+
+	// A custom label holding the next letter blocks adding another question
+	// until it is changed
+	must(t, s.UpdateContestProblem(ctx, contestId, ids[1], types.ProblemPlacement{Label: "D"}))
+	_, err := s.AddQuestionToContest(ctx, contestId, types.Question{Title: "P4", Description: "P4"})
+	wantErr(t, err, storage.ErrValidation)
+
+	// Trashing a question doesn't relabel the others
+	must(t, s.DeleteQuestionById(ctx, ids[2], true, true))
+	if got, want := problems(), []string{"2D:P2", "3C:P1"}; !slices.Equal(got, want) {
+		t.Errorf("problems after trashing the first = %v, want %v", got, want)
+	}
+}
+
+func testQuestionTestCases(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{
+		Title:          "Two Sum",
+		Description:    "Add two numbers",
+		Difficulty:     "easy",
+		Tags:           []string{"math", "warmup"},
+		Points:         100,
+		Cpu_time_limit: 2000,
+		Memory_limit:   65536,
+	})
+	must(t, err)
+
+	public, err := s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{
+		Input:          "1 2",
+		ExpectedOutput: "3",
+		Visibility:     types.VisibilityPublic,
+	}, "")
+
+	must(t, err)
+	private, err := s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{
+		Input:          map[string]interface{}{"a": 2.0, "b": 2.0},
+		ExpectedOutput: 4.0,
+		Visibility:     types.VisibilityPrivate,
+	}, "")
+
+	must(t, err)
+
+	_, err = s.AddTestCaseToQuestion(ctx, missingID, types.TestCase{Input: "x", Visibility: types.VisibilityPublic}, "")
+	wantErr(t, err, storage.ErrNotFound)
+
+	owner, err := s.GetTestCaseQuestionId(ctx, private)
+	must(t, err)
+	if owner != questionId {
+		t.Errorf("owning question = %q, want %q", owner, questionId)
+	}
+	owner, err = s.GetTestCaseQuestionId(ctx, missingID)
+	must(t, err)
+	if owner != "" {
+		t.Errorf("owning question of unlisted test case = %q", owner)
+	}
+	_, err = s.GetTestCaseQuestionId(ctx, "not-an-id")
+	wantErr(t, err, storage.ErrInvalidID)
+
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.ID.Hex() != questionId || question.Title != "Two Sum" || question.Points != 100 {
+		t.Errorf("question = %+v", question)
+	}
+	if question.Cpu_time_limit != 2000 || question.Memory_limit != 65536 {
+		t.Errorf("limits = %d ms / %d KB", question.Cpu_time_limit, question.Memory_limit)
+	}
+	if len(question.Tags) != 2 || question.Tags[0] != "math" {
+		t.Errorf("tags = %v", question.Tags)
+	}
+
+	if len(question.TestCases) != 2 {
+		t.Fatalf("test cases = %+v", question.TestCases)
+	}
+	tc := question.TestCases[0]
+	if tc.ID.Hex() != public || tc.Input != "1 2" || tc.ExpectedOutput != "3" || tc.Visibility != types.VisibilityPublic {
+		t.Errorf("public test case = %+v", tc)
+	}
+	tc = question.TestCases[1]
+	if input, ok := tc.Input.(bson.M); !ok || input["a"] != 2.0 {
+		t.Errorf("structured input = %#v, want bson.M", tc.Input)
+	}
+	if tc.ExpectedOutput != 4.0 {
+		t.Errorf("expected output = %#v", tc.ExpectedOutput)
+	}
+
+	must(t, s.EditTestCaseById(ctx, public, types.TestCase{ExpectedOutput: "3\n"}, ""))
+	wantErr(t, s.EditTestCaseById(ctx, missingID, types.TestCase{Input: "x"}, ""), storage.ErrNotFound)
+
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Title: "Sum of Two", Tags: []string{"easy"}}, ""))
+	wantErr(t, s.EditQuestionById(ctx, missingID, types.Question{Title: "x"}, ""), storage.ErrNotFound)
+
+	must(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, private, ""))
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, private, ""), storage.ErrNotFound)
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, missingID, public, ""), storage.ErrNotFound)
+
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.Title != "Sum of Two" || question.Points != 100 {
+		t.Errorf("question after edit = %+v", question)
+	}
+	if len(question.Tags) != 1 || question.Tags[0] != "easy" {
+		t.Errorf("tags after edit = %v", question.Tags)
+	}
+	if len(question.TestCases) != 1 || question.TestCases[0].ExpectedOutput != "3\n" {
+		t.Errorf("test cases after edit = %+v", question.TestCases)
+	}
+
+	// An edit leaves max_attempts alone unless it sets it, even to 0
+	maxAttempts := 3
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{MaxAttempts: &maxAttempts}, ""))
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Title: "Sum of Two"}, ""))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.MaxAttempts != 3 {
+		t.Errorf("max_attempts = %d after an edit without it, want 3", question.MaxAttempts)
+	}
+	maxAttempts = 0
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{MaxAttempts: &maxAttempts}, ""))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.MaxAttempts != 0 {
+		t.Errorf("max_attempts = %d after clearing it", question.MaxAttempts)
+	}
+
+	_, err = s.GetQuestionById(ctx, missingID)
+	wantErr(t, err, storage.ErrNotFound)
+}
+
+func testRevisions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	setter := primitive.NewObjectID()
+	questionId, err := s.CreateQuestion(ctx, types.Question{
+		Title:       "Two Sum",
+		Description: "Add two numbers",
+		Points:      100,
+		CreatedBy:   setter,
+		CreatedAt:   time.Now(),
+	})
+	must(t, err)
+	testCaseId, err := s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{Input: "1 2", ExpectedOutput: "3", Visibility: types.VisibilityPublic}, setter.Hex())
+	must(t, err)
+
+	revisions := func() []types.Revision {
+		t.Helper()
+		revisions, err := s.ListQuestionRevisions(ctx, questionId)
+		must(t, err)
+		return revisions
+	}
+	actions := func(revisions []types.Revision) []string {
+		actions := []string{}
+		for _, rev := range revisions {
+			entity := "question"
+			if rev.TestCaseID != nil {
+				entity = "test case"
+			}
+			actions = append(actions, fmt.Sprintf("%d %s %s", rev.Number, rev.Action, entity))
+		}
+		return actions
+	}
+	// Adding a test case is recorded with how it was added
+	got := revisions()
+	if want := []string{"1 add test case"}; !slices.Equal(actions(got), want) {
+		t.Fatalf("revisions before any edit = %v, want %v", actions(got), want)
+	}
+	if got[0].TestCaseID.Hex() != testCaseId || got[0].Snapshot["input"] != "1 2" || len(got[0].Changes) == 0 {
+		t.Errorf("add = %+v", got[0])
+	}
+	if got[0].Author == nil || *got[0].Author != setter {
+		t.Errorf("add author = %v, want %s", got[0].Author, setter.Hex())
+	}
+	_, err = s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{Input: "x"}, "bad")
+	wantErr(t, err, storage.ErrInvalidID)
+
+	editor := primitive.NewObjectID().Hex()
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Description: "Add two integers", Points: 150}, editor))
+	// An edit changing nothing isn't recorded
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Points: 150}, editor))
+	must(t, s.EditTestCaseById(ctx, testCaseId, types.TestCase{ExpectedOutput: "3\n"}, editor))
+	wantErr(t, s.EditQuestionById(ctx, questionId, types.Question{Title: "x"}, "bad"), storage.ErrInvalidID)
+
+	// The first edit of the question records the original first; the test
+	// case's add already does
+	got = revisions()
+	want := []string{"1 add test case", "2 original question", "3 edit question", "4 edit test case"}
+	if !slices.Equal(actions(got), want) {
+		t.Fatalf("revisions = %v, want %v", actions(got), want)
+	}
+	if got[1].Author == nil || *got[1].Author != setter || got[1].Snapshot["description"] != "Add two numbers" {
+		t.Errorf("original = %+v", got[1])
+	}
+	if got[2].Author == nil || got[2].Author.Hex() != editor {
+		t.Errorf("edit author = %v, want %s", got[2].Author, editor)
+	}
+	changed := []string{}
+	for _, change := range got[2].Changes {
+		changed = append(changed, fmt.Sprintf("%s: %v -> %v", change.Field, change.Old, change.New))
+	}
+	if want := []string{"description: Add two numbers -> Add two integers", "points: 100 -> 150"}; !slices.Equal(changed, want) {
+		t.Errorf("changes = %v, want %v", changed, want)
+	}
+	if got[3].TestCaseID.Hex() != testCaseId || len(got[3].Changes) != 1 || got[3].Changes[0].Field != "expected_output" {
+		t.Errorf("test case edit = %+v", got[3])
+	}
+
+	// Rolling back to the original and the add restores what contestants
+	// first saw
+	must(t, s.RollbackQuestion(ctx, questionId, 2, editor))
+	must(t, s.RollbackQuestion(ctx, questionId, 1, editor))
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.Description != "Add two numbers" || question.Points != 100 || question.Title != "Two Sum" {
+		t.Errorf("question after rollback = %+v", question)
+	}
+	if len(question.TestCases) != 1 || question.TestCases[0].ExpectedOutput != "3" || question.TestCases[0].Input != "1 2" {
+		t.Errorf("test cases after rollback = %+v", question.TestCases)
+	}
+	got = revisions()
+	if len(got) != 6 || got[4].Action != types.RevisionRollback || got[4].RollbackOf != 2 || got[5].RollbackOf != 1 {
+		t.Errorf("revisions after rollback = %+v", got)
+	}
+	// Nothing left to undo
+	must(t, s.RollbackQuestion(ctx, questionId, 2, editor))
+	if n := len(revisions()); n != 6 {
+		t.Errorf("no-op rollback recorded a revision, %d revisions", n)
+	}
+
+	wantErr(t, s.RollbackQuestion(ctx, questionId, 99, editor), storage.ErrNotFound)
+	wantErr(t, s.RollbackQuestion(ctx, missingID, 1, editor), storage.ErrNotFound)
+	_, err = s.ListQuestionRevisions(ctx, missingID)
+	wantErr(t, err, storage.ErrNotFound)
+
+	// Replacing the test cases removes the old ones and adds the new
+	_, err = s.AddTestCasesToQuestion(ctx, questionId, nil, true, "bad")
+	wantErr(t, err, storage.ErrInvalidID)
+	replacer, remover := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	ids, err := s.AddTestCasesToQuestion(ctx, questionId, []types.TestCase{{Input: "2 2", ExpectedOutput: "4", Visibility: types.VisibilityPrivate}}, true, replacer)
+	must(t, err)
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, ids[0], "bad"), storage.ErrInvalidID)
+	must(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, ids[0], remover))
+	got = revisions()[6:]
+	if want := []string{"7 remove test case", "8 add test case", "9 remove test case"}; !slices.Equal(actions(got), want) {
+		t.Fatalf("revisions after removing test cases = %v, want %v", actions(got), want)
+	}
+	if got[0].TestCaseID.Hex() != testCaseId || len(got[0].Snapshot) != 0 {
+		t.Errorf("remove = %+v", got[0])
+	}
+	removed := map[string]interface{}{}
+	for _, change := range got[0].Changes {
+		if change.New != nil {
+			t.Errorf("remove changes %s to %v", change.Field, change.New)
+		}
+		removed[change.Field] = change.Old
+	}
+	if removed["input"] != "1 2" || removed["expected_output"] != "3" {
+		t.Errorf("remove changes = %+v", got[0].Changes)
+	}
+	if got[1].TestCaseID.Hex() != ids[0] || got[2].TestCaseID.Hex() != ids[0] {
+		t.Errorf("add/remove of new test case = %+v", got[1:])
+	}
+	for i, want := range []string{replacer, replacer, remover} {
+		if got[i].Author == nil || got[i].Author.Hex() != want {
+			t.Errorf("%s author = %v, want %s", got[i].Action, got[i].Author, want)
+		}
+	}
+
+	// A removed test case comes back through the trash, not a rollback
+	wantErr(t, s.RollbackQuestion(ctx, questionId, 7, editor), storage.ErrValidation)
+	wantErr(t, s.RollbackQuestion(ctx, questionId, 4, editor), storage.ErrNotFound)
+}
+
+func testStatements(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{
+		Title: "Two Sum",
+		Statement: &types.Statement{
+			Legend:             "Add *two* numbers.",
+			Constraints:        "$1 \\le a_i \\le 10^9$",
+			SampleExplanations: []string{"<b>1</b> + 2 = 3"},
+		},
+		// Clients can't supply the rendering
+		StatementHTML: &types.RenderedStatement{Statement: types.Statement{Legend: "<script>"}, Version: statement.Version},
+	})
+	must(t, err)
+
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.Statement == nil || question.Statement.Legend != "Add *two* numbers." {
+		t.Fatalf("statement = %+v", question.Statement)
+	}
+	html := question.StatementHTML
+	if html == nil || html.Legend != "<p>Add <em>two</em> numbers.</p>\n" {
+		t.Fatalf("rendered statement = %+v", html)
+	}
+	if !strings.Contains(html.Constraints, `<span class="math math-inline">\(1 \le a_i \le 10^9\)</span>`) {
+		t.Errorf("rendered constraints = %q", html.Constraints)
+	}
+	if len(html.SampleExplanations) != 1 || strings.Contains(html.SampleExplanations[0], "<b>") {
+		t.Errorf("rendered explanations = %q", html.SampleExplanations)
+	}
+
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Statement: &types.Statement{Legend: "Add **two** numbers."}}, ""))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.StatementHTML == nil || question.StatementHTML.Legend != "<p>Add <strong>two</strong> numbers.</p>\n" || question.StatementHTML.Constraints != "" {
+		t.Errorf("rendered statement after edit = %+v", question.StatementHTML)
+	}
+
+	// Rolling back restores the rendering with the statement
+	must(t, s.RollbackQuestion(ctx, questionId, 1, ""))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.StatementHTML == nil || question.StatementHTML.Legend != "<p>Add <em>two</em> numbers.</p>\n" {
+		t.Errorf("rendered statement after rollback = %+v", question.StatementHTML)
+	}
+}
+
+func testImportQuestion(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.ImportQuestion(ctx, types.Question{
+		Title:     "Imported",
+		Statement: &types.Statement{Legend: "Print *n*."},
+		Checker:   &types.Checker{Name: "std::wcmp.cpp"},
+	}, []types.TestCase{
+		{Input: "1\n", ExpectedOutput: "1\n", Visibility: types.VisibilityPublic},
+		{Input: "2\n", ExpectedOutput: "2\n", Visibility: types.VisibilityPrivate},
+		{Input: "3\n", ExpectedOutput: "3\n", Visibility: types.VisibilityPrivate},
+	})
+	must(t, err)
+
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if question.Checker == nil || question.Checker.Name != "std::wcmp.cpp" {
+		t.Errorf("checker = %+v", question.Checker)
+	}
+	if question.StatementHTML == nil || question.StatementHTML.Legend != "<p>Print <em>n</em>.</p>\n" {
+		t.Errorf("rendered statement = %+v", question.StatementHTML)
+	}
+	if len(question.TestCases) != 3 {
+		t.Fatalf("test cases = %+v", question.TestCases)
+	}
+	// Test cases keep package order
+	for i, tc := range question.TestCases {
+		if want := fmt.Sprintf("%d\n", i+1); tc.Input != want {
+			t.Errorf("test case %d input = %v, want %q", i, tc.Input, want)
+		}
+	}
+	if question.TestCases[0].Visibility != types.VisibilityPublic {
+		t.Errorf("first test case visibility = %q", question.TestCases[0].Visibility)
+	}
+}
+
+func testTestCaseBlobs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{Title: "Big Data"})
+	must(t, err)
+	input := types.BlobRef{Hash: strings.Repeat("a", 64), Size: 1 << 20}
+	testCaseId, err := s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{
+		InputBlob:      &input,
+		ExpectedOutput: "42\n",
+		Visibility:     types.VisibilityPrivate,
+	}, "")
+
+	must(t, err)
+
+	testCase, err := s.GetTestCaseById(ctx, testCaseId)
+	must(t, err)
+	if testCase.InputBlob == nil || *testCase.InputBlob != input || testCase.Input != nil {
+		t.Errorf("stored input = %v, %+v", testCase.Input, testCase.InputBlob)
+	}
+
+	// Replacing inline output with a blob clears the inline copy, and back
+	output := types.BlobRef{Hash: strings.Repeat("b", 64), Size: 3}
+	must(t, s.EditTestCaseById(ctx, testCaseId, types.TestCase{ExpectedOutputBlob: &output}, ""))
+	must(t, s.EditTestCaseById(ctx, testCaseId, types.TestCase{Input: "1\n"}, ""))
+
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if len(question.TestCases) != 1 {
+		t.Fatalf("test cases = %+v", question.TestCases)
+	}
+	tc := question.TestCases[0]
+	if tc.Input != "1\n" || tc.InputBlob != nil {
+		t.Errorf("input = %v, %+v", tc.Input, tc.InputBlob)
+	}
+	if tc.ExpectedOutput != nil || tc.ExpectedOutputBlob == nil || *tc.ExpectedOutputBlob != output {
+		t.Errorf("expected output = %v, %+v", tc.ExpectedOutput, tc.ExpectedOutputBlob)
+	}
+
+	if _, err := s.GetTestCaseById(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("missing test case: %v", err)
+	}
+}
+
+func testAddTestCases(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{Title: "Many Tests"})
+	must(t, err)
+	oldId, err := s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{Input: "0", ExpectedOutput: "0", Visibility: types.VisibilityPublic}, "")
+	must(t, err)
+
+	batch := func(inputs ...string) []types.TestCase {
+		var testCases []types.TestCase
+		for i, input := range inputs {
+			testCases = append(testCases, types.TestCase{Input: input, ExpectedOutput: input, Visibility: types.VisibilityPrivate, Subtask: i + 1})
+		}
+		return testCases
+	}
+	inputs := func() []string {
+		question, err := s.GetQuestionById(ctx, questionId)
+		must(t, err)
+		var got []string
+		for _, tc := range question.TestCases {
+			got = append(got, fmt.Sprintf("%v/%d", tc.Input, tc.Subtask))
+		}
+		return got
+	}
+
+	ids, err := s.AddTestCasesToQuestion(ctx, questionId, batch("1", "2"), false, "")
+	must(t, err)
+	if len(ids) != 2 {
+		t.Fatalf("ids = %v", ids)
+	}
+	if got, want := inputs(), []string{"0/0", "1/1", "2/2"}; !slices.Equal(got, want) {
+		t.Errorf("after append = %v, want %v", got, want)
+	}
+
+	_, err = s.AddTestCasesToQuestion(ctx, questionId, batch("3", "4", "5"), true, "")
+	must(t, err)
+	if got, want := inputs(), []string{"3/1", "4/2", "5/3"}; !slices.Equal(got, want) {
+		t.Errorf("after replace = %v, want %v", got, want)
+	}
+
+	// Replaced test cases are in the trash and can come back
+	must(t, s.RestoreDeleted(ctx, types.KindTestCase, oldId))
+	if got, want := inputs(), []string{"0/0", "3/1", "4/2", "5/3"}; !slices.Equal(got, want) {
+		t.Errorf("after restore = %v, want %v", got, want)
+	}
+
+	_, err = s.AddTestCasesToQuestion(ctx, primitive.NewObjectID().Hex(), batch("6"), false, "")
+	wantErr(t, err, storage.ErrNotFound)
+}
+
+func testQuestionSolutions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{Title: "Checked"})
+	must(t, err)
+	solutions, err := s.GetQuestionSolutions(ctx, questionId)
+	must(t, err)
+	if solutions == nil || len(solutions) != 0 {
+		t.Errorf("solutions of a new question = %#v, want empty", solutions)
+	}
+
+	want := []types.Solution{
+		{Name: "main", LanguageID: "54", Code: "int main() {}", Expected: types.SolutionAccepted},
+		{Name: "greedy", LanguageID: "71", Code: "print(1)", Expected: types.SolutionWrong},
+	}
+	must(t, s.SetQuestionSolutions(ctx, questionId, want))
+	solutions, err = s.GetQuestionSolutions(ctx, questionId)
+	must(t, err)
+	if !slices.Equal(solutions, want) {
+		t.Errorf("solutions = %+v, want %+v", solutions, want)
+	}
+
+	// Editing the question leaves its solutions alone
+	must(t, s.EditQuestionById(ctx, questionId, types.Question{Title: "Checked again"}, ""))
+	solutions, err = s.GetQuestionSolutions(ctx, questionId)
+	must(t, err)
+	if len(solutions) != 2 {
+		t.Errorf("solutions after edit = %+v", solutions)
+	}
+
+	must(t, s.SetQuestionSolutions(ctx, questionId, nil))
+	solutions, err = s.GetQuestionSolutions(ctx, questionId)
+	must(t, err)
+	if len(solutions) != 0 {
+		t.Errorf("solutions after clearing = %+v", solutions)
+	}
+
+	wantErr(t, s.SetQuestionSolutions(ctx, primitive.NewObjectID().Hex(), want), storage.ErrNotFound)
+	_, err = s.GetQuestionSolutions(ctx, primitive.NewObjectID().Hex())
+	wantErr(t, err, storage.ErrNotFound)
+}
+
+func testQuestionTools(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	questionId, err := s.CreateQuestion(ctx, types.Question{Title: "Generated"})
+	must(t, err)
+	tools, err := s.GetQuestionTools(ctx, questionId)
+	must(t, err)
+	if tools.Validator != nil || tools.Generators == nil || len(tools.Generators) != 0 || tools.Solutions == nil {
+		t.Errorf("tools of a new question = %+v", tools)
+	}
+
+	validator := types.Program{LanguageID: "54", Code: "int main() { return 0; }"}
+	generators := []types.Generator{{Name: "rand", LanguageID: "71", Code: "print(1)"}}
+	solutions := []types.Solution{{Name: "main", LanguageID: "71", Code: "print(input())", Expected: types.SolutionAccepted}}
+	must(t, s.SetQuestionValidator(ctx, questionId, &validator))
+	must(t, s.SetQuestionGenerators(ctx, questionId, generators))
+	must(t, s.SetQuestionSolutions(ctx, questionId, solutions))
+
+	tools, err = s.GetQuestionTools(ctx, questionId)
+	must(t, err)
+	if tools.Validator == nil || *tools.Validator != validator || !slices.Equal(tools.Generators, generators) || !slices.Equal(tools.Solutions, solutions) {
+		t.Errorf("tools = %+v", tools)
+	}
+
+	must(t, s.SetQuestionValidator(ctx, questionId, nil))
+	tools, err = s.GetQuestionTools(ctx, questionId)
+	must(t, err)
+	if tools.Validator != nil || len(tools.Generators) != 1 {
+		t.Errorf("tools after removing the validator = %+v", tools)
+	}
+	wantErr(t, s.SetQuestionGenerators(ctx, primitive.NewObjectID().Hex(), generators), storage.ErrNotFound)
+
+	// Provenance is kept until the test data is edited
+	generated := types.Generation{Generator: "rand", Args: "10", Seed: 3, Solution: "main", GeneratedAt: time.Now().UTC().Truncate(time.Millisecond)}
+	ids, err := s.AddTestCasesToQuestion(ctx, questionId, []types.TestCase{
+		{Input: "1", ExpectedOutput: "1", Visibility: types.VisibilityPrivate, Generated: &generated},
+	}, false, "")
+
+	must(t, err)
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if g := question.TestCases[0].Generated; g == nil || *g != generated {
+		t.Errorf("provenance = %+v, want %+v", g, generated)
+	}
+	must(t, s.EditTestCaseById(ctx, ids[0], types.TestCase{Visibility: types.VisibilityPublic}, ""))
+	testCase, err := s.GetTestCaseById(ctx, ids[0])
+	must(t, err)
+	if testCase.Generated == nil {
+		t.Error("provenance dropped by a visibility change")
+	}
+	must(t, s.EditTestCaseById(ctx, ids[0], types.TestCase{Input: "2"}, ""))
+	testCase, err = s.GetTestCaseById(ctx, ids[0])
+	must(t, err)
+	if testCase.Generated != nil {
+		t.Errorf("provenance kept after editing the input: %+v", testCase.Generated)
+	}
+}
+
+func testSubmissions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userId := createUser(t, s, "Asha", "asha@example.com", "2100001")
+	questionID, contestID := primitive.NewObjectID(), primitive.NewObjectID()
+	submittedAt := time.Now()
+	id, err := s.CreateSubmission(ctx, types.Submission{
+		UserID:      mustObjectID(t, userId),
+		QuestionID:  questionID,
+		ContestID:   contestID,
+		Code:        "print(1)",
+		LanguageID:  "71",
+		Status:      types.StatusPending,
+		SubmittedAt: submittedAt,
+	})
+	must(t, err)
+
+	submission, err := s.GetSubmissionById(ctx, id)
+	must(t, err)
+	if submission.ID.Hex() != id || submission.Code != "print(1)" || submission.Status != types.StatusPending {
+		t.Errorf("GetSubmissionById = %+v", submission)
+	}
+	if !submission.SubmittedAt.Equal(submittedAt.Truncate(time.Millisecond)) {
+		t.Errorf("SubmittedAt = %v, want %v at millisecond precision", submission.SubmittedAt, submittedAt)
+	}
+
+	must(t, s.UpdateSubmissionStatus(ctx, id, types.StatusAccepted, 100))
+	submission, err = s.GetSubmissionById(ctx, id)
+	must(t, err)
+	if submission.Status != types.StatusAccepted || submission.Score != 100 {
+		t.Errorf("status/score = %q/%d", submission.Status, submission.Score)
+	}
+
+	wantErr(t, s.UpdateSubmissionStatus(ctx, missingID, types.StatusAccepted, 0), storage.ErrNotFound)
+	_, err = s.GetSubmissionById(ctx, missingID)
+	wantErr(t, err, storage.ErrNotFound)
+
+	// Attempts count per user, contest and question
+	for _, other := range []types.Submission{
+		{UserID: mustObjectID(t, userId), QuestionID: questionID, ContestID: contestID},
+		{UserID: mustObjectID(t, userId), QuestionID: primitive.NewObjectID(), ContestID: contestID},
+		{UserID: mustObjectID(t, userId), QuestionID: questionID, ContestID: primitive.NewObjectID()},
+		{UserID: primitive.NewObjectID(), QuestionID: questionID, ContestID: contestID},
+	} {
+		other.Code, other.LanguageID, other.Status = "print(2)", "71", types.StatusPending
+		_, err := s.CreateSubmission(ctx, other)
+		must(t, err)
+	}
+	count, err := s.CountSubmissions(ctx, userId, contestID.Hex(), questionID.Hex())
+	must(t, err)
+	if count != 2 {
+		t.Errorf("CountSubmissions = %d, want 2", count)
+	}
+	count, err = s.CountSubmissions(ctx, userId, "", questionID.Hex())
+	must(t, err)
+	if count != 3 {
+		t.Errorf("CountSubmissions across contests = %d, want 3", count)
+	}
+	_, err = s.CountSubmissions(ctx, userId, "bad", questionID.Hex())
+	wantErr(t, err, storage.ErrInvalidID)
+
+	must(t, s.CreateAuditEntry(ctx, types.AuditEntry{Action: types.AuditAccountLocked, Subject: "asha@example.com"}))
+}
+
+func testPublicProfile(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userId := createUser(t, s, "Asha", "asha@example.com", "2100001")
+	must(t, s.UpdateUserProfile(ctx, userId, types.ProfileUpdate{Bio: "hello"}))
+
+	older := createContest(t, s, "Weekly 1", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := createContest(t, s, "Weekly 2", time.Date(2030, 1, 8, 0, 0, 0, 0, time.UTC))
+	shared := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+
+	submit := func(contestId string, questionId primitive.ObjectID, status string, score int) {
+		t.Helper()
+		_, err := s.CreateSubmission(ctx, types.Submission{
+			UserID:     mustObjectID(t, userId),
+			QuestionID: questionId,
+			ContestID:  mustObjectID(t, contestId),
+			Code:       "code",
+			LanguageID: "71",
+			Status:     status,
+			Score:      score,
+		})
+		must(t, err)
+	}
+	submit(older, shared, types.StatusWrongAnswer, 20)
+	submit(older, shared, types.StatusAccepted, 100)
+	submit(older, other, types.StatusWrongAnswer, 30)
+	submit(newer, shared, types.StatusAccepted, 50)
+
+	profile, err := s.GetPublicProfile(ctx, userId)
+	must(t, err)
+	if profile.ID.Hex() != userId || profile.Name != "Asha" || profile.Bio != "hello" {
+		t.Errorf("profile = %+v", profile)
+	}
+	if profile.SolvedCount != 1 {
+		t.Errorf("SolvedCount = %d, want 1 distinct question", profile.SolvedCount)
+	}
+	if len(profile.Contests) != 2 {
+		t.Fatalf("got %d contests, want 2", len(profile.Contests))
+	}
+
+	latest, earliest := profile.Contests[0], profile.Contests[1]
+	if latest.ContestID.Hex() != newer || earliest.ContestID.Hex() != older {
+		t.Fatalf("contests not newest first: %+v", profile.Contests)
+	}
+	if earliest.Title != "Weekly 1" || earliest.Submissions != 3 || earliest.Solved != 1 || earliest.Score != 130 {
+		t.Errorf("older contest = %+v, want 3 submissions, 1 solved, score 130", earliest)
+	}
+	if latest.Submissions != 1 || latest.Solved != 1 || latest.Score != 50 {
+		t.Errorf("newer contest = %+v", latest)
+	}
+
+	_, err = s.GetPublicProfile(ctx, missingID)
+	wantErr(t, err, storage.ErrNotFound)
+}
+
+// contestWithTestCase creates a contest holding one question with one
+// test case.
+func contestWithTestCase(t *testing.T, s storage.Storage, title string) (contestId, questionId, testCaseId string) {
+	t.Helper()
+	ctx := context.Background()
+	contestId = createContest(t, s, title, time.Now().Add(time.Hour))
+	questionId, err := s.AddQuestionToContest(ctx, contestId, types.Question{Title: title + " Q1", Description: "x"})
+	must(t, err)
+	testCaseId, err = s.AddTestCaseToQuestion(ctx, questionId, types.TestCase{Input: "1", ExpectedOutput: "1", Visibility: types.VisibilityPublic}, "")
+	must(t, err)
+	return contestId, questionId, testCaseId
+}
+
+func testTrash(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	contestId, questionId, testCaseId := contestWithTestCase(t, s, "Weekly")
+	must(t, s.DeleteContestById(ctx, contestId, true))
+
+	_, err := s.GetContestById(ctx, contestId)
+	wantErr(t, err, storage.ErrNotFound)
+	_, err = s.GetQuestionById(ctx, questionId)
+	wantErr(t, err, storage.ErrNotFound)
+	contests, err := s.GetAllContests(ctx)
+	must(t, err)
+	if len(contests) != 0 {
+		t.Errorf("contests = %+v, want trashed contest hidden", contests)
+	}
+	wantErr(t, s.EditContestById(ctx, contestId, types.Contest{Title: "x"}), storage.ErrNotFound)
+	_, err = s.AddQuestionToContest(ctx, contestId, types.Question{Title: "x", Description: "x"})
+	wantErr(t, err, storage.ErrNotFound)
+
+	trash, err := s.ListTrash(ctx)
+	must(t, err)
+	if len(trash) != 3 {
+		t.Fatalf("trash = %+v, want contest, question and test case", trash)
+	}
+	wantKinds := []types.ContentKind{types.KindContest, types.KindQuestion, types.KindTestCase}
+	wantIds := []string{contestId, questionId, testCaseId}
+	for i, item := range trash {
+		if item.Kind != wantKinds[i] || item.ID.Hex() != wantIds[i] || item.DeletedAt.IsZero() {
+			t.Errorf("trash[%d] = %+v, want %s %s", i, item, wantKinds[i], wantIds[i])
+		}
+	}
+	if trash[0].Title != "Weekly" {
+		t.Errorf("contest title in trash = %q", trash[0].Title)
+	}
+
+	// Restoring the contest brings back everything deleted with it
+	must(t, s.RestoreDeleted(ctx, types.KindContest, contestId))
+	wantErr(t, s.RestoreDeleted(ctx, types.KindContest, contestId), storage.ErrNotFound)
+	contest, err := s.GetContestById(ctx, contestId)
+	must(t, err)
+	if len(contest.Questions) != 1 {
+		t.Errorf("questions after restore = %+v", contest.Questions)
+	}
+	question, err := s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if len(question.TestCases) != 1 {
+		t.Errorf("test cases after restore = %+v", question.TestCases)
+	}
+
+	// A test case deleted on its own stays deleted when its question is
+	// trashed and restored
+	must(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, testCaseId, ""))
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, questionId, testCaseId, ""), storage.ErrNotFound)
+	must(t, s.DeleteQuestionById(ctx, questionId, true, true))
+	must(t, s.RestoreDeleted(ctx, types.KindQuestion, questionId))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if len(question.TestCases) != 0 {
+		t.Errorf("test cases = %+v, want separately deleted one still in trash", question.TestCases)
+	}
+	must(t, s.RestoreDeleted(ctx, types.KindTestCase, testCaseId))
+	question, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	if len(question.TestCases) != 1 {
+		t.Errorf("test cases after restoring it = %+v", question.TestCases)
+	}
+
+	// Without cascade only the contest goes
+	must(t, s.DeleteContestById(ctx, contestId, false))
+	_, err = s.GetQuestionById(ctx, questionId)
+	must(t, err)
+	trash, err = s.ListTrash(ctx)
+	must(t, err)
+	if len(trash) != 1 || trash[0].Kind != types.KindContest {
+		t.Errorf("trash without cascade = %+v", trash)
+	}
+
+	wantErr(t, s.RestoreDeleted(ctx, types.KindQuestion, questionId), storage.ErrNotFound)
+	wantErr(t, s.RestoreDeleted(ctx, "answer", questionId), storage.ErrValidation)
+}
+
+func testPurge(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userId := createUser(t, s, "Alice", "alice@example.com", "2300001")
+	gone, goneQuestion, goneTestCase := contestWithTestCase(t, s, "Gone")
+	kept, keptQuestion, keptTestCase := contestWithTestCase(t, s, "Kept")
+	for _, submission := range []types.Submission{
+		{UserID: mustObjectID(t, userId), ContestID: mustObjectID(t, gone), QuestionID: mustObjectID(t, goneQuestion), Code: "x", LanguageID: "71"},
+		{UserID: mustObjectID(t, userId), ContestID: mustObjectID(t, kept), QuestionID: mustObjectID(t, keptQuestion), Code: "x", LanguageID: "71"},
+	} {
+		_, err := s.CreateSubmission(ctx, submission)
+		must(t, err)
+	}
+
+	must(t, s.DeleteContestById(ctx, gone, true))
+	must(t, s.DeleteTestCaseFromQuestionById(ctx, keptQuestion, keptTestCase, ""))
+
+	// Nothing is old enough yet
+	result, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour), true)
+	must(t, err)
+	if *result != (types.PurgeResult{}) {
+		t.Errorf("purge of recent trash = %+v, want nothing", result)
+	}
+
+	result, err = s.PurgeDeleted(ctx, time.Now().Add(time.Second), true)
+	must(t, err)
+	// The revisions go too: adding the gone question's test case, and
+	// adding and removing the kept question's
+	want := types.PurgeResult{Contests: 1, Questions: 1, TestCases: 2, Submissions: 1, Revisions: 3}
+	if *result != want {
+		t.Errorf("purge = %+v, want %+v", result, want)
+	}
+
+	trash, err := s.ListTrash(ctx)
+	must(t, err)
+	if len(trash) != 0 {
+		t.Errorf("trash after purge = %+v", trash)
+	}
+	wantErr(t, s.RestoreDeleted(ctx, types.KindContest, gone), storage.ErrNotFound)
+	wantErr(t, s.RestoreDeleted(ctx, types.KindTestCase, goneTestCase), storage.ErrNotFound)
+
+	question, err := s.GetQuestionById(ctx, keptQuestion)
+	must(t, err)
+	if len(question.TestCases) != 0 {
+		t.Errorf("kept question test cases = %+v", question.TestCases)
+	}
+	profile, err := s.GetPublicProfile(ctx, userId)
+	must(t, err)
+	if len(profile.Contests) != 1 || profile.Contests[0].ContestID.Hex() != kept {
+		t.Errorf("profile contests after purge = %+v", profile.Contests)
+	}
+}
+
+func testInvalidIDs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	const bad = "not-an-id"
+
+	_, err := s.GetUserById(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.UpdateUserRole(ctx, bad, types.RoleUser), storage.ErrInvalidID)
+	wantErr(t, s.DeleteUserById(ctx, bad), storage.ErrInvalidID)
+	_, err = s.GetPublicProfile(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	_, err = s.GetContestById(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.EditContestById(ctx, bad, types.Contest{Title: "x"}), storage.ErrInvalidID)
+	wantErr(t, s.DeleteContestById(ctx, bad, true), storage.ErrInvalidID)
+	_, err = s.GetQuestionById(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.EditQuestionById(ctx, bad, types.Question{Title: "x"}, ""), storage.ErrInvalidID)
+	_, err = s.AddQuestionToContest(ctx, bad, types.Question{Title: "x"})
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.DeleteQuestionFromContestById(ctx, bad, missingID), storage.ErrInvalidID)
+	wantErr(t, s.DeleteQuestionFromContestById(ctx, missingID, bad), storage.ErrInvalidID)
+	wantErr(t, s.LinkQuestionToContest(ctx, bad, missingID, types.ProblemPlacement{}), storage.ErrInvalidID)
+	wantErr(t, s.LinkQuestionToContest(ctx, missingID, bad, types.ProblemPlacement{}), storage.ErrInvalidID)
+	wantErr(t, s.UpdateContestProblem(ctx, bad, missingID, types.ProblemPlacement{}), storage.ErrInvalidID)
+	wantErr(t, s.UpdateContestProblem(ctx, missingID, bad, types.ProblemPlacement{}), storage.ErrInvalidID)
+	wantErr(t, s.DeleteQuestionById(ctx, bad, true, true), storage.ErrInvalidID)
+	wantErr(t, s.ReorderContestProblems(ctx, bad, nil), storage.ErrInvalidID)
+	wantErr(t, s.RestoreDeleted(ctx, types.KindContest, bad), storage.ErrInvalidID)
+	wantErr(t, s.RestoreDeleted(ctx, types.KindTestCase, bad), storage.ErrInvalidID)
+	_, err = s.AddTestCaseToQuestion(ctx, bad, types.TestCase{Input: "x"}, "")
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.EditTestCaseById(ctx, bad, types.TestCase{Input: "x"}, ""), storage.ErrInvalidID)
+	_, err = s.ListQuestionRevisions(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.RollbackQuestion(ctx, bad, 1, ""), storage.ErrInvalidID)
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, bad, missingID, ""), storage.ErrInvalidID)
+	wantErr(t, s.DeleteTestCaseFromQuestionById(ctx, missingID, bad, ""), storage.ErrInvalidID)
+	_, err = s.GetSubmissionById(ctx, bad)
+	wantErr(t, err, storage.ErrInvalidID)
+	wantErr(t, s.UpdateSubmissionStatus(ctx, bad, types.StatusAccepted, 0), storage.ErrInvalidID)
+}
+
+func mustObjectID(t *testing.T, id string) primitive.ObjectID {
+	t.Helper()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		t.Fatalf("invalid id %q: %v", id, err)
+	}
+	return objectId
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/types"
+)
+
+// SortTrash orders items most recently deleted first, with parents before
+// the children deleted along with them.
+func SortTrash(items []types.TrashItem) {
+	rank := map[types.ContentKind]int{types.KindContest: 0, types.KindQuestion: 1, types.KindTestCase: 2}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		if items[i].Kind != items[j].Kind {
+			return rank[items[i].Kind] < rank[items[j].Kind]
+		}
+		return items[i].ID.Hex() < items[j].ID.Hex()
+	})
+}
@@ -12,40 +12,160 @@ const (
 )
 
 type User struct {
-    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-    Name      string            `bson:"name" json:"name" validate:"required"`
-    Email     string            `bson:"email" json:"email" validate:"required"`
-    Password  string            `bson:"password" json:"password" validate:"required"`
-    StudentId string            `bson:"studentId" json:"studentId" validate:"required"`
-    CreatedAt time.Time         `bson:"createdAt" json:"createdAt"`
-    Role      Role              `bson:"role" json:"role" default:"user"`
+    ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+    Name              string             `bson:"name" json:"name" validate:"required"`
+    Email             string             `bson:"email" json:"email" validate:"required"`
+    Password          string             `bson:"password" json:"-"`
+    StudentId         string             `bson:"studentId" json:"studentId" validate:"required"`
+    CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+    UpdatedAt         time.Time          `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+    Role              Role               `bson:"role" json:"role" default:"user"`
+    Avatar            string             `bson:"avatar,omitempty" json:"avatar,omitempty"`
+    Bio               string             `bson:"bio,omitempty" json:"bio,omitempty"`
+    PreferredLanguage string             `bson:"preferredLanguage,omitempty" json:"preferredLanguage,omitempty"`
+    Disabled          bool               `bson:"disabled" json:"disabled"`
+    Team              string             `bson:"team,omitempty" json:"team,omitempty"`
+    InviteTokenHash   string             `bson:"inviteTokenHash,omitempty" json:"-"`
+    InviteExpiresAt   time.Time          `bson:"inviteExpiresAt,omitempty" json:"-"`
 }
 
 type Contest struct {
     ID          primitive.ObjectID   `bson:"_id,omitempty" json:"contest_id"`
-    Title       string              `bson:"title" json:"title" validate:"required"`
+    Title       string              `bson:"title" json:"title" validate:"required,max=200"`
     StartTime   time.Time           `bson:"start_time" json:"start_time" validate:"required"`
-    EndTime     time.Time           `bson:"end_time" json:"end_time" validate:"required"`
+    EndTime     time.Time           `bson:"end_time" json:"end_time" validate:"required,gtfield=StartTime"`
     Description string              `bson:"description" json:"description" validate:"required"`
-    CreatedBy   string              `bson:"created_by" json:"created_by"`
-    QuestionIDs []string            `bson:"question_ids" json:"question_ids,omitempty"`
+    CreatedBy   primitive.ObjectID  `bson:"created_by,omitempty" json:"created_by,omitempty"`
+    // MaxAttempts caps each contestant's submissions in the contest to
+    // each of its questions, besides the question's own cap; 0 or unset
+    // means no cap. An edit only changes it when set, so 0 clears it.
+    MaxAttempts *int                `bson:"max_attempts,omitempty" json:"max_attempts,omitempty" validate:"omitempty,min=0"`
+    Problems    []ContestProblem    `bson:"problems" json:"-"`
     CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+    DeletedAt   *time.Time          `bson:"deleted_at,omitempty" json:"-"`
+    DeletionID  primitive.ObjectID  `bson:"deletion_id,omitempty" json:"-"`
+}
+
+// ContestProblem places a question from the bank in a contest. Its index
+// in Contest.Problems is its order in the contest.
+type ContestProblem struct {
+    QuestionID primitive.ObjectID `bson:"question_id" json:"question_id"`
+    // Points overrides the question's own points in this contest
+    Points     *int               `bson:"points,omitempty" json:"points,omitempty"`
+    // Label replaces the letter given by position (A, B, C...)
+    Label      string             `bson:"label,omitempty" json:"label,omitempty"`
+    Colour     string             `bson:"colour,omitempty" json:"colour,omitempty"`
 }
 
 type Question struct {
-    ID        string    `bson:"_id,omitempty" json:"question_id"`
-    Title     string    `bson:"title" json:"title" validate:"required"`
-    Description string `bson:"description" json:"description" validate:"required"`
-    Difficulty string `bson:"difficulty" json:"difficulty"`
-    Tags []string     `bson:"tags" json:"tags"`
-    TestCaseIDs []string `bson:"test_case_ids" json:"test_case_ids" validate:"required"`
-    Points int `bson:"points" json:"points"`
-    Cpu_time_limit int `bson:"cpu_time_limit" json:"cpu_time_limit"`
-    Memory_limit int `bson:"memory_limit" json:"memory_limit"`
+    ID        primitive.ObjectID `bson:"_id,omitempty" json:"question_id"`
+    Title     string    `bson:"title" json:"title" validate:"required,max=200"`
+    Description string `bson:"description" json:"description" validate:"required_without=Statement"`
+    Statement *Statement `bson:"statement,omitempty" json:"statement,omitempty"`
+    // StatementHTML caches Statement rendered by the statement package
+    StatementHTML *RenderedStatement `bson:"statement_html,omitempty" json:"-" validate:"-"`
+    Difficulty string `bson:"difficulty" json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+    Tags []string     `bson:"tags" json:"tags" validate:"omitempty,dive,required,max=50"`
+    TestCaseIDs []primitive.ObjectID `bson:"test_case_ids" json:"test_case_ids"`
+    Points int `bson:"points" json:"points" validate:"min=0"`
+    Cpu_time_limit int `bson:"cpu_time_limit" json:"cpu_time_limit" validate:"min=0"`
+    Memory_limit int `bson:"memory_limit" json:"memory_limit" validate:"min=0"`
+    Checker *Checker `bson:"checker,omitempty" json:"checker,omitempty"`
+    // MaxAttempts caps each contestant's submissions to the question,
+    // counted across every contest; 0 or unset means no cap. An edit
+    // only changes it when set, so 0 clears it.
+    MaxAttempts *int `bson:"max_attempts,omitempty" json:"max_attempts,omitempty" validate:"omitempty,min=0"`
+    // Solutions are only read and written through their own endpoints,
+    // never with the rest of the question
+    Solutions []Solution `bson:"solutions,omitempty" json:"-" validate:"-"`
+    Validator *Program `bson:"validator,omitempty" json:"-" validate:"-"`
+    Generators []Generator `bson:"generators,omitempty" json:"-" validate:"-"`
     CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
     CreatedAt time.Time `bson:"created_at" json:"created_at"`
+    DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"-"`
+    DeletionID primitive.ObjectID `bson:"deletion_id,omitempty" json:"-"`
+}
+
+// Statement is a structured problem statement. Each part is Markdown, with
+// LaTeX math between $ (inline) or $$ (display) delimiters.
+// SampleExplanations explain the public test cases, in order.
+type Statement struct {
+    Legend             string   `bson:"legend" json:"legend" validate:"required,max=20000"`
+    InputFormat        string   `bson:"input_format,omitempty" json:"input_format,omitempty" validate:"max=10000"`
+    OutputFormat       string   `bson:"output_format,omitempty" json:"output_format,omitempty" validate:"max=10000"`
+    Constraints        string   `bson:"constraints,omitempty" json:"constraints,omitempty" validate:"max=10000"`
+    Notes              string   `bson:"notes,omitempty" json:"notes,omitempty" validate:"max=10000"`
+    SampleExplanations []string `bson:"sample_explanations,omitempty" json:"sample_explanations,omitempty" validate:"max=50,dive,max=10000"`
+}
+
+// RenderedStatement is a Statement with every part rendered to sanitised
+// HTML by the renderer Version.
+type RenderedStatement struct {
+    Statement `bson:",inline"`
+    Version   int `bson:"version" json:"-"`
+}
+
+// Checker is the program a problem package compares output with. Name is
+// a standard checker (such as Polygon's std::wcmp.cpp or Kattis's default)
+// or the file name of Source. Checkers are kept so packages export as they
+// were imported; judging still compares output exactly.
+type Checker struct {
+    Name     string `bson:"name" json:"name" validate:"required,max=200"`
+    Language string `bson:"language,omitempty" json:"language,omitempty" validate:"max=50"`
+    Source   string `bson:"source,omitempty" json:"source,omitempty" validate:"max=1000000"`
+    Args     string `bson:"args,omitempty" json:"args,omitempty" validate:"max=1000"`
+}
+
+// Solution is a program a setter wrote for a question, run against its
+// test cases to check them: a reference solution must pass every test, a
+// known-wrong one must fail at least one.
+type Solution struct {
+    Name string `bson:"name" json:"name" validate:"required,max=100"`
+    LanguageID string `bson:"language_id" json:"language_id" validate:"required"`
+    Code string `bson:"code" json:"code" validate:"required,max=200000"`
+    Expected SolutionExpectation `bson:"expected" json:"expected" validate:"required,oneof=accepted wrong"`
 }
 
+// Program is source code for the execution backend.
+type Program struct {
+    LanguageID string `bson:"language_id" json:"language_id" validate:"required"`
+    Code string `bson:"code" json:"code" validate:"required,max=200000"`
+}
+
+// Generator is a program that prints a test input, given its arguments
+// and a seed as command-line arguments.
+type Generator struct {
+    Name string `bson:"name" json:"name" validate:"required,max=100"`
+    LanguageID string `bson:"language_id" json:"language_id" validate:"required"`
+    Code string `bson:"code" json:"code" validate:"required,max=200000"`
+}
+
+// Generation records how a test case was generated, so it can be
+// reproduced: the generator run with Args and Seed, and the solution its
+// expected output came from.
+type Generation struct {
+    Generator string `bson:"generator" json:"generator"`
+    Args string `bson:"args,omitempty" json:"args,omitempty"`
+    Seed int64 `bson:"seed" json:"seed"`
+    Solution string `bson:"solution" json:"solution"`
+    GeneratedAt time.Time `bson:"generated_at" json:"generated_at"`
+}
+
+// QuestionTools are the programs a setter keeps with a question to build
+// and check its test data.
+type QuestionTools struct {
+    Solutions []Solution `bson:"solutions" json:"solutions"`
+    Validator *Program `bson:"validator,omitempty" json:"validator"`
+    Generators []Generator `bson:"generators" json:"generators"`
+}
+
+type SolutionExpectation string
+
+const (
+    SolutionAccepted SolutionExpectation = "accepted"
+    SolutionWrong    SolutionExpectation = "wrong"
+)
+
 type Visibility string
 
 const (
@@ -53,12 +173,65 @@ const (
     VisibilityPrivate Visibility = "private"
 )
 
+// TestCase data is either inline or, when large, a blob in blob storage.
 type TestCase struct {
-    ID string `bson:"_id,omitempty" json:"test_case_id"`
+    ID primitive.ObjectID `bson:"_id,omitempty" json:"test_case_id"`
     Input interface{} `bson:"input" json:"input"`
     ExpectedOutput interface{} `bson:"expected_output" json:"expected_output"`
+    InputBlob *BlobRef `bson:"input_blob,omitempty" json:"input_blob,omitempty"`
+    ExpectedOutputBlob *BlobRef `bson:"expected_output_blob,omitempty" json:"expected_output_blob,omitempty"`
     CreatedAt time.Time `bson:"created_at" json:"created_at"`
     Visibility Visibility `bson:"visibility" json:"visibility" validate:"required,oneof=public private"`
+    // Subtask groups test cases for partial scoring; 0 means none
+    Subtask int `bson:"subtask,omitempty" json:"subtask,omitempty" validate:"gte=0,lte=1000"`
+    // Generated is set on test cases made by a generator, and cleared when
+    // their data is edited
+    Generated *Generation `bson:"generated,omitempty" json:"generated,omitempty" validate:"-"`
+    DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"-"`
+    DeletionID primitive.ObjectID `bson:"deletion_id,omitempty" json:"-"`
+}
+
+// BlobRef points at data in blob storage by the SHA-256 of its content.
+type BlobRef struct {
+    Hash string `bson:"hash" json:"hash" validate:"required,len=64,hexadecimal,lowercase"`
+    Size int64  `bson:"size" json:"size"`
+}
+
+type RevisionAction string
+
+const (
+    // RevisionOriginal is the state before the first recorded change
+    RevisionOriginal RevisionAction = "original"
+    RevisionEdit     RevisionAction = "edit"
+    RevisionRollback RevisionAction = "rollback"
+    // RevisionAdd and RevisionRemove record a test case joining or leaving
+    // its question
+    RevisionAdd      RevisionAction = "add"
+    RevisionRemove   RevisionAction = "remove"
+)
+
+// Revision is an immutable record of one change to a question or to one of
+// its test cases. Revisions of a question and its test cases are numbered
+// in a single sequence from 1. Snapshot holds the tracked fields as they
+// were after the change.
+type Revision struct {
+    ID         primitive.ObjectID  `bson:"_id,omitempty" json:"revision_id"`
+    QuestionID primitive.ObjectID  `bson:"question_id" json:"question_id"`
+    TestCaseID *primitive.ObjectID `bson:"test_case_id,omitempty" json:"test_case_id,omitempty"`
+    Number     int                 `bson:"number" json:"number"`
+    Action     RevisionAction      `bson:"action" json:"action"`
+    RollbackOf int                 `bson:"rollback_of,omitempty" json:"rollback_of,omitempty"`
+    Author     *primitive.ObjectID `bson:"author,omitempty" json:"author,omitempty"`
+    CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+    Changes    []FieldChange       `bson:"changes" json:"changes"`
+    Snapshot   map[string]interface{} `bson:"snapshot" json:"snapshot"`
+}
+
+// FieldChange is one field's value before and after a revision.
+type FieldChange struct {
+    Field string      `bson:"field" json:"field"`
+    Old   interface{} `bson:"old" json:"old"`
+    New   interface{} `bson:"new" json:"new"`
 }
 
 type Submission struct {
@@ -84,9 +257,22 @@ const (
 )
 
 type Leaderboard struct {
-    ID string `bson:"_id,omitempty" json:"leaderboard_id"`
+    ID primitive.ObjectID `bson:"_id,omitempty" json:"leaderboard_id"`
     UserID primitive.ObjectID `bson:"user_id" json:"user_id"`
     ContestID primitive.ObjectID `bson:"contest_id" json:"contest_id"`
     LeaderboardScore int `bson:"leaderboard_score" json:"leaderboard_score"`
     CreatedAt time.Time `bson:"created_at" json:"created_at"`
-}
\ No newline at end of file
+}
+
+const (
+    AuditAccountLocked = "account_locked"
+)
+
+type AuditEntry struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+    Action    string             `bson:"action" json:"action"`
+    Subject   string             `bson:"subject" json:"subject"`
+    IP        string             `bson:"ip" json:"ip"`
+    Details   string             `bson:"details" json:"details"`
+    CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
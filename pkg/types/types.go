@@ -2,13 +2,170 @@ package types
 
 import (
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ContestBasicInfo struct {
-    ID          string    `bson:"_id" json:"contest_id"`
+    ID          primitive.ObjectID `bson:"_id" json:"contest_id"`
     Title       string    `bson:"title" json:"title"`
     StartTime   time.Time `bson:"start_time" json:"start_time"`
     EndTime     time.Time `bson:"end_time" json:"end_time"`
     Description string    `bson:"description" json:"description"`
 }
 
+
+// ContestDetail is a contest together with summaries of its questions.
+type ContestDetail struct {
+    ID          primitive.ObjectID `bson:"_id" json:"contest_id"`
+    Title       string            `bson:"title" json:"title"`
+    StartTime   time.Time         `bson:"start_time" json:"start_time"`
+    EndTime     time.Time         `bson:"end_time" json:"end_time"`
+    Description string            `bson:"description" json:"description"`
+    MaxAttempts int               `bson:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+    Questions   []QuestionSummary `bson:"questions" json:"questions"`
+}
+
+type QuestionSummary struct {
+    ID          primitive.ObjectID `bson:"_id" json:"question_id"`
+    Title       string `bson:"title" json:"title"`
+    Description string `bson:"description" json:"description"`
+    Difficulty  string `bson:"difficulty" json:"difficulty"`
+    // Position counts from 1 in contest order
+    Position    int    `bson:"position" json:"position"`
+    Label       string `bson:"label" json:"label"`
+    Colour      string `bson:"colour,omitempty" json:"colour,omitempty"`
+    // Points is what the question is worth in this contest
+    Points      int    `bson:"points" json:"points"`
+}
+
+// ProblemPlacement is where a question goes in a contest and how it is
+// shown and scored there. Position counts from 1; 0 appends when linking
+// and keeps the current place when updating. Nil Points falls back to the
+// question's own, an empty Label to the letter for its position.
+type ProblemPlacement struct {
+    Position int    `json:"position" validate:"min=0"`
+    Points   *int   `json:"points" validate:"omitempty,min=0"`
+    Label    string `json:"label" validate:"omitempty,alphanum,max=8"`
+    Colour   string `json:"colour" validate:"omitempty,hexcolor"`
+}
+
+// ProblemOrder lists every question in a contest in its new order.
+type ProblemOrder struct {
+    QuestionIDs []string `json:"question_ids" validate:"required,dive,mongodb"`
+}
+
+// QuestionFilter narrows the question bank. A question must carry every
+// tag in Tags; Author is a user id.
+type QuestionFilter struct {
+    Tags       []string
+    Difficulty string
+    Author     string
+    Page       int
+    Limit      int
+}
+
+// QuestionBankEntry summarises a question in the bank together with the
+// number of contests using it.
+type QuestionBankEntry struct {
+    ID         primitive.ObjectID `bson:"_id" json:"question_id"`
+    Title      string             `bson:"title" json:"title"`
+    Difficulty string             `bson:"difficulty" json:"difficulty"`
+    Tags       []string           `bson:"tags" json:"tags"`
+    Points     int                `bson:"points" json:"points"`
+    CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+    CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+    Contests   int                `bson:"-" json:"contests"`
+}
+
+type QuestionList struct {
+    Questions []QuestionBankEntry `json:"questions"`
+    Total     int64               `json:"total"`
+    Page      int                 `json:"page"`
+    Limit     int                 `json:"limit"`
+}
+
+// QuestionDetail is a question together with its test cases.
+type QuestionDetail struct {
+    ID             primitive.ObjectID `bson:"_id" json:"question_id"`
+    Title          string           `bson:"title" json:"title"`
+    Description    string           `bson:"description" json:"description"`
+    Statement      *Statement       `bson:"statement,omitempty" json:"statement,omitempty"`
+    StatementHTML  *RenderedStatement `bson:"statement_html,omitempty" json:"statement_html,omitempty"`
+    Difficulty     string           `bson:"difficulty" json:"difficulty"`
+    Tags           []string         `bson:"tags" json:"tags"`
+    Points         int              `bson:"points" json:"points"`
+    Cpu_time_limit int              `bson:"cpu_time_limit" json:"cpu_time_limit"`
+    Memory_limit   int              `bson:"memory_limit" json:"memory_limit"`
+    Checker        *Checker         `bson:"checker,omitempty" json:"checker,omitempty"`
+    MaxAttempts    int              `bson:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+    TestCases      []TestCaseDetail `bson:"test_cases" json:"test_cases"`
+}
+
+type TestCaseDetail struct {
+    ID             primitive.ObjectID `bson:"_id" json:"test_case_id"`
+    Input          interface{} `bson:"input" json:"input"`
+    ExpectedOutput interface{} `bson:"expected_output" json:"expected_output"`
+    InputBlob      *BlobRef    `bson:"input_blob,omitempty" json:"input_blob,omitempty"`
+    ExpectedOutputBlob *BlobRef `bson:"expected_output_blob,omitempty" json:"expected_output_blob,omitempty"`
+    Visibility     Visibility  `bson:"visibility" json:"visibility"`
+    Subtask        int         `bson:"subtask,omitempty" json:"subtask,omitempty"`
+    Generated      *Generation `bson:"generated,omitempty" json:"generated,omitempty"`
+}
+
+// QuestionView is a question as contestants see it: its public test cases
+// are samples and the others are only counted.
+type QuestionView struct {
+    ID             primitive.ObjectID `json:"question_id"`
+    Title          string             `json:"title"`
+    Description    string             `json:"description"`
+    Statement      *Statement         `json:"statement,omitempty"`
+    StatementHTML  *RenderedStatement `json:"statement_html,omitempty"`
+    Difficulty     string             `json:"difficulty"`
+    Tags           []string           `json:"tags"`
+    Points         int                `json:"points"`
+    Cpu_time_limit int                `json:"cpu_time_limit"`
+    Memory_limit   int                `json:"memory_limit"`
+    MaxAttempts    int                `json:"max_attempts,omitempty"`
+    Samples        []Sample           `json:"samples"`
+    HiddenTestCases int               `json:"hidden_test_cases"`
+}
+
+// Sample is a public test case with the statement's explanation of it, if
+// it has one, as written and rendered.
+type Sample struct {
+    TestCaseID      primitive.ObjectID `json:"test_case_id"`
+    Input           string             `json:"input"`
+    ExpectedOutput  string             `json:"expected_output"`
+    Explanation     string             `json:"explanation,omitempty"`
+    ExplanationHTML string             `json:"explanation_html,omitempty"`
+}
+
+
+// ContentKind names a kind of content that can be moved to the trash.
+type ContentKind string
+
+const (
+    KindContest  ContentKind = "contest"
+    KindQuestion ContentKind = "question"
+    KindTestCase ContentKind = "test_case"
+)
+
+// TrashItem is a deleted contest, question or test case that can still be
+// restored.
+type TrashItem struct {
+    Kind      ContentKind        `json:"kind"`
+    ID        primitive.ObjectID `json:"id"`
+    Title     string             `json:"title,omitempty"`
+    DeletedAt time.Time          `json:"deleted_at"`
+}
+
+// PurgeResult counts what a purge removed for good.
+type PurgeResult struct {
+    Contests        int `json:"contests"`
+    Questions       int `json:"questions"`
+    TestCases       int `json:"test_cases"`
+    Submissions     int `json:"submissions"`
+    LeaderboardRows int `json:"leaderboard_rows"`
+    Revisions       int `json:"revisions"`
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SignupRequest struct {
+    Name      string `json:"name" validate:"required"`
+    Email     string `json:"email" validate:"required"`
+    Password  string `json:"password" validate:"required"`
+    StudentId string `json:"studentId" validate:"required"`
+}
+
+type AcceptInvitationRequest struct {
+    Token    string `json:"token" validate:"required"`
+    Password string `json:"password" validate:"required"`
+}
+
+// ProfileUpdate holds the fields a user may change on their own account.
+// Empty fields are left untouched.
+type ProfileUpdate struct {
+    Name              string `json:"name" validate:"max=100"`
+    Avatar            string `json:"avatar" validate:"omitempty,url,max=2048"`
+    Bio               string `json:"bio" validate:"max=1000"`
+    PreferredLanguage string `json:"preferredLanguage" validate:"max=50"`
+}
+
+type RoleUpdate struct {
+    Role Role `json:"role" validate:"required,oneof=user admin"`
+}
+
+type UserFilter struct {
+    Query    string
+    Role     Role
+    Disabled *bool
+    Page     int
+    Limit    int
+}
+
+type UserList struct {
+    Users []User `json:"users"`
+    Total int64  `json:"total"`
+    Page  int    `json:"page"`
+    Limit int    `json:"limit"`
+}
+
+type ContestParticipation struct {
+    ContestID   primitive.ObjectID `bson:"_id" json:"contest_id"`
+    Title       string             `bson:"title" json:"title"`
+    StartTime   time.Time          `bson:"start_time" json:"start_time"`
+    Submissions int                `bson:"submissions" json:"submissions"`
+    Solved      int                `bson:"solved" json:"solved"`
+    Score       int                `bson:"score" json:"score"`
+}
+
+// PublicProfile is what anyone may see about a user.
+type PublicProfile struct {
+    ID          primitive.ObjectID     `json:"id"`
+    Name        string                 `json:"name"`
+    Avatar      string                 `json:"avatar,omitempty"`
+    Bio         string                 `json:"bio,omitempty"`
+    CreatedAt   time.Time              `json:"createdAt"`
+    SolvedCount int                    `json:"solved_count"`
+    Contests    []ContestParticipation `json:"contests"`
+}
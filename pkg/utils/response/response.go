@@ -2,29 +2,117 @@ package response
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/storage"
+	"github.com/krishkumar84/bdcoe-golang-portal/pkg/validation"
 )
 
+// Response is the error envelope returned by every endpoint.
 type Response struct {
-	Status string
-	Error string
+	Status    string       `json:"status"`
+	Code      string       `json:"code"`
+	Error     string       `json:"error"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
 	}
+
+// FieldError reports one failed rule against a request field, named as
+// the client sent it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
 	
 	const (
 		StatusOK = "ok"
 		StatusError = "error"
 	)
+
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodeTooLarge,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusTooManyRequests:       CodeRateLimited,
+}
 	
 func WriteJson(w http.ResponseWriter, status int, data interface{}) error {
+	if resp, ok := data.(Response); ok {
+		if resp.Code == "" {
+			resp.Code = codeForStatus(status)
+		}
+		resp.RequestID = w.Header().Get(RequestIDHeader)
+		data = resp
+	}
+
 	w.Header().Set("Content-Type","application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(data)
 }
 
+func codeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// StatusForError maps the storage error kinds onto HTTP statuses. Anything
+// unrecognised is an internal error.
+func StatusForError(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrInvalidID):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrValidation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteError responds to a failed operation with the status matching err.
+// Internal errors are logged and replaced with a generic message so driver
+// and network details don't leak to clients.
+func WriteError(w http.ResponseWriter, err error) error {
+	status := StatusForError(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("request failed", slog.String("request_id", w.Header().Get(RequestIDHeader)), slog.String("error", err.Error()))
+		err = fmt.Errorf("internal server error")
+	}
+	return WriteJson(w, status, GeneralError(err))
+}
+
 func GeneralError(err error) Response {
 	return Response{
 		Status: StatusError,
@@ -32,19 +120,44 @@ func GeneralError(err error) Response {
 	}
 }
 
-func ValidationError(errs validator.ValidationErrors) Response {
+// ValidationError reports every failed rule in err, which is normally the
+// result of validation.Struct or validation.Partial.
+func ValidationError(err error) Response {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return Response{
+			Status: StatusError,
+			Code:   CodeValidationFailed,
+			Error:  err.Error(),
+		}
+	}
+
+	fields := make([]FieldError, 0, len(errs))
 	var errMsgs []string
 
-	for _,err := range errs {
-		switch err.ActualTag() {
-		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required",err.Field()))
-		default:
-			errMsgs = append(errMsgs,fmt.Sprintf("field %s is invalid",err.Field()) )	
-		}
+	for _,fe := range errs {
+		message := validation.Message(fe)
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Code:    fe.ActualTag(),
+			Message: message,
+		})
+		errMsgs = append(errMsgs, message)
 	}
 	return Response{
 		Status: StatusError,
+		Code:   CodeValidationFailed,
 		Error: strings.Join(errMsgs,", "),
+		Fields: fields,
 	}
-}
\ No newline at end of file
+}
+
+// fieldPath drops the top-level struct name from the namespace, so nested
+// fields come out as e.g. "test_cases[0].visibility".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by the names clients send rather than Go struct names.
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return fld.Name
+		}
+		return name
+	})
+
+	return v
+}
+
+// Struct validates every field of s against its validate tags.
+func Struct(s interface{}) error {
+	return validate.Struct(s)
+}
+
+// Partial validates only the fields of s that are set. It suits partial
+// updates, where a zero field means "leave unchanged" rather than "missing".
+// A set pointer to a struct replaces the whole struct, so all its fields
+// are validated.
+func Partial(s interface{}) error {
+	val := reflect.Indirect(reflect.ValueOf(s))
+	if val.Kind() != reflect.Struct {
+		return validate.Struct(s)
+	}
+
+	var fields []string
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if !field.IsExported() || val.Field(i).IsZero() {
+			continue
+		}
+		fields = append(fields, field.Name)
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			for _, nested := range reflect.VisibleFields(field.Type.Elem()) {
+				if nested.IsExported() {
+					fields = append(fields, field.Name+"."+nested.Name)
+				}
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return validate.StructPartial(s, fields...)
+}
+
+// Message describes a failed rule in words suitable for API clients.
+func Message(fe validator.FieldError) string {
+	field := fe.Field()
+
+	switch fe.ActualTag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "mongodb":
+		return fmt.Sprintf("%s must be a valid id", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		if isLength(fe.Kind()) {
+			return fmt.Sprintf("%s must have at least %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		if isLength(fe.Kind()) {
+			return fmt.Sprintf("%s must have at most %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "required_without":
+		return fmt.Sprintf("%s is required unless %s is given", field, snakeCase(fe.Param()))
+	case "gtfield":
+		return fmt.Sprintf("%s must be after %s", field, snakeCase(fe.Param()))
+	default:
+		return fmt.Sprintf("%s is invalid", field)
+	}
+}
+
+func isLength(kind reflect.Kind) bool {
+	return kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array
+}
+
+// snakeCase turns the Go field name in a cross-field rule's parameter into
+// the snake_case JSON name the API uses for it.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}